@@ -7,12 +7,18 @@ CREATE TABLE IF NOT EXISTS av_time_series_metadata (
     id SERIAL PRIMARY KEY,
     symbol VARCHAR(50) NOT NULL,
     last_refreshed DATE NOT NULL,
+    provider VARCHAR(50) NOT NULL DEFAULT 'alphavantage',
+    frequency VARCHAR(20) NOT NULL DEFAULT 'weekly', -- bar spacing, drives the refresh staleness policy
     created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
     
     CONSTRAINT uq_time_series_metadata_symbol UNIQUE (symbol)
 );
 
+-- existing databases predate the provider and frequency columns
+ALTER TABLE av_time_series_metadata ADD COLUMN IF NOT EXISTS provider VARCHAR(50) NOT NULL DEFAULT 'alphavantage';
+ALTER TABLE av_time_series_metadata ADD COLUMN IF NOT EXISTS frequency VARCHAR(20) NOT NULL DEFAULT 'weekly';
+
 CREATE OR REPLACE FUNCTION update_av_time_series_metadata_updated_at()
 RETURNS TRIGGER AS $$
 BEGIN
@@ -47,6 +53,115 @@ CREATE TABLE IF NOT EXISTS av_time_series_data (
 
 CREATE INDEX IF NOT EXISTS idx_time_series_source_timestamp ON av_time_series_data(source_id, timestamp DESC);
 
+-- one row per field a sync or import overwrote, e.g. adjusted closes restated after a split or dividend
+CREATE TABLE IF NOT EXISTS av_time_series_revision (
+    id SERIAL PRIMARY KEY,
+    source_id INTEGER NOT NULL,
+    "timestamp" DATE NOT NULL,
+    field VARCHAR(50) NOT NULL,
+    previous_value NUMERIC(20, 4) NOT NULL,
+    new_value NUMERIC(20, 4) NOT NULL,
+    provider VARCHAR(50) NOT NULL,
+    revised_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_time_series_revision_metadata FOREIGN KEY (source_id)
+        REFERENCES av_time_series_metadata(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_time_series_revision_source_timestamp ON av_time_series_revision(source_id, timestamp DESC);
+
+-- dividends (cash per share) and splits (coefficient, 4 for a 4:1) by ex date, used to rebuild adjusted closes
+CREATE TABLE IF NOT EXISTS av_corporate_action (
+    id SERIAL PRIMARY KEY,
+    source_id INTEGER NOT NULL,
+    ex_date DATE NOT NULL,
+    action_type VARCHAR(20) NOT NULL,
+    amount NUMERIC(20, 6) NOT NULL,
+    origin VARCHAR(50) NOT NULL, -- provider that reported it, or 'inferred' when derived from the adjusted series
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT uq_corporate_action UNIQUE (source_id, ex_date, action_type),
+    CONSTRAINT ck_corporate_action_type CHECK (action_type IN ('dividend', 'split')),
+    CONSTRAINT fk_corporate_action_metadata FOREIGN KEY (source_id)
+        REFERENCES av_time_series_metadata(id)
+        ON DELETE CASCADE
+);
+
+-- history of background and manual refreshes of stale symbols
+CREATE TABLE IF NOT EXISTS refresh_run (
+    id SERIAL PRIMARY KEY,
+    "trigger" VARCHAR(20) NOT NULL, -- schedule or manual
+    status VARCHAR(20) NOT NULL, -- running, completed, skipped (another instance held the lock) or failed
+    started_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMPTZ,
+    symbols_checked INTEGER NOT NULL DEFAULT 0,
+    stale INTEGER NOT NULL DEFAULT 0,
+    synced INTEGER NOT NULL DEFAULT 0,
+    fresh INTEGER NOT NULL DEFAULT 0,
+    failed INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_run_started_at ON refresh_run(started_at DESC);
+
+-- named symbol lists that can be synced together
+CREATE TABLE IF NOT EXISTS watchlist (
+    id SERIAL PRIMARY KEY,
+    "name" VARCHAR(100) NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT uq_watchlist_name UNIQUE ("name")
+);
+
+CREATE TABLE IF NOT EXISTS watchlist_symbol (
+    watchlist_id INTEGER NOT NULL,
+    symbol VARCHAR(50) NOT NULL,
+
+    CONSTRAINT uq_watchlist_symbol UNIQUE (watchlist_id, symbol),
+    CONSTRAINT fk_watchlist_symbol_watchlist FOREIGN KEY (watchlist_id)
+        REFERENCES watchlist(id)
+        ON DELETE CASCADE
+);
+
+-- stress tests, either a historical window replayed against an allocation or a set of shocks per symbol
+CREATE TABLE IF NOT EXISTS stress_scenario (
+    id SERIAL PRIMARY KEY,
+    "name" VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    kind VARCHAR(20) NOT NULL, -- historical or shock
+    start_date DATE, -- historical window, null for shocks
+    end_date DATE,
+    shocks JSONB NOT NULL DEFAULT '{}', -- symbol -> total return, e.g. {"SPY": -0.3}
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT uq_stress_scenario_name UNIQUE ("name")
+);
+
+-- predefined windows, peak to trough of the S&P 500 unless noted
+INSERT INTO stress_scenario ("name", description, kind, start_date, end_date) VALUES
+    ('dotcom', 'Dot-com bust', 'historical', '2000-03-24', '2002-10-09'),
+    ('gfc_2008', '2008 global financial crisis', 'historical', '2007-10-09', '2009-03-09'),
+    ('covid_2020', '2020 COVID crash', 'historical', '2020-02-19', '2020-03-23'),
+    ('rate_shock_2022', '2022 rate shock, stocks and bonds fell together', 'historical', '2022-01-03', '2022-10-12')
+ON CONFLICT ("name") DO NOTHING;
+
+-- published capital market assumptions, one row per set of asset class assumptions
+CREATE TABLE IF NOT EXISTS capital_market_assumption (
+    id SERIAL PRIMARY KEY,
+    "name" VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    asset_classes JSONB NOT NULL DEFAULT '{}', -- class -> {"expectedreturn": 0.06, "volatility": 0.16}, annualized
+    correlations JSONB NOT NULL DEFAULT '{}', -- class -> class -> correlation, each pair once in either direction
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT uq_capital_market_assumption_name UNIQUE ("name")
+);
+
 -- create table to store scenario meta data
 CREATE TABLE IF NOT EXISTS scenario_configuration (
     id SERIAL PRIMARY KEY,
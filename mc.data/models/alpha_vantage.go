@@ -18,14 +18,35 @@ type TimeSeriesMetadata struct {
 	Id            int32     `db:"id"`
 	Symbol        string    `db:"symbol"`
 	LastRefreshed time.Time `db:"last_refreshed"`
+	Provider      string    `db:"provider"`  // market data provider the series was sourced from
+	Frequency     string    `db:"frequency"` // bar spacing, one of the Frequency constants. empty is stored as weekly
 }
 
+const (
+	FrequencyDaily   = "daily"
+	FrequencyWeekly  = "weekly"
+	FrequencyMonthly = "monthly"
+)
+
 type TimeSeriesData struct {
 	SourceId  int32     `db:"source_id"`
 	Timestamp time.Time `db:"timestamp"`
 	TimeSeriesOHLCV
 	AdjustedClose  float64 `db:"adjusted_close"`
 	DividendAmount float64 `db:"dividend_amount"`
+	// only daily series report splits, zero when the provider did not say. splits are stored as corporate actions
+	SplitCoefficient float64 `db:"-"`
+}
+
+// TimeSeriesRevision records one stored value that was overwritten by a later sync or import
+type TimeSeriesRevision struct {
+	SourceId      int32     `db:"source_id" json:"-"`
+	Timestamp     time.Time `db:"timestamp" json:"timestamp"`
+	Field         string    `db:"field" json:"field"`
+	PreviousValue float64   `db:"previous_value" json:"previousvalue"`
+	NewValue      float64   `db:"new_value" json:"newvalue"`
+	Provider      string    `db:"provider" json:"provider"`
+	RevisedAt     time.Time `db:"revised_at" json:"revisedat"`
 }
 
 type TimeSeriesIntradayData struct {
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// CapitalMarketAssumptions is a published set of forward looking asset class assumptions, all annualized
+type CapitalMarketAssumptions struct {
+	Id           int32                           `db:"id" json:"id"`
+	Name         string                          `db:"name" json:"name"`
+	Description  string                          `db:"description" json:"description"`
+	AssetClasses map[string]AssetClassAssumption `db:"asset_classes" json:"assetclasses"`
+	Correlations map[string]map[string]float64   `db:"correlations" json:"correlations"`
+	CreatedAt    time.Time                       `db:"created_at" json:"createdat"`
+	UpdatedAt    time.Time                       `db:"updated_at" json:"updatedat"`
+}
+
+type AssetClassAssumption struct {
+	ExpectedReturn float64 `json:"expectedreturn"`
+	Volatility     float64 `json:"volatility"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+const (
+	CorporateActionDividend = "dividend"
+	CorporateActionSplit    = "split"
+
+	// origin of actions derived from the vendor's adjusted series rather than reported directly
+	CorporateActionInferred = "inferred"
+)
+
+// CorporateAction is a dividend (amount is cash per share) or split (amount is the coefficient, 4 for a 4:1)
+type CorporateAction struct {
+	SourceId   int32     `db:"source_id" json:"-"`
+	ExDate     time.Time `db:"ex_date" json:"exdate"`
+	ActionType string    `db:"action_type" json:"actiontype"`
+	Amount     float64   `db:"amount" json:"amount"`
+	Origin     string    `db:"origin" json:"origin"`
+}
@@ -0,0 +1,24 @@
+package models
+
+// SymbolMatch is a single hit from a provider's symbol search
+type SymbolMatch struct {
+	Symbol     string  `json:"symbol"`
+	Name       string  `json:"name"`
+	Type       string  `json:"type"`
+	Region     string  `json:"region"`
+	Currency   string  `json:"currency"`
+	MatchScore float64 `json:"matchscore"`
+}
+
+// SymbolMetadata is descriptive information about a symbol, not to be confused with TimeSeriesMetadata
+type SymbolMetadata struct {
+	Symbol      string `json:"symbol"`
+	Name        string `json:"name"`
+	AssetType   string `json:"assettype"`
+	Exchange    string `json:"exchange"`
+	Currency    string `json:"currency"`
+	Country     string `json:"country"`
+	Sector      string `json:"sector"`
+	Industry    string `json:"industry"`
+	Description string `json:"description"`
+}
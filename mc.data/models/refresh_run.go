@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+const (
+	RefreshTriggerSchedule = "schedule"
+	RefreshTriggerManual   = "manual"
+
+	RefreshStatusRunning   = "running"
+	RefreshStatusCompleted = "completed"
+	RefreshStatusSkipped   = "skipped" // another instance held the refresh lock
+	RefreshStatusFailed    = "failed"
+)
+
+type RefreshRun struct {
+	Id             int32      `db:"id" json:"id"`
+	Trigger        string     `db:"trigger" json:"trigger"`
+	Status         string     `db:"status" json:"status"`
+	StartedAt      time.Time  `db:"started_at" json:"startedat"`
+	FinishedAt     *time.Time `db:"finished_at" json:"finishedat"`
+	SymbolsChecked int32      `db:"symbols_checked" json:"symbolschecked"`
+	Stale          int32      `db:"stale" json:"stale"`
+	Synced         int32      `db:"synced" json:"synced"`
+	Fresh          int32      `db:"fresh" json:"fresh"`
+	Failed         int32      `db:"failed" json:"failed"`
+	Error          string     `db:"error" json:"error"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+const (
+	StressKindHistorical = "historical" // replay stored returns between StartDate and EndDate
+	StressKindShock      = "shock"      // apply Shocks, a total return per symbol
+)
+
+type StressScenario struct {
+	Id          int32              `db:"id" json:"id"`
+	Name        string             `db:"name" json:"name"`
+	Description string             `db:"description" json:"description"`
+	Kind        string             `db:"kind" json:"kind"`
+	StartDate   *time.Time         `db:"start_date" json:"startdate"`
+	EndDate     *time.Time         `db:"end_date" json:"enddate"`
+	Shocks      map[string]float64 `db:"shocks" json:"shocks"`
+	CreatedAt   time.Time          `db:"created_at" json:"createdat"`
+	UpdatedAt   time.Time          `db:"updated_at" json:"updatedat"`
+}
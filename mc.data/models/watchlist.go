@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+type Watchlist struct {
+	Id        int32     `db:"id" json:"id"`
+	Name      string    `db:"name" json:"name"`
+	Symbols   []string  `db:"symbols" json:"symbols"`
+	CreatedAt time.Time `db:"created_at" json:"createdat"`
+	UpdatedAt time.Time `db:"updated_at" json:"updatedat"`
+}
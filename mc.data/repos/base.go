@@ -42,6 +42,36 @@ func (pg *Postgres) Close() {
 	pg.db.Close()
 }
 
+// TryAdvisoryLock takes a session level advisory lock without waiting. The lock lives on one pooled
+// connection, which is held until unlock is called. ok is false when another session has the lock.
+func (pg *Postgres) TryAdvisoryLock(ctx context.Context, key int64) (unlock func(), ok bool, err error) {
+	conn, err := pg.db.Acquire(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("error acquiring connection for advisory lock: %w", err)
+	}
+
+	if err := conn.QueryRow(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&ok); err != nil {
+		conn.Release()
+		return nil, false, fmt.Errorf("error taking advisory lock %d: %w", key, err)
+	}
+
+	if !ok {
+		conn.Release()
+		return nil, false, nil
+	}
+
+	unlock = func() {
+		// the caller's context may be done by now, the lock still needs to go
+		if _, err := conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", key); err != nil {
+			// closing the connection drops the session and its locks with it
+			conn.Conn().Close(context.Background())
+		}
+		conn.Release()
+	}
+
+	return unlock, true, nil
+}
+
 func (pg *Postgres) BulkInsert(ctx context.Context, table_name string, columns []string, data [][]any, tx *pgx.Tx) (int64, error) {
 	if tx == nil {
 		return pg.db.CopyFrom(ctx, pgx.Identifier{table_name}, columns, pgx.CopyFromRows(data))
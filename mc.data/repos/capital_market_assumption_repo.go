@@ -0,0 +1,98 @@
+package repos
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	m "mc.data/models"
+)
+
+const capitalMarketAssumptionQuery = `
+	SELECT
+		id,
+		"name",
+		description,
+		asset_classes,
+		correlations,
+		created_at,
+		updated_at
+	FROM capital_market_assumption`
+
+func (pg *Postgres) GetCapitalMarketAssumptions(ctx context.Context) ([]*m.CapitalMarketAssumptions, error) {
+	query := capitalMarketAssumptionQuery + `
+		ORDER BY "name"`
+
+	res, err := Query[m.CapitalMarketAssumptions](ctx, pg, query, pgx.NamedArgs{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get capital market assumptions: %w", err)
+	}
+	return res, nil
+}
+
+// GetCapitalMarketAssumption returns nil when there is no assumption set with the name
+func (pg *Postgres) GetCapitalMarketAssumption(ctx context.Context, name string) (*m.CapitalMarketAssumptions, error) {
+	query := capitalMarketAssumptionQuery + `
+		WHERE "name" = @name`
+
+	res, err := Query[m.CapitalMarketAssumptions](ctx, pg, query, pgx.NamedArgs{"name": name})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get capital market assumptions %s: %w", name, err)
+	}
+
+	if len(res) == 0 {
+		return nil, nil
+	}
+
+	return res[0], nil
+}
+
+// SaveCapitalMarketAssumption creates or replaces the assumption set with the same name
+func (pg *Postgres) SaveCapitalMarketAssumption(ctx context.Context, cma *m.CapitalMarketAssumptions) error {
+	query := `
+		INSERT INTO capital_market_assumption ("name", description, asset_classes, correlations)
+		VALUES (@name, @description, @asset_classes, @correlations)
+		ON CONFLICT ("name") DO UPDATE SET
+			description = EXCLUDED.description,
+			asset_classes = EXCLUDED.asset_classes,
+			correlations = EXCLUDED.correlations,
+			updated_at = CURRENT_TIMESTAMP
+		RETURNING id, created_at, updated_at`
+
+	assetClasses := cma.AssetClasses
+	if assetClasses == nil {
+		assetClasses = map[string]m.AssetClassAssumption{}
+	}
+	correlations := cma.Correlations
+	if correlations == nil {
+		correlations = map[string]map[string]float64{}
+	}
+
+	args := pgx.NamedArgs{
+		"name":          cma.Name,
+		"description":   cma.Description,
+		"asset_classes": assetClasses,
+		"correlations":  correlations,
+	}
+
+	if err := pg.db.QueryRow(ctx, query, args).Scan(&cma.Id, &cma.CreatedAt, &cma.UpdatedAt); err != nil {
+		return fmt.Errorf("error saving capital market assumptions %s: %w", cma.Name, err)
+	}
+
+	return nil
+}
+
+// DeleteCapitalMarketAssumption returns false when there was nothing to delete
+func (pg *Postgres) DeleteCapitalMarketAssumption(ctx context.Context, name string) (bool, error) {
+	query := `
+		DELETE FROM capital_market_assumption
+		WHERE "name" = @name`
+
+	ct, err := pg.db.Exec(ctx, query, pgx.NamedArgs{"name": name})
+	if err != nil {
+		return false, fmt.Errorf("error deleting capital market assumptions %s: %w", name, err)
+	}
+
+	return ct.RowsAffected() > 0, nil
+}
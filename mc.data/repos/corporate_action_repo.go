@@ -0,0 +1,91 @@
+package repos
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	m "mc.data/models"
+)
+
+func (pg *Postgres) GetCorporateActions(ctx context.Context, symbol string) ([]*m.CorporateAction, error) {
+	query := `
+		SELECT
+			aca.source_id,
+			aca.ex_date,
+			aca.action_type,
+			aca.amount,
+			aca.origin
+		FROM av_corporate_action aca
+		JOIN av_time_series_metadata atsm ON aca.source_id = atsm.id
+		WHERE atsm.symbol = @symbol
+		ORDER BY aca.ex_date DESC, aca.action_type`
+
+	args := pgx.NamedArgs{
+		"symbol": symbol,
+	}
+
+	res, err := Query[m.CorporateAction](ctx, pg, query, args)
+	if err != nil {
+		return nil, fmt.Errorf("unable to query corporate actions by symbol (%s): %w", symbol, err)
+	}
+	return res, nil
+}
+
+// UpsertCorporateActions writes actions keyed on (source_id, ex_date, action_type), restated amounts overwrite the stored ones
+func (pg *Postgres) UpsertCorporateActions(ctx context.Context, actions []*m.CorporateAction, id *int32, tx *pgx.Tx) (int64, error) {
+	if len(actions) == 0 {
+		return 0, nil
+	}
+
+	sourceIds := make([]int32, len(actions))
+	exDates := make([]time.Time, len(actions))
+	actionTypes := make([]string, len(actions))
+	amounts := make([]float64, len(actions))
+	origins := make([]string, len(actions))
+	for i, a := range actions {
+		sourceIds[i] = a.SourceId
+		if id != nil {
+			sourceIds[i] = *id
+		}
+		exDates[i] = a.ExDate
+		actionTypes[i] = a.ActionType
+		amounts[i] = a.Amount
+		origins[i] = a.Origin
+	}
+
+	query := `
+		INSERT INTO av_corporate_action AS aca
+			(source_id, ex_date, action_type, amount, origin)
+		SELECT * FROM UNNEST(@source_ids::INTEGER[], @ex_dates::DATE[], @action_types::VARCHAR[], @amounts::NUMERIC[], @origins::VARCHAR[])
+		ON CONFLICT (source_id, ex_date, action_type) DO UPDATE SET
+			amount = EXCLUDED.amount,
+			origin = EXCLUDED.origin,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE (aca.amount, aca.origin) IS DISTINCT FROM (EXCLUDED.amount, EXCLUDED.origin)`
+
+	args := pgx.NamedArgs{
+		"source_ids":   sourceIds,
+		"ex_dates":     exDates,
+		"action_types": actionTypes,
+		"amounts":      amounts,
+		"origins":      origins,
+	}
+
+	var err error
+	var ct pgconn.CommandTag
+	if tx == nil {
+		ct, err = pg.db.Exec(ctx, query, args)
+	} else {
+		ct, err = (*tx).Exec(ctx, query, args)
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("error upserting corporate actions: %w", err)
+	}
+
+	return ct.RowsAffected(), nil
+}
@@ -0,0 +1,88 @@
+package repos
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	m "mc.data/models"
+)
+
+func (pg *Postgres) GetRefreshRuns(ctx context.Context, limit int) ([]*m.RefreshRun, error) {
+	query := `
+		SELECT
+			id,
+			"trigger",
+			status,
+			started_at,
+			finished_at,
+			symbols_checked,
+			stale,
+			synced,
+			fresh,
+			failed,
+			error
+		FROM refresh_run
+		ORDER BY started_at DESC
+		LIMIT @limit`
+
+	res, err := Query[m.RefreshRun](ctx, pg, query, pgx.NamedArgs{"limit": limit})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get refresh runs: %w", err)
+	}
+	return res, nil
+}
+
+// InsertRefreshRun records the start of a run, setting its id and started at
+func (pg *Postgres) InsertRefreshRun(ctx context.Context, run *m.RefreshRun) error {
+	query := `
+		INSERT INTO refresh_run
+			("trigger", status)
+		VALUES
+			(@trigger, @status)
+		RETURNING id, started_at`
+
+	args := pgx.NamedArgs{
+		"trigger": run.Trigger,
+		"status":  run.Status,
+	}
+
+	if err := pg.db.QueryRow(ctx, query, args).Scan(&run.Id, &run.StartedAt); err != nil {
+		return fmt.Errorf("error inserting refresh run: %w", err)
+	}
+	return nil
+}
+
+// FinishRefreshRun stores the outcome of a run, setting its finished at
+func (pg *Postgres) FinishRefreshRun(ctx context.Context, run *m.RefreshRun) error {
+	query := `
+		UPDATE refresh_run
+		SET
+			status = @status,
+			finished_at = CURRENT_TIMESTAMP,
+			symbols_checked = @symbols_checked,
+			stale = @stale,
+			synced = @synced,
+			fresh = @fresh,
+			failed = @failed,
+			error = @error
+		WHERE id = @id
+		RETURNING finished_at`
+
+	args := pgx.NamedArgs{
+		"id":              run.Id,
+		"status":          run.Status,
+		"symbols_checked": run.SymbolsChecked,
+		"stale":           run.Stale,
+		"synced":          run.Synced,
+		"fresh":           run.Fresh,
+		"failed":          run.Failed,
+		"error":           run.Error,
+	}
+
+	if err := pg.db.QueryRow(ctx, query, args).Scan(&run.FinishedAt); err != nil {
+		return fmt.Errorf("error finishing refresh run %d: %w", run.Id, err)
+	}
+	return nil
+}
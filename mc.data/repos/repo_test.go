@@ -29,6 +29,7 @@ func Test_TimeSeriesMetaDataRepo_CanCRUD(t *testing.T) {
 	testMetaData := m.TimeSeriesMetadata{
 		Symbol:        symbol,
 		LastRefreshed: time.Date(2025, time.October, 31, 0, 0, 0, 0, time.UTC),
+		Provider:      "_testprovider",
 	}
 
 	ctx := context.Background()
@@ -65,6 +66,9 @@ func Test_TimeSeriesMetaDataRepo_CanCRUD(t *testing.T) {
 	if testMetaData.LastRefreshed != res.LastRefreshed {
 		t.Fatalf("last refreshed time did not match, inserted %s, got back %s", ex.FmtLong(testMetaData.LastRefreshed), ex.FmtLong(res.LastRefreshed))
 	}
+	if testMetaData.Provider != res.Provider {
+		t.Fatalf("providers did not match, inserted %s, got back %s", testMetaData.Provider, res.Provider)
+	}
 
 	newLR := time.Date(2025, time.October, 31, 0, 0, 0, 0, time.UTC)
 
@@ -79,6 +83,17 @@ func Test_TimeSeriesMetaDataRepo_CanCRUD(t *testing.T) {
 	if newLR != newRes.LastRefreshed {
 		t.Fatalf("error updating meta data last refreshed date, expected %s, got %s", ex.FmtLong(newLR), ex.FmtLong(newRes.LastRefreshed))
 	}
+
+	if err := pg.UpdateProvider(ctx, symbol, "_otherprovider", nil); err != nil {
+		t.Fatalf("error updating provider: %s", err)
+	}
+
+	providerRes, err := pg.GetMetaDataBySymbol(ctx, symbol)
+	if err != nil {
+		t.Fatalf("error getting updated meta data for symbol %s", symbol)
+	}
+	ex.AssertAreEqual(t, "provider", "_otherprovider", providerRes.Provider)
+	ex.AssertAreEqual(t, "frequency defaults to weekly", m.FrequencyWeekly, providerRes.Frequency)
 }
 
 func Test_TimeSeriesDataRepo_CanInsertAndGet(t *testing.T) {
@@ -143,6 +158,270 @@ func Test_TimeSeriesDataRepo_CanInsertAndGet(t *testing.T) {
 	compareTimeSeriesData(t, testTimeSeriesData[0], ts[1])
 }
 
+func Test_TimeSeriesDataRepo_CanUpsert(t *testing.T) {
+	symbol := "_TEST3"
+
+	testMetaData := m.TimeSeriesMetadata{
+		Symbol:        symbol,
+		LastRefreshed: time.Date(2025, time.October, 31, 0, 0, 0, 0, time.UTC),
+		Provider:      "_testprovider",
+	}
+
+	ctx := context.Background()
+	pg := getConnection(t, ctx)
+
+	if err := pg.InsertNewMetaData(ctx, &testMetaData, nil); err != nil {
+		t.Fatalf("error inserting new meta data: %s", err)
+	}
+
+	defer pg.deleteTestTimeSeriesData(t, ctx, testMetaData.Id)
+
+	bar := func(day int, close float64) *m.TimeSeriesData {
+		return &m.TimeSeriesData{
+			SourceId:        testMetaData.Id,
+			Timestamp:       time.Date(2025, time.October, day, 0, 0, 0, 0, time.UTC),
+			TimeSeriesOHLCV: m.TimeSeriesOHLCV{Open: close, High: close, Low: close, Close: close, Volume: 100},
+			AdjustedClose:   close,
+		}
+	}
+
+	ct, err := pg.InsertTimeSeriesData(ctx, []*m.TimeSeriesData{bar(24, 100), bar(31, 101)}, nil, nil)
+	if err != nil {
+		t.Fatalf("error inserting time series data: %s", err)
+	}
+	ex.AssertAreEqual(t, "inserted", int64(2), ct)
+
+	// one unchanged, one revised and one new bar
+	ct, err = pg.UpsertTimeSeriesData(ctx, []*m.TimeSeriesData{bar(24, 100), bar(31, 99), bar(17, 98)}, nil, nil)
+	if err != nil {
+		t.Fatalf("error upserting time series data: %s", err)
+	}
+	ex.AssertAreEqual(t, "upserted", int64(2), ct)
+
+	ts, err := pg.GetTimeSeriesData(ctx, symbol)
+	if err != nil {
+		t.Fatalf("error getting time series data by symbol: %s", err)
+	}
+
+	ex.AssertAreEqual(t, "row count", 3, len(ts))
+	compareTimeSeriesData(t, bar(31, 99), ts[0])
+	compareTimeSeriesData(t, bar(24, 100), ts[1])
+	compareTimeSeriesData(t, bar(17, 98), ts[2])
+}
+
+func Test_TimeSeriesRevisionRepo_CanInsertAndGet(t *testing.T) {
+	symbol := "_TEST4"
+
+	testMetaData := m.TimeSeriesMetadata{
+		Symbol:        symbol,
+		LastRefreshed: time.Date(2025, time.October, 31, 0, 0, 0, 0, time.UTC),
+		Provider:      "_testprovider",
+	}
+
+	ctx := context.Background()
+	pg := getConnection(t, ctx)
+
+	if err := pg.InsertNewMetaData(ctx, &testMetaData, nil); err != nil {
+		t.Fatalf("error inserting new meta data: %s", err)
+	}
+
+	// revisions cascade with the meta data row
+	defer pg.deleteTestTimeSeriesData(t, ctx, testMetaData.Id)
+
+	revisions := []*m.TimeSeriesRevision{
+		{SourceId: testMetaData.Id, Timestamp: time.Date(2025, time.October, 24, 0, 0, 0, 0, time.UTC), Field: "adjusted_close", PreviousValue: 100, NewValue: 99.5, Provider: "_testprovider"},
+		{SourceId: testMetaData.Id, Timestamp: time.Date(2025, time.October, 31, 0, 0, 0, 0, time.UTC), Field: "close", PreviousValue: 101, NewValue: 101.25, Provider: "_testprovider"},
+	}
+
+	ct, err := pg.InsertTimeSeriesRevisions(ctx, revisions, nil)
+	if err != nil {
+		t.Fatalf("error inserting revisions: %s", err)
+	}
+	ex.AssertAreEqual(t, "inserted", int64(2), ct)
+
+	res, err := pg.GetTimeSeriesRevisions(ctx, symbol)
+	if err != nil {
+		t.Fatalf("error getting revisions: %s", err)
+	}
+
+	ex.AssertAreEqual(t, "row count", 2, len(res))
+	ex.AssertAreEqual(t, "latest date first", "close", res[0].Field)
+	ex.AssertAreEqual(t, "new value", 101.25, res[0].NewValue)
+	ex.AssertAreEqual(t, "previous value", 100.0, res[1].PreviousValue)
+}
+
+func Test_CorporateActionRepo_CanUpsertAndGet(t *testing.T) {
+	symbol := "_TEST5"
+
+	testMetaData := m.TimeSeriesMetadata{
+		Symbol:        symbol,
+		LastRefreshed: time.Date(2025, time.October, 31, 0, 0, 0, 0, time.UTC),
+		Provider:      "_testprovider",
+	}
+
+	ctx := context.Background()
+	pg := getConnection(t, ctx)
+
+	if err := pg.InsertNewMetaData(ctx, &testMetaData, nil); err != nil {
+		t.Fatalf("error inserting new meta data: %s", err)
+	}
+
+	defer pg.deleteTestTimeSeriesData(t, ctx, testMetaData.Id)
+
+	dividend := &m.CorporateAction{ExDate: time.Date(2025, time.August, 8, 0, 0, 0, 0, time.UTC), ActionType: m.CorporateActionDividend, Amount: 0.26, Origin: "_testprovider"}
+	split := &m.CorporateAction{ExDate: time.Date(2025, time.June, 10, 0, 0, 0, 0, time.UTC), ActionType: m.CorporateActionSplit, Amount: 4, Origin: m.CorporateActionInferred}
+
+	ct, err := pg.UpsertCorporateActions(ctx, []*m.CorporateAction{dividend, split}, &testMetaData.Id, nil)
+	if err != nil {
+		t.Fatalf("error upserting corporate actions: %s", err)
+	}
+	ex.AssertAreEqual(t, "inserted", int64(2), ct)
+
+	// the same dividend again is a no op, a restated split overwrites
+	split.Amount, split.Origin = 2, "_testprovider"
+	ct, err = pg.UpsertCorporateActions(ctx, []*m.CorporateAction{dividend, split}, &testMetaData.Id, nil)
+	if err != nil {
+		t.Fatalf("error upserting corporate actions: %s", err)
+	}
+	ex.AssertAreEqual(t, "updated", int64(1), ct)
+
+	res, err := pg.GetCorporateActions(ctx, symbol)
+	if err != nil {
+		t.Fatalf("error getting corporate actions: %s", err)
+	}
+
+	ex.AssertAreEqual(t, "row count", 2, len(res))
+	ex.AssertAreEqual(t, "latest first", m.CorporateActionDividend, res[0].ActionType)
+	ex.AssertAreEqual(t, "split amount", 2.0, res[1].Amount)
+	ex.AssertAreEqual(t, "split origin", "_testprovider", res[1].Origin)
+}
+
+func Test_RefreshRunRepo_CanInsertFinishAndGet(t *testing.T) {
+	ctx := context.Background()
+	pg := getConnection(t, ctx)
+
+	run := m.RefreshRun{Trigger: "_test", Status: m.RefreshStatusRunning}
+	if err := pg.InsertRefreshRun(ctx, &run); err != nil {
+		t.Fatalf("error inserting refresh run: %s", err)
+	}
+	defer pg.db.Exec(ctx, "DELETE FROM refresh_run WHERE id = @id", pgx.NamedArgs{"id": run.Id})
+
+	run.Status, run.SymbolsChecked, run.Stale, run.Synced = m.RefreshStatusCompleted, 3, 2, 2
+	if err := pg.FinishRefreshRun(ctx, &run); err != nil {
+		t.Fatalf("error finishing refresh run: %s", err)
+	}
+	if run.FinishedAt == nil {
+		t.Fatalf("expected finished at to be set")
+	}
+
+	runs, err := pg.GetRefreshRuns(ctx, 1)
+	if err != nil {
+		t.Fatalf("error getting refresh runs: %s", err)
+	}
+	ex.AssertAreEqual(t, "latest run", run.Id, runs[0].Id)
+	ex.AssertAreEqual(t, "status", m.RefreshStatusCompleted, runs[0].Status)
+	ex.AssertAreEqual(t, "synced", int32(2), runs[0].Synced)
+}
+
+func Test_StressScenarioRepo_CanSaveGetAndDelete(t *testing.T) {
+	ctx := context.Background()
+	pg := getConnection(t, ctx)
+	name := "_test_scenario"
+
+	scenario := m.StressScenario{
+		Name:   name,
+		Kind:   m.StressKindShock,
+		Shocks: map[string]float64{"_TEST": -0.3},
+	}
+	if err := pg.SaveStressScenario(ctx, &scenario); err != nil {
+		t.Fatalf("error saving stress scenario: %s", err)
+	}
+	defer pg.DeleteStressScenario(ctx, name)
+
+	res, err := pg.GetStressScenario(ctx, name)
+	if err != nil {
+		t.Fatalf("error getting stress scenario: %s", err)
+	}
+	ex.AssertAreEqual(t, "id", scenario.Id, res.Id)
+	ex.AssertAreEqual(t, "shock", -0.3, res.Shocks["_TEST"])
+	ex.AssertAreEqual(t, "no window", true, res.StartDate == nil)
+
+	// the predefined windows are seeded with the tables
+	gfc, err := pg.GetStressScenario(ctx, "gfc_2008")
+	if err != nil || gfc == nil {
+		t.Fatalf("expected the seeded gfc scenario: %v", err)
+	}
+	ex.AssertAreEqual(t, "gfc kind", m.StressKindHistorical, gfc.Kind)
+
+	deleted, err := pg.DeleteStressScenario(ctx, name)
+	if err != nil {
+		t.Fatalf("error deleting stress scenario: %s", err)
+	}
+	ex.AssertAreEqual(t, "deleted", true, deleted)
+}
+
+func Test_CapitalMarketAssumptionRepo_CanSaveGetAndDelete(t *testing.T) {
+	ctx := context.Background()
+	pg := getConnection(t, ctx)
+	name := "_test_cma"
+
+	cma := m.CapitalMarketAssumptions{
+		Name: name,
+		AssetClasses: map[string]m.AssetClassAssumption{
+			"equity": {ExpectedReturn: 0.07, Volatility: 0.16},
+			"bonds":  {ExpectedReturn: 0.04, Volatility: 0.06},
+		},
+		Correlations: map[string]map[string]float64{"equity": {"bonds": 0.1}},
+	}
+	if err := pg.SaveCapitalMarketAssumption(ctx, &cma); err != nil {
+		t.Fatalf("error saving capital market assumptions: %s", err)
+	}
+	defer pg.DeleteCapitalMarketAssumption(ctx, name)
+
+	res, err := pg.GetCapitalMarketAssumption(ctx, name)
+	if err != nil {
+		t.Fatalf("error getting capital market assumptions: %s", err)
+	}
+	ex.AssertAreEqual(t, "id", cma.Id, res.Id)
+	ex.AssertAreEqual(t, "equity return", 0.07, res.AssetClasses["equity"].ExpectedReturn)
+	ex.AssertAreEqual(t, "correlation", 0.1, res.Correlations["equity"]["bonds"])
+
+	deleted, err := pg.DeleteCapitalMarketAssumption(ctx, name)
+	if err != nil {
+		t.Fatalf("error deleting capital market assumptions: %s", err)
+	}
+	ex.AssertAreEqual(t, "deleted", true, deleted)
+
+	missing, err := pg.GetCapitalMarketAssumption(ctx, name)
+	if err != nil {
+		t.Fatalf("error getting capital market assumptions: %s", err)
+	}
+	ex.AssertAreEqual(t, "gone", true, missing == nil)
+}
+
+func Test_Base_AdvisoryLockIsExclusive(t *testing.T) {
+	ctx := context.Background()
+	pg := getConnection(t, ctx)
+	key := int64(-42)
+
+	unlock, ok, err := pg.TryAdvisoryLock(ctx, key)
+	if err != nil || !ok {
+		t.Fatalf("expected to take the advisory lock, ok %v, err %v", ok, err)
+	}
+
+	if _, ok, err := pg.TryAdvisoryLock(ctx, key); err != nil || ok {
+		t.Fatalf("expected the second attempt to be refused, ok %v, err %v", ok, err)
+	}
+
+	unlock()
+
+	unlock, ok, err = pg.TryAdvisoryLock(ctx, key)
+	if err != nil || !ok {
+		t.Fatalf("expected to take the advisory lock after unlocking, ok %v, err %v", ok, err)
+	}
+	unlock()
+}
+
 func compareTimeSeriesData(t *testing.T, expected, actual *m.TimeSeriesData) {
 	t.Helper()
 	if expected.Timestamp.Before(actual.Timestamp) {
@@ -0,0 +1,100 @@
+package repos
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	m "mc.data/models"
+)
+
+const stressScenarioQuery = `
+	SELECT
+		id,
+		"name",
+		description,
+		kind,
+		start_date,
+		end_date,
+		shocks,
+		created_at,
+		updated_at
+	FROM stress_scenario`
+
+func (pg *Postgres) GetStressScenarios(ctx context.Context) ([]*m.StressScenario, error) {
+	query := stressScenarioQuery + `
+		ORDER BY "name"`
+
+	res, err := Query[m.StressScenario](ctx, pg, query, pgx.NamedArgs{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get stress scenarios: %w", err)
+	}
+	return res, nil
+}
+
+// GetStressScenario returns nil when there is no scenario with the name
+func (pg *Postgres) GetStressScenario(ctx context.Context, name string) (*m.StressScenario, error) {
+	query := stressScenarioQuery + `
+		WHERE "name" = @name`
+
+	res, err := Query[m.StressScenario](ctx, pg, query, pgx.NamedArgs{"name": name})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get stress scenario %s: %w", name, err)
+	}
+
+	if len(res) == 0 {
+		return nil, nil
+	}
+
+	return res[0], nil
+}
+
+// SaveStressScenario creates or replaces the scenario with the same name
+func (pg *Postgres) SaveStressScenario(ctx context.Context, scenario *m.StressScenario) error {
+	query := `
+		INSERT INTO stress_scenario ("name", description, kind, start_date, end_date, shocks)
+		VALUES (@name, @description, @kind, @start_date, @end_date, @shocks)
+		ON CONFLICT ("name") DO UPDATE SET
+			description = EXCLUDED.description,
+			kind = EXCLUDED.kind,
+			start_date = EXCLUDED.start_date,
+			end_date = EXCLUDED.end_date,
+			shocks = EXCLUDED.shocks,
+			updated_at = CURRENT_TIMESTAMP
+		RETURNING id, created_at, updated_at`
+
+	shocks := scenario.Shocks
+	if shocks == nil {
+		shocks = map[string]float64{}
+	}
+
+	args := pgx.NamedArgs{
+		"name":        scenario.Name,
+		"description": scenario.Description,
+		"kind":        scenario.Kind,
+		"start_date":  scenario.StartDate,
+		"end_date":    scenario.EndDate,
+		"shocks":      shocks,
+	}
+
+	if err := pg.db.QueryRow(ctx, query, args).Scan(&scenario.Id, &scenario.CreatedAt, &scenario.UpdatedAt); err != nil {
+		return fmt.Errorf("error saving stress scenario %s: %w", scenario.Name, err)
+	}
+
+	return nil
+}
+
+// DeleteStressScenario returns false when there was nothing to delete
+func (pg *Postgres) DeleteStressScenario(ctx context.Context, name string) (bool, error) {
+	query := `
+		DELETE FROM stress_scenario
+		WHERE "name" = @name`
+
+	ct, err := pg.db.Exec(ctx, query, pgx.NamedArgs{"name": name})
+	if err != nil {
+		return false, fmt.Errorf("error deleting stress scenario %s: %w", name, err)
+	}
+
+	return ct.RowsAffected() > 0, nil
+}
@@ -39,12 +39,108 @@ func (pg *Postgres) GetTimeSeriesData(ctx context.Context, symbol string) ([]*m.
 	return res, nil
 }
 
+// GetTimeSeriesDataBetween returns the bars of every source id from start through end inclusive,
+// ordered by source id and then timestamp ascending
+func (pg *Postgres) GetTimeSeriesDataBetween(ctx context.Context, sourceIds []int32, start, end time.Time) ([]*m.TimeSeriesData, error) {
+	query := `
+		SELECT 
+			source_id,
+			"timestamp", 
+			"open", 
+			high, 
+			low, 
+			"close", 
+			volume, 
+			adjusted_close, 
+			dividend_amount
+		FROM av_time_series_data
+		WHERE source_id = ANY(@source_ids)
+			AND "timestamp" BETWEEN @start AND @end
+		ORDER BY source_id, "timestamp"`
+
+	args := pgx.NamedArgs{
+		"source_ids": sourceIds,
+		"start":      start,
+		"end":        end,
+	}
+
+	res, err := Query[m.TimeSeriesData](ctx, pg, query, args)
+	if err != nil {
+		return nil, fmt.Errorf("unable to query data by source id (%v) between %s and %s: %w", sourceIds, start.Format(time.DateOnly), end.Format(time.DateOnly), err)
+	}
+	return res, nil
+}
+
+var timeSeriesDataColumns = []string{
+	"source_id", "timestamp", "open", "high", "low",
+	"close", "volume", "adjusted_close", "dividend_amount",
+}
+
 func (pg *Postgres) InsertTimeSeriesData(ctx context.Context, data []*m.TimeSeriesData, id *int32, tx *pgx.Tx) (int64, error) {
-	columns := []string{
-		"source_id", "timestamp", "open", "high", "low",
-		"close", "volume", "adjusted_close", "dividend_amount",
+	return pg.BulkInsert(ctx, "av_time_series_data", timeSeriesDataColumns, timeSeriesDataRows(data, id), tx)
+}
+
+// UpsertTimeSeriesData inserts new bars and overwrites existing ones that differ. CopyFrom cannot upsert,
+// so the rows are copied into a temp staging table and merged with INSERT ... ON CONFLICT.
+// Returns the number of rows inserted or changed, unchanged rows are left alone.
+func (pg *Postgres) UpsertTimeSeriesData(ctx context.Context, data []*m.TimeSeriesData, id *int32, tx *pgx.Tx) (int64, error) {
+	// the staging table only lives for the transaction, so we need one
+	if tx == nil {
+		ownTx, err := pg.GetTransaction(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("error beginning upsert transaction: %w", err)
+		}
+		defer ownTx.Rollback(ctx)
+
+		ct, err := pg.UpsertTimeSeriesData(ctx, data, id, &ownTx)
+		if err != nil {
+			return 0, err
+		}
+
+		return ct, ownTx.Commit(ctx)
+	}
+
+	staging := `
+		CREATE TEMP TABLE IF NOT EXISTS av_time_series_data_staging
+			(LIKE av_time_series_data INCLUDING DEFAULTS)
+			ON COMMIT DROP;
+		TRUNCATE av_time_series_data_staging;`
+
+	if _, err := (*tx).Exec(ctx, staging); err != nil {
+		return 0, fmt.Errorf("error creating time series staging table: %w", err)
+	}
+
+	if _, err := pg.BulkInsert(ctx, "av_time_series_data_staging", timeSeriesDataColumns, timeSeriesDataRows(data, id), tx); err != nil {
+		return 0, fmt.Errorf("error copying into time series staging table: %w", err)
 	}
 
+	merge := `
+		INSERT INTO av_time_series_data AS atsd
+			(source_id, "timestamp", "open", high, low, "close", volume, adjusted_close, dividend_amount)
+		SELECT
+			source_id, "timestamp", "open", high, low, "close", volume, adjusted_close, dividend_amount
+		FROM av_time_series_data_staging
+		ON CONFLICT (source_id, "timestamp") DO UPDATE SET
+			"open" = EXCLUDED."open",
+			high = EXCLUDED.high,
+			low = EXCLUDED.low,
+			"close" = EXCLUDED."close",
+			volume = EXCLUDED.volume,
+			adjusted_close = EXCLUDED.adjusted_close,
+			dividend_amount = EXCLUDED.dividend_amount
+		WHERE (atsd."open", atsd.high, atsd.low, atsd."close", atsd.volume, atsd.adjusted_close, atsd.dividend_amount)
+			IS DISTINCT FROM
+			(EXCLUDED."open", EXCLUDED.high, EXCLUDED.low, EXCLUDED."close", EXCLUDED.volume, EXCLUDED.adjusted_close, EXCLUDED.dividend_amount)`
+
+	ct, err := (*tx).Exec(ctx, merge)
+	if err != nil {
+		return 0, fmt.Errorf("error merging time series staging table: %w", err)
+	}
+
+	return ct.RowsAffected(), nil
+}
+
+func timeSeriesDataRows(data []*m.TimeSeriesData, id *int32) [][]any {
 	// multiply by -1 to sort the data in descending order
 	slices.SortFunc(data, func(i, j *m.TimeSeriesData) int {
 		return -1 * i.Timestamp.Compare(j.Timestamp)
@@ -63,11 +159,7 @@ func (pg *Postgres) InsertTimeSeriesData(ctx context.Context, data []*m.TimeSeri
 		}
 	}
 
-	if tx == nil {
-		return pg.db.CopyFrom(ctx, pgx.Identifier{"av_time_series_data"}, columns, pgx.CopyFromRows(entries))
-	}
-
-	return (*tx).CopyFrom(ctx, pgx.Identifier{"av_time_series_data"}, columns, pgx.CopyFromRows(entries))
+	return entries
 }
 
 func (pg *Postgres) GetMostRecentTimestampForSymbol(ctx context.Context, symbol string) (*time.Time, error) {
@@ -14,7 +14,9 @@ func (pg *Postgres) GetMetaDataBySymbol(ctx context.Context, symbol string) (*m.
 		SELECT 
 			id, 
 			symbol, 
-			last_refreshed
+			last_refreshed,
+			provider,
+			frequency
 		FROM av_time_series_metadata 
 		WHERE symbol = @symbol`
 
@@ -34,23 +36,68 @@ func (pg *Postgres) GetMetaDataBySymbol(ctx context.Context, symbol string) (*m.
 	return res[0], nil
 }
 
+// GetMetaDataById returns nil when there is no series with the id
+func (pg *Postgres) GetMetaDataById(ctx context.Context, id int32) (*m.TimeSeriesMetadata, error) {
+	query := `
+		SELECT 
+			id, 
+			symbol, 
+			last_refreshed,
+			provider,
+			frequency
+		FROM av_time_series_metadata 
+		WHERE id = @id`
+
+	res, err := Query[m.TimeSeriesMetadata](ctx, pg, query, pgx.NamedArgs{"id": id})
+	if err != nil {
+		return nil, fmt.Errorf("unable to query metadata by id (%d): %w", id, err)
+	}
+
+	if len(res) == 0 {
+		return nil, nil
+	}
+
+	return res[0], nil
+}
+
+func (pg *Postgres) GetAllMetaData(ctx context.Context) ([]*m.TimeSeriesMetadata, error) {
+	query := `
+		SELECT 
+			id, 
+			symbol, 
+			last_refreshed,
+			provider,
+			frequency
+		FROM av_time_series_metadata 
+		ORDER BY symbol`
+
+	res, err := Query[m.TimeSeriesMetadata](ctx, pg, query, pgx.NamedArgs{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to query all metadata: %w", err)
+	}
+
+	return res, nil
+}
+
 func (pg *Postgres) InsertNewMetaData(ctx context.Context, metadata *m.TimeSeriesMetadata, tx *pgx.Tx) (err error) {
 	query := `
 		INSERT INTO av_time_series_metadata 
-			(symbol, last_refreshed) 
+			(symbol, last_refreshed, provider, frequency) 
 		VALUES 
-			(@symbol, @last_refreshed) 
-		RETURNING id`
+			(@symbol, @last_refreshed, @provider, COALESCE(NULLIF(@frequency, ''), 'weekly')) 
+		RETURNING id, frequency`
 
 	args := pgx.NamedArgs{
 		"symbol":         metadata.Symbol,
 		"last_refreshed": metadata.LastRefreshed,
+		"provider":       metadata.Provider,
+		"frequency":      metadata.Frequency,
 	}
 
 	if tx == nil {
-		err = pg.db.QueryRow(ctx, query, args).Scan(&metadata.Id)
+		err = pg.db.QueryRow(ctx, query, args).Scan(&metadata.Id, &metadata.Frequency)
 	} else {
-		err = (*tx).QueryRow(ctx, query, args).Scan(&metadata.Id)
+		err = (*tx).QueryRow(ctx, query, args).Scan(&metadata.Id, &metadata.Frequency)
 	}
 
 	if err != nil {
@@ -79,3 +126,23 @@ func (pg *Postgres) UpdateLastRefreshedDate(ctx context.Context, symbol string,
 
 	return
 }
+
+func (pg *Postgres) UpdateProvider(ctx context.Context, symbol string, provider string, tx *pgx.Tx) (err error) {
+	query := `
+		UPDATE av_time_series_metadata
+		SET provider = @provider
+		WHERE symbol = @symbol`
+
+	args := pgx.NamedArgs{
+		"provider": provider,
+		"symbol":   symbol,
+	}
+
+	if tx == nil {
+		_, err = pg.db.Exec(ctx, query, args)
+	} else {
+		_, err = (*tx).Exec(ctx, query, args)
+	}
+
+	return
+}
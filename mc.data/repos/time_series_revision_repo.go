@@ -0,0 +1,48 @@
+package repos
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	m "mc.data/models"
+)
+
+func (pg *Postgres) GetTimeSeriesRevisions(ctx context.Context, symbol string) ([]*m.TimeSeriesRevision, error) {
+	query := `
+		SELECT
+			atsr.source_id,
+			atsr."timestamp",
+			atsr.field,
+			atsr.previous_value,
+			atsr.new_value,
+			atsr.provider,
+			atsr.revised_at
+		FROM av_time_series_revision atsr
+		JOIN av_time_series_metadata atsm ON atsr.source_id = atsm.id
+		WHERE atsm.symbol = @symbol
+		ORDER BY atsr.revised_at DESC, atsr."timestamp" DESC, atsr.field`
+
+	args := pgx.NamedArgs{
+		"symbol": symbol,
+	}
+
+	res, err := Query[m.TimeSeriesRevision](ctx, pg, query, args)
+	if err != nil {
+		return nil, fmt.Errorf("unable to query revisions by symbol (%s): %w", symbol, err)
+	}
+	return res, nil
+}
+
+// InsertTimeSeriesRevisions bulk loads revision rows, revised_at is left to the column default
+func (pg *Postgres) InsertTimeSeriesRevisions(ctx context.Context, revisions []*m.TimeSeriesRevision, tx *pgx.Tx) (int64, error) {
+	columns := []string{"source_id", "timestamp", "field", "previous_value", "new_value", "provider"}
+
+	entries := make([][]any, len(revisions))
+	for i, rev := range revisions {
+		entries[i] = []any{rev.SourceId, rev.Timestamp, rev.Field, rev.PreviousValue, rev.NewValue, rev.Provider}
+	}
+
+	return pg.BulkInsert(ctx, "av_time_series_revision", columns, entries, tx)
+}
@@ -0,0 +1,114 @@
+package repos
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	m "mc.data/models"
+)
+
+const watchlistQuery = `
+	SELECT
+		w.id,
+		w."name",
+		COALESCE(ARRAY_AGG(ws.symbol ORDER BY ws.symbol) FILTER (WHERE ws.symbol IS NOT NULL), '{}') AS symbols,
+		w.created_at,
+		w.updated_at
+	FROM watchlist w
+	LEFT JOIN watchlist_symbol ws ON ws.watchlist_id = w.id`
+
+func (pg *Postgres) GetWatchlists(ctx context.Context) ([]*m.Watchlist, error) {
+	query := watchlistQuery + `
+		GROUP BY w.id
+		ORDER BY w."name"`
+
+	res, err := Query[m.Watchlist](ctx, pg, query, pgx.NamedArgs{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get watchlists: %w", err)
+	}
+	return res, nil
+}
+
+// GetWatchlist returns nil when there is no watchlist with the name
+func (pg *Postgres) GetWatchlist(ctx context.Context, name string) (*m.Watchlist, error) {
+	query := watchlistQuery + `
+		WHERE w."name" = @name
+		GROUP BY w.id`
+
+	args := pgx.NamedArgs{
+		"name": name,
+	}
+
+	res, err := Query[m.Watchlist](ctx, pg, query, args)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get watchlist %s: %w", name, err)
+	}
+
+	if len(res) == 0 {
+		return nil, nil
+	}
+
+	return res[0], nil
+}
+
+// SaveWatchlist creates the watchlist if needed and replaces its symbols
+func (pg *Postgres) SaveWatchlist(ctx context.Context, name string, symbols []string, tx *pgx.Tx) error {
+	if tx == nil {
+		ownTx, err := pg.GetTransaction(ctx)
+		if err != nil {
+			return fmt.Errorf("error beginning watchlist transaction: %w", err)
+		}
+		defer ownTx.Rollback(ctx)
+
+		if err := pg.SaveWatchlist(ctx, name, symbols, &ownTx); err != nil {
+			return err
+		}
+
+		return ownTx.Commit(ctx)
+	}
+
+	query := `
+		INSERT INTO watchlist ("name")
+		VALUES (@name)
+		ON CONFLICT ("name") DO UPDATE SET updated_at = CURRENT_TIMESTAMP
+		RETURNING id`
+
+	var id int32
+	if err := (*tx).QueryRow(ctx, query, pgx.NamedArgs{"name": name}).Scan(&id); err != nil {
+		return fmt.Errorf("error saving watchlist %s: %w", name, err)
+	}
+
+	replace := `
+		DELETE FROM watchlist_symbol WHERE watchlist_id = @id;`
+
+	if _, err := (*tx).Exec(ctx, replace, pgx.NamedArgs{"id": id}); err != nil {
+		return fmt.Errorf("error clearing symbols for watchlist %s: %w", name, err)
+	}
+
+	insert := `
+		INSERT INTO watchlist_symbol (watchlist_id, symbol)
+		SELECT @id, UNNEST(@symbols::VARCHAR[])
+		ON CONFLICT DO NOTHING`
+
+	if _, err := (*tx).Exec(ctx, insert, pgx.NamedArgs{"id": id, "symbols": symbols}); err != nil {
+		return fmt.Errorf("error saving symbols for watchlist %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// DeleteWatchlist returns false when there was nothing to delete
+func (pg *Postgres) DeleteWatchlist(ctx context.Context, name string) (bool, error) {
+	query := `
+		DELETE FROM watchlist
+		WHERE "name" = @name`
+
+	ct, err := pg.db.Exec(ctx, query, pgx.NamedArgs{"name": name})
+	if err != nil {
+		return false, fmt.Errorf("error deleting watchlist %s: %w", name, err)
+	}
+
+	return ct.RowsAffected() > 0, nil
+}
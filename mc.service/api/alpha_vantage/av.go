@@ -2,6 +2,7 @@ package alpha_vantage
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -20,14 +21,26 @@ import (
 
 // public
 const (
-	HostDefault = "www.alphavantage.co"
+	HostDefault  = "www.alphavantage.co"
+	ProviderName = "alphavantage"
+
+	// FreeTierCallsPerMinute is the quota of a free api key, the default when no limit is configured
+	FreeTierCallsPerMinute = 5
+)
+
+// DataType is the response format requested from alpha vantage
+type DataType string
+
+const (
+	DataTypeJSON DataType = "json"
+	DataTypeCSV  DataType = "csv" // smaller payloads, handy for full history pulls
 )
 
 // private
 const (
 	// default query parameters
 	defaultOutputSize = "Compact"
-	defaultDataType   = "JSON"
+	defaultDataType   = DataTypeJSON
 	defaultTimeout    = time.Second * 30
 
 	// api request elements
@@ -35,6 +48,14 @@ const (
 	symbol   = "symbol"
 	function = "function"
 	interval = "interval"
+	datatype = "datatype"
+)
+
+var (
+	// ErrApiError is returned when alpha vantage responds with an "Error Message" payload
+	ErrApiError = errors.New("alpha vantage returned an error")
+	// ErrThrottled is returned when alpha vantage responds with a rate limit notice instead of data
+	ErrThrottled = fmt.Errorf("alpha vantage request was throttled: %w", a.ErrThrottled)
 )
 
 var (
@@ -49,23 +70,56 @@ var (
 
 type AlphaVantageClient struct {
 	*a.Client
+	DataType DataType // format used when serving as a MarketDataProvider, defaults to json
 }
 
 func GetClient(apiKey string) AlphaVantageClient {
+	return GetClientForHost(HostDefault, apiKey)
+}
+
+// GetClientForHost points the client somewhere other than alpha vantage, e.g. http://localhost:8081 for the fakeav server
+func GetClientForHost(host string, apiKey string) AlphaVantageClient {
+	if host == "" {
+		host = HostDefault
+	}
+
+	return AlphaVantageClient{
+		Client: a.ClientFactory(host, apiKey, defaultTimeout),
+	}
+}
+
+// NewClient builds a client on top of an existing connection, e.g. a recording or replaying one for tests
+func NewClient(connection a.Connection, apiKey string) AlphaVantageClient {
 	return AlphaVantageClient{
-		a.ClientFactory(HostDefault, apiKey, defaultTimeout),
+		Client: &a.Client{
+			Connection: connection,
+			ApiKey:     apiKey,
+		},
 	}
 }
 
 // https://www.alphavantage.co/documentation/#weeklyadj
-func (avc *AlphaVantageClient) GetStockWeeklyAdjustedMetrics(ticker string) (*m.TimeSeriesResult, error) {
+// an empty dataType falls back to json
+func (avc *AlphaVantageClient) GetStockWeeklyAdjustedMetrics(ticker string, dataType DataType) (*m.TimeSeriesResult, error) {
+	return avc.getAdjustedMetrics(ticker, dataType, "TIME_SERIES_WEEKLY_ADJUSTED", "Weekly Adjusted Time Series")
+}
+
+// https://www.alphavantage.co/documentation/#dailyadj
+// unlike weekly, daily bars carry split coefficients. an empty dataType falls back to json
+func (avc *AlphaVantageClient) GetStockDailyAdjustedMetrics(ticker string, dataType DataType) (*m.TimeSeriesResult, error) {
+	return avc.getAdjustedMetrics(ticker, dataType, "TIME_SERIES_DAILY_ADJUSTED", "Time Series (Daily)")
+}
+
+func (avc *AlphaVantageClient) getAdjustedMetrics(ticker string, dataType DataType, fn string, seriesKey string) (*m.TimeSeriesResult, error) {
 	if avc == nil {
 		panic("alpha vantage client has not been set.")
 	}
 
+	dataType = resolveDataType(dataType)
 	endpoint := avc.buildRequestPath(map[string]string{
-		function: "TIME_SERIES_WEEKLY_ADJUSTED",
+		function: fn,
 		symbol:   ticker,
+		datatype: string(dataType),
 	})
 
 	response, err := avc.Client.Connection.Request(endpoint)
@@ -75,6 +129,10 @@ func (avc *AlphaVantageClient) GetStockWeeklyAdjustedMetrics(ticker string) (*m.
 
 	defer response.Body.Close()
 
+	if dataType == DataTypeCSV {
+		return parseTimeSeriesCsvResult(response.Body, ticker)
+	}
+
 	raw, err := parseRawJson(response.Body)
 	if err != nil {
 		return nil, err
@@ -85,7 +143,7 @@ func (avc *AlphaVantageClient) GetStockWeeklyAdjustedMetrics(ticker string) (*m.
 		return nil, err
 	}
 
-	timeSeriesData, err := parseTimeSeriesDataResult(raw, "Weekly Adjusted Time Series", timeZone)
+	timeSeriesData, err := parseTimeSeriesDataResult(raw, seriesKey, timeZone)
 	if err != nil {
 		return nil, err
 	}
@@ -94,15 +152,17 @@ func (avc *AlphaVantageClient) GetStockWeeklyAdjustedMetrics(ticker string) (*m.
 		Metadata:   metaData,
 		TimeSeries: timeSeriesData,
 	}, nil
-
 }
 
 // StockTimeSeriesIntraday queries a stock symbols statistics throughout the day.
-func (avc *AlphaVantageClient) GetStockIntradayMetrics(ticker string) (*m.TimeSeriesIntradayResult, error) {
+// an empty dataType falls back to json
+func (avc *AlphaVantageClient) GetStockIntradayMetrics(ticker string, dataType DataType) (*m.TimeSeriesIntradayResult, error) {
+	dataType = resolveDataType(dataType)
 	endpoint := avc.buildRequestPath(map[string]string{
 		function: "TIME_SERIES_INTRADAY",
 		interval: "5min",
 		symbol:   ticker,
+		datatype: string(dataType),
 	})
 
 	response, err := avc.Client.Connection.Request(endpoint)
@@ -112,6 +172,10 @@ func (avc *AlphaVantageClient) GetStockIntradayMetrics(ticker string) (*m.TimeSe
 
 	defer response.Body.Close()
 
+	if dataType == DataTypeCSV {
+		return parseTimeSeriesIntradayCsvResult(response.Body, ticker)
+	}
+
 	raw, err := parseRawJson(response.Body)
 	if err != nil {
 		return nil, err
@@ -141,7 +205,7 @@ func (avc *AlphaVantageClient) buildRequestPath(params map[string]string) *url.U
 	// base parameters
 	query := endpoint.Query()
 	query.Set("apikey", avc.Client.ApiKey)
-	query.Set("datatype", defaultDataType)
+	query.Set(datatype, string(defaultDataType))
 	query.Set("outputsize", defaultOutputSize)
 
 	// additional parameters
@@ -154,6 +218,13 @@ func (avc *AlphaVantageClient) buildRequestPath(params map[string]string) *url.U
 	return endpoint
 }
 
+func resolveDataType(dataType DataType) DataType {
+	if dataType == "" {
+		return defaultDataType
+	}
+	return DataType(strings.ToLower(string(dataType)))
+}
+
 func parseRawJson(reader io.Reader) (raw map[string]json.RawMessage, err error) {
 	body, err := io.ReadAll(reader)
 	if err != nil {
@@ -165,9 +236,39 @@ func parseRawJson(reader io.Reader) (raw map[string]json.RawMessage, err error)
 		return nil, fmt.Errorf("error unmarshaling response: %w", err)
 	}
 
+	if err := parseResponseNotice(raw); err != nil {
+		return nil, err
+	}
+
 	return
 }
 
+// parseResponseNotice checks for the payloads av sends back with a 200 in place of data
+func parseResponseNotice(raw map[string]json.RawMessage) error {
+	var message string
+	if v, ok := raw["Error Message"]; ok {
+		json.Unmarshal(v, &message)
+		return fmt.Errorf("%w: %s", ErrApiError, message)
+	}
+
+	if v, ok := raw["Note"]; ok {
+		json.Unmarshal(v, &message)
+		return fmt.Errorf("%w: %s", ErrThrottled, message)
+	}
+
+	// newer rate limit responses come back as "Information", which is also used for premium endpoint notices
+	if v, ok := raw["Information"]; ok {
+		json.Unmarshal(v, &message)
+		lower := strings.ToLower(message)
+		if strings.Contains(lower, "rate limit") || strings.Contains(lower, "call frequency") {
+			return fmt.Errorf("%w: %s", ErrThrottled, message)
+		}
+		return fmt.Errorf("%w: %s", ErrApiError, message)
+	}
+
+	return nil
+}
+
 func parseMetaData(raw map[string]json.RawMessage) (*m.TimeSeriesMetadata, *time.Location, error) {
 	var metadataElements map[string]string
 	if err := json.Unmarshal(raw["Meta Data"], &metadataElements); err != nil {
@@ -247,6 +348,10 @@ func parseTimeSeriesDataResult(raw map[string]json.RawMessage, key string, locat
 		return nil, fmt.Errorf("error extracting dividend amount key for time series")
 	}
 
+	// split coefficient is only on the daily series
+	scf := func(s string) bool { return strings.HasSuffix(s, ". split coefficient") }
+	splitCoefficientKey := e.FilterFirst(slices.Collect(maps.Keys(firstValue)), scf)
+
 	timeSeries := make([]*m.TimeSeriesData, 0, len(timeSeriesElements))
 	for timeSeriesKey, timeSeriesValue := range timeSeriesElements {
 		// get timestamp
@@ -262,10 +367,11 @@ func parseTimeSeriesDataResult(raw map[string]json.RawMessage, key string, locat
 		}
 
 		timeSeries = append(timeSeries, &m.TimeSeriesData{
-			Timestamp:       timestamp,
-			TimeSeriesOHLCV: ohlcv,
-			AdjustedClose:   parseFloat(timeSeriesValue[adjustedCloseKey]),
-			DividendAmount:  parseFloat(timeSeriesValue[dividendAmountKey]),
+			Timestamp:        timestamp,
+			TimeSeriesOHLCV:  ohlcv,
+			AdjustedClose:    parseFloat(timeSeriesValue[adjustedCloseKey]),
+			DividendAmount:   parseFloat(timeSeriesValue[dividendAmountKey]),
+			SplitCoefficient: parseFloat(timeSeriesValue[splitCoefficientKey]),
 		})
 	}
 
@@ -0,0 +1,177 @@
+package alpha_vantage
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	m "mc.data/models"
+)
+
+const (
+	// csv responses carry no meta data, av documents all equity series in eastern time
+	csvTimeZone = "US/Eastern"
+
+	csvTimestamp        = "timestamp"
+	csvAdjustedClose    = "adjusted close"
+	csvDividendAmount   = "dividend amount"
+	csvSplitCoefficient = "split coefficient" // daily only
+)
+
+var (
+	// normalized csv header -> TimeSeriesOHLCV field, weekly uses "adjusted close" where daily uses "adjusted_close"
+	csvOhlcvKeys = map[string]string{
+		"open":   "Open",
+		"high":   "High",
+		"low":    "Low",
+		"close":  "Close",
+		"volume": "Volume",
+	}
+)
+
+// parseTimeSeriesCsvResult builds the same result as the json path. symbol and last refreshed
+// are not in the payload, so they come from the request and the latest row respectively
+func parseTimeSeriesCsvResult(reader io.Reader, ticker string) (*m.TimeSeriesResult, error) {
+	rows, location, err := parseRawCsv(reader, csvAdjustedClose, csvDividendAmount)
+	if err != nil {
+		return nil, err
+	}
+
+	timeSeries := make([]*m.TimeSeriesData, 0, len(rows))
+	for _, row := range rows {
+		timestamp, ohlcv, err := parseCsvBar(row, location)
+		if err != nil {
+			return nil, err
+		}
+
+		timeSeries = append(timeSeries, &m.TimeSeriesData{
+			Timestamp:        timestamp,
+			TimeSeriesOHLCV:  ohlcv,
+			AdjustedClose:    parseFloat(row[csvAdjustedClose]),
+			DividendAmount:   parseFloat(row[csvDividendAmount]),
+			SplitCoefficient: parseFloat(row[csvSplitCoefficient]),
+		})
+	}
+
+	return &m.TimeSeriesResult{
+		Metadata:   csvMetaData(ticker, timeSeries, func(v *m.TimeSeriesData) time.Time { return v.Timestamp }),
+		TimeSeries: timeSeries,
+	}, nil
+}
+
+func parseTimeSeriesIntradayCsvResult(reader io.Reader, ticker string) (*m.TimeSeriesIntradayResult, error) {
+	rows, location, err := parseRawCsv(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	timeSeries := make([]*m.TimeSeriesIntradayData, 0, len(rows))
+	for _, row := range rows {
+		timestamp, ohlcv, err := parseCsvBar(row, location)
+		if err != nil {
+			return nil, err
+		}
+
+		timeSeries = append(timeSeries, &m.TimeSeriesIntradayData{
+			Timestamp:       timestamp,
+			TimeSeriesOHLCV: ohlcv,
+		})
+	}
+
+	return &m.TimeSeriesIntradayResult{
+		Metadata:   csvMetaData(ticker, timeSeries, func(v *m.TimeSeriesIntradayData) time.Time { return v.Timestamp }),
+		TimeSeries: timeSeries,
+	}, nil
+}
+
+// parseRawCsv reads the payload into one map per row keyed on the normalized header.
+// av still answers errors and throttling with json when csv is requested, so those are checked first
+func parseRawCsv(reader io.Reader, requiredColumns ...string) ([]map[string]string, *time.Location, error) {
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading response body: %w", err)
+	}
+
+	trimmed := bytes.TrimSpace(body)
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		if _, err := parseRawJson(bytes.NewReader(trimmed)); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, fmt.Errorf("error parsing csv response, received json without an error notice")
+	}
+
+	records, err := csv.NewReader(bytes.NewReader(trimmed)).ReadAll()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading csv response: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, nil, fmt.Errorf("error reading csv response, no header row")
+	}
+
+	header := make([]string, len(records[0]))
+	for i, h := range records[0] {
+		header[i] = normalizeCsvHeader(h)
+	}
+
+	required := append([]string{csvTimestamp}, requiredColumns...)
+	for k := range csvOhlcvKeys {
+		required = append(required, k)
+	}
+	for _, r := range required {
+		if !slices.Contains(header, r) {
+			return nil, nil, fmt.Errorf("error reading csv response, missing column %s. Available headers: %v", r, header)
+		}
+	}
+
+	rows := make([]map[string]string, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]string, len(header))
+		for i, v := range record {
+			row[header[i]] = v
+		}
+		rows = append(rows, row)
+	}
+
+	location, err := getTimeZone(csvTimeZone)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return rows, location, nil
+}
+
+func parseCsvBar(row map[string]string, location *time.Location) (time.Time, m.TimeSeriesOHLCV, error) {
+	timestamp, err := parseDate(row[csvTimestamp], location)
+	if err != nil {
+		return time.Time{}, m.TimeSeriesOHLCV{}, fmt.Errorf("error converting TIMESTAMP from string to time.Time: %w", err)
+	}
+
+	ohlcv, err := parseOHLCV(row, csvOhlcvKeys)
+	if err != nil {
+		return time.Time{}, m.TimeSeriesOHLCV{}, fmt.Errorf("error parsing OHLCV: %w", err)
+	}
+
+	return timestamp, ohlcv, nil
+}
+
+func csvMetaData[T any](ticker string, series []*T, timestamp func(*T) time.Time) *m.TimeSeriesMetadata {
+	var lastRefreshed time.Time
+	for _, v := range series {
+		if ts := timestamp(v); ts.After(lastRefreshed) {
+			lastRefreshed = ts
+		}
+	}
+
+	return &m.TimeSeriesMetadata{
+		Symbol:        ticker,
+		LastRefreshed: lastRefreshed,
+	}
+}
+
+func normalizeCsvHeader(h string) string {
+	return strings.ToLower(strings.TrimSpace(strings.ReplaceAll(h, "_", " ")))
+}
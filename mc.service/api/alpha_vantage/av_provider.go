@@ -0,0 +1,119 @@
+package alpha_vantage
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+
+	e "mc.data/extensions"
+	m "mc.data/models"
+	a "mc.service/api"
+)
+
+var _ a.MarketDataProvider = (*AlphaVantageClient)(nil)
+
+func (avc *AlphaVantageClient) Name() string {
+	return ProviderName
+}
+
+func (avc *AlphaVantageClient) GetHistoricalBars(ticker string) (*m.TimeSeriesResult, error) {
+	return avc.GetStockWeeklyAdjustedMetrics(ticker, avc.DataType)
+}
+
+func (avc *AlphaVantageClient) GetIntradayBars(ticker string) (*m.TimeSeriesIntradayResult, error) {
+	return avc.GetStockIntradayMetrics(ticker, avc.DataType)
+}
+
+// https://www.alphavantage.co/documentation/#symbolsearch
+func (avc *AlphaVantageClient) SearchSymbols(keywords string) ([]*m.SymbolMatch, error) {
+	endpoint := avc.buildRequestPath(map[string]string{
+		function:   "SYMBOL_SEARCH",
+		"keywords": keywords,
+	})
+
+	raw, err := avc.requestRawJson(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []map[string]string
+	if err := json.Unmarshal(raw["bestMatches"], &matches); err != nil {
+		return nil, fmt.Errorf("error unmarshaling symbol search matches: %w", err)
+	}
+
+	res := make([]*m.SymbolMatch, 0, len(matches))
+	for _, match := range matches {
+		res = append(res, &m.SymbolMatch{
+			Symbol:     getSuffixedValue(match, ". symbol"),
+			Name:       getSuffixedValue(match, ". name"),
+			Type:       getSuffixedValue(match, ". type"),
+			Region:     getSuffixedValue(match, ". region"),
+			Currency:   getSuffixedValue(match, ". currency"),
+			MatchScore: parseFloat(getSuffixedValue(match, ". matchScore")),
+		})
+	}
+
+	return res, nil
+}
+
+// https://www.alphavantage.co/documentation/#company-overview
+func (avc *AlphaVantageClient) GetSymbolMetadata(ticker string) (*m.SymbolMetadata, error) {
+	endpoint := avc.buildRequestPath(map[string]string{
+		function: "OVERVIEW",
+		symbol:   ticker,
+	})
+
+	raw, err := avc.requestRawJson(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	// av answers unknown symbols (and most etfs) with an empty object
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("%w: no overview available for %s", ErrApiError, ticker)
+	}
+
+	// every overview value is a string, numbers included
+	overview := make(map[string]string, len(raw))
+	for k, v := range raw {
+		var value string
+		if err := json.Unmarshal(v, &value); err == nil {
+			overview[k] = value
+		}
+	}
+
+	return &m.SymbolMetadata{
+		Symbol:      overview["Symbol"],
+		Name:        overview["Name"],
+		AssetType:   overview["AssetType"],
+		Exchange:    overview["Exchange"],
+		Currency:    overview["Currency"],
+		Country:     overview["Country"],
+		Sector:      overview["Sector"],
+		Industry:    overview["Industry"],
+		Description: overview["Description"],
+	}, nil
+}
+
+func (avc *AlphaVantageClient) requestRawJson(endpoint *url.URL) (map[string]json.RawMessage, error) {
+	response, err := avc.Client.Connection.Request(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+
+	return parseRawJson(response.Body)
+}
+
+func getSuffixedValue(values map[string]string, suffix string) string {
+	f := func(s string) bool { return strings.HasSuffix(s, suffix) }
+	key, err := e.FilterSingle(slices.Collect(maps.Keys(values)), f)
+	if err != nil {
+		return ""
+	}
+	return values[key]
+}
@@ -2,7 +2,9 @@ package alpha_vantage
 
 import (
 	"encoding/json"
+	"errors"
 	"os"
+	"slices"
 	"testing"
 	"time"
 
@@ -10,10 +12,13 @@ import (
 
 	e "mc.data/extensions"
 	m "mc.data/models"
+	a "mc.service/api"
 )
 
 const (
-	avKeyName = "ALPHAVANTAGE_API_KEY"
+	avKeyName       = "ALPHAVANTAGE_API_KEY"
+	avRecordKeyName = "AV_RECORD_FIXTURES"
+	fixtureDir      = "testdata"
 )
 
 func Test_AlphaVantage_GetApiKey(t *testing.T) {
@@ -37,21 +42,39 @@ func getApiKey(t *testing.T) string {
 	t.Helper()
 	err := godotenv.Load("../../.env")
 	if err != nil {
-		t.Errorf("error loading environment: %s", err)
+		t.Skipf("skipping live alpha vantage test, environment not loaded: %s", err)
 	}
 
 	return os.Getenv(avKeyName)
 }
 
+// getLiveClient hits the real api, setting AV_RECORD_FIXTURES=1 will write the responses to testdata
+func getLiveClient(t *testing.T) AlphaVantageClient {
+	t.Helper()
+	c := GetClient(getApiKey(t))
+	if os.Getenv(avRecordKeyName) != "" {
+		c.Client.Connection = &a.RecordingConnection{
+			Connection: c.Client.Connection,
+			Dir:        fixtureDir,
+		}
+	}
+	return c
+}
+
+// getReplayClient serves the golden responses in testdata, no network or api key needed
+func getReplayClient(t *testing.T) AlphaVantageClient {
+	t.Helper()
+	return NewClient(&a.ReplayConnection{Dir: fixtureDir}, "replay")
+}
+
 func Test_AlphaVantage_StockIntradayTimeSeries(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping test that utilizes alpha vantage api queries")
 	}
 
 	ticker := "AAPL"
-	apiKey := getApiKey(t)
-	c := GetClient(apiKey)
-	res, err := c.GetStockIntradayMetrics(ticker)
+	c := getLiveClient(t)
+	res, err := c.GetStockIntradayMetrics(ticker, DataTypeJSON)
 
 	if err != nil {
 		t.Fatalf("error getting stock time series: %s", err)
@@ -105,9 +128,8 @@ func Test_AlphaVantage_StockTimeSeries(t *testing.T) {
 	}
 
 	ticker := "AAPL"
-	apiKey := getApiKey(t)
-	c := GetClient(apiKey)
-	res, err := c.GetStockWeeklyAdjustedMetrics(ticker)
+	c := getLiveClient(t)
+	res, err := c.GetStockWeeklyAdjustedMetrics(ticker, DataTypeJSON)
 
 	if err != nil {
 		t.Fatalf("error getting stock time series: %s", err)
@@ -128,7 +150,120 @@ func Test_AlphaVantage_StockTimeSeries(t *testing.T) {
 		t.Fatalf("error parsing meta data last refreshed date, %s", res.Metadata.LastRefreshed)
 	}
 
-	// time series element tieout
+	assertWeeklyTieOut(t, res, targetDate)
+}
+
+func Test_AlphaVantage_Replay_StockTimeSeries(t *testing.T) {
+	ticker := "AAPL"
+	c := getReplayClient(t)
+	res, err := c.GetStockWeeklyAdjustedMetrics(ticker, DataTypeJSON)
+
+	if err != nil {
+		t.Fatalf("error getting stock time series: %s", err)
+	}
+
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("error parsing time zone: %s", err)
+	}
+
+	if res.Metadata.Symbol != ticker {
+		t.Fatalf("metadata symbol did not match expected value %s != %s", res.Metadata.Symbol, ticker)
+	}
+
+	targetDate := time.Date(2025, time.October, 31, 0, 0, 0, 0, location)
+	if !targetDate.Equal(res.Metadata.LastRefreshed) {
+		t.Fatalf("last refreshed mismatch, expected %s, got %s", e.FmtLong(targetDate), e.FmtLong(res.Metadata.LastRefreshed))
+	}
+
+	e.AssertAreEqual(t, "time series length", 15, len(res.TimeSeries))
+	assertWeeklyTieOut(t, res, targetDate)
+
+	// the dividend week should carry its payout through
+	dividendDate := time.Date(2025, time.August, 15, 0, 0, 0, 0, location)
+	f := func(tsd *m.TimeSeriesData) bool { return dividendDate.Equal(tsd.Timestamp) }
+	d, err := e.FilterSingle(res.TimeSeries, f)
+	if err != nil {
+		t.Fatalf("error filtering dividend week: %v", err)
+	}
+	e.AssertAreEqual(t, "dividend amount", 0.26, d.DividendAmount)
+}
+
+func Test_AlphaVantage_Replay_StockIntradayTimeSeries(t *testing.T) {
+	ticker := "AAPL"
+	c := getReplayClient(t)
+	res, err := c.GetStockIntradayMetrics(ticker, DataTypeJSON)
+
+	if err != nil {
+		t.Fatalf("error getting stock intraday time series: %s", err)
+	}
+
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("error parsing time zone: %s", err)
+	}
+
+	lastRefreshed := time.Date(2025, time.October, 31, 19, 55, 0, 0, location)
+	if !lastRefreshed.Equal(res.Metadata.LastRefreshed) {
+		t.Fatalf("last refreshed mismatch, expected %s, got %s", e.FmtLong(lastRefreshed), e.FmtLong(res.Metadata.LastRefreshed))
+	}
+
+	e.AssertAreEqual(t, "time series length", 24, len(res.TimeSeries))
+
+	slices.SortFunc(res.TimeSeries, func(i, j *m.TimeSeriesIntradayData) int {
+		return -1 * i.Timestamp.Compare(j.Timestamp)
+	})
+
+	s := res.TimeSeries[0]
+	if !lastRefreshed.Equal(s.Timestamp) {
+		t.Fatalf("latest bar mismatch, expected %s, got %s", e.FmtLong(lastRefreshed), e.FmtLong(s.Timestamp))
+	}
+	e.AssertAreEqual(t, "open", 270.1, s.Open)
+	e.AssertAreEqual(t, "volume", 6247.0, s.Volume)
+
+	for _, bar := range res.TimeSeries {
+		if bar.Low > bar.High || bar.Close == 0 || bar.Volume == 0 {
+			t.Fatalf("bad bar parsed at %s: %+v", e.FmtLong(bar.Timestamp), bar.TimeSeriesOHLCV)
+		}
+	}
+}
+
+func Test_AlphaVantage_Replay_ErrorMessage(t *testing.T) {
+	c := getReplayClient(t)
+	res, err := c.GetStockWeeklyAdjustedMetrics("INVALID", DataTypeJSON)
+
+	if !errors.Is(err, ErrApiError) {
+		t.Fatalf("expected ErrApiError, got %v", err)
+	}
+	if res != nil {
+		t.Fatalf("expected no result for an error payload")
+	}
+}
+
+func Test_AlphaVantage_Replay_Throttled(t *testing.T) {
+	c := getReplayClient(t)
+
+	// daily limit notices come back under "Information"
+	if _, err := c.GetStockWeeklyAdjustedMetrics("THROTTLED", DataTypeJSON); !errors.Is(err, ErrThrottled) {
+		t.Fatalf("expected ErrThrottled for information payload, got %v", err)
+	}
+
+	// per minute limit notices come back under "Note"
+	if _, err := c.GetStockIntradayMetrics("THROTTLED", DataTypeJSON); !errors.Is(err, ErrThrottled) {
+		t.Fatalf("expected ErrThrottled for note payload, got %v", err)
+	}
+}
+
+func Test_AlphaVantage_Replay_MissingFixture(t *testing.T) {
+	c := getReplayClient(t)
+	if _, err := c.GetStockWeeklyAdjustedMetrics("NOFIXTURE", DataTypeJSON); err == nil {
+		t.Fatalf("expected an error when no fixture has been recorded")
+	}
+}
+
+func assertWeeklyTieOut(t *testing.T, res *m.TimeSeriesResult, targetDate time.Time) {
+	t.Helper()
+
 	f := func(tsd *m.TimeSeriesData) bool { return targetDate.Equal(tsd.Timestamp) }
 	s, err := e.FilterSingle(res.TimeSeries, f)
 	if err != nil {
@@ -181,3 +316,76 @@ func Test_AlphaVantage_StockTimeSeries(t *testing.T) {
 		t.Fatalf("dividend amount mismatch, expected %v, got %v", expected.DividendAmount, s.DividendAmount)
 	}
 }
+
+func Test_AlphaVantage_Replay_CsvMatchesJson(t *testing.T) {
+	c := getReplayClient(t)
+
+	jsonRes, err := c.GetStockWeeklyAdjustedMetrics("AAPL", DataTypeJSON)
+	if err != nil {
+		t.Fatalf("error getting json weekly series: %s", err)
+	}
+
+	csvRes, err := c.GetStockWeeklyAdjustedMetrics("AAPL", DataTypeCSV)
+	if err != nil {
+		t.Fatalf("error getting csv weekly series: %s", err)
+	}
+
+	AssertTimeSeriesResultsEqual(t, jsonRes, csvRes)
+}
+
+func Test_AlphaVantage_Replay_IntradayCsvMatchesJson(t *testing.T) {
+	c := getReplayClient(t)
+
+	jsonRes, err := c.GetStockIntradayMetrics("AAPL", DataTypeJSON)
+	if err != nil {
+		t.Fatalf("error getting json intraday series: %s", err)
+	}
+
+	csvRes, err := c.GetStockIntradayMetrics("AAPL", DataTypeCSV)
+	if err != nil {
+		t.Fatalf("error getting csv intraday series: %s", err)
+	}
+
+	AssertTimeSeriesIntradayResultsEqual(t, jsonRes, csvRes)
+}
+
+func Test_AlphaVantage_Replay_CsvThrottled(t *testing.T) {
+	c := getReplayClient(t)
+
+	// av answers throttling in json even when csv was requested
+	if _, err := c.GetStockWeeklyAdjustedMetrics("THROTTLED", DataTypeCSV); !errors.Is(err, ErrThrottled) {
+		t.Fatalf("expected ErrThrottled for a json notice on a csv request, got %v", err)
+	}
+}
+
+func Test_AlphaVantage_Replay_SymbolSearch(t *testing.T) {
+	c := getReplayClient(t)
+	res, err := c.SearchSymbols("apple")
+	if err != nil {
+		t.Fatalf("error searching symbols: %s", err)
+	}
+
+	e.AssertAreEqual(t, "match count", 2, len(res))
+	e.AssertAreEqual(t, "symbol", "AAPL", res[0].Symbol)
+	e.AssertAreEqual(t, "name", "Apple Inc", res[0].Name)
+	e.AssertAreEqual(t, "currency", "USD", res[0].Currency)
+	e.AssertAreEqual(t, "match score", 0.8889, res[0].MatchScore)
+}
+
+func Test_AlphaVantage_Replay_SymbolMetadata(t *testing.T) {
+	c := getReplayClient(t)
+	res, err := c.GetSymbolMetadata("AAPL")
+	if err != nil {
+		t.Fatalf("error getting symbol metadata: %s", err)
+	}
+
+	e.AssertAreEqual(t, "name", "Apple Inc", res.Name)
+	e.AssertAreEqual(t, "asset type", "Common Stock", res.AssetType)
+	e.AssertAreEqual(t, "exchange", "NASDAQ", res.Exchange)
+	e.AssertAreEqual(t, "sector", "TECHNOLOGY", res.Sector)
+
+	// etfs come back as an empty object
+	if _, err := c.GetSymbolMetadata("SPY"); !errors.Is(err, ErrApiError) {
+		t.Fatalf("expected ErrApiError for an empty overview, got %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package alpha_vantage
+
+import (
+	"slices"
+	"testing"
+
+	e "mc.data/extensions"
+	m "mc.data/models"
+)
+
+// AssertTimeSeriesResultsEqual checks two results hold the same bars regardless of order, e.g. json vs csv
+func AssertTimeSeriesResultsEqual(t *testing.T, expected, actual *m.TimeSeriesResult) {
+	t.Helper()
+	assertMetadataEqual(t, expected.Metadata, actual.Metadata)
+	e.AssertAreEqual(t, "time series length", len(expected.TimeSeries), len(actual.TimeSeries))
+
+	sortDesc := func(i, j *m.TimeSeriesData) int { return -1 * i.Timestamp.Compare(j.Timestamp) }
+	ex, ac := slices.Clone(expected.TimeSeries), slices.Clone(actual.TimeSeries)
+	slices.SortFunc(ex, sortDesc)
+	slices.SortFunc(ac, sortDesc)
+
+	for i := range ex {
+		if !ex[i].Timestamp.Equal(ac[i].Timestamp) {
+			t.Fatalf("timestamp mismatch at %d, expected %s, got %s", i, e.FmtLong(ex[i].Timestamp), e.FmtLong(ac[i].Timestamp))
+		}
+		e.AssertAreEqual(t, "ohlcv "+e.FmtShort(ex[i].Timestamp), ex[i].TimeSeriesOHLCV, ac[i].TimeSeriesOHLCV)
+		e.AssertAreEqual(t, "adjusted close "+e.FmtShort(ex[i].Timestamp), ex[i].AdjustedClose, ac[i].AdjustedClose)
+		e.AssertAreEqual(t, "dividend amount "+e.FmtShort(ex[i].Timestamp), ex[i].DividendAmount, ac[i].DividendAmount)
+		e.AssertAreEqual(t, "split coefficient "+e.FmtShort(ex[i].Timestamp), ex[i].SplitCoefficient, ac[i].SplitCoefficient)
+	}
+}
+
+// AssertTimeSeriesIntradayResultsEqual checks two intraday results hold the same bars regardless of order
+func AssertTimeSeriesIntradayResultsEqual(t *testing.T, expected, actual *m.TimeSeriesIntradayResult) {
+	t.Helper()
+	assertMetadataEqual(t, expected.Metadata, actual.Metadata)
+	e.AssertAreEqual(t, "time series length", len(expected.TimeSeries), len(actual.TimeSeries))
+
+	sortDesc := func(i, j *m.TimeSeriesIntradayData) int { return -1 * i.Timestamp.Compare(j.Timestamp) }
+	ex, ac := slices.Clone(expected.TimeSeries), slices.Clone(actual.TimeSeries)
+	slices.SortFunc(ex, sortDesc)
+	slices.SortFunc(ac, sortDesc)
+
+	for i := range ex {
+		if !ex[i].Timestamp.Equal(ac[i].Timestamp) {
+			t.Fatalf("timestamp mismatch at %d, expected %s, got %s", i, e.FmtLong(ex[i].Timestamp), e.FmtLong(ac[i].Timestamp))
+		}
+		e.AssertAreEqual(t, "ohlcv "+e.FmtLong(ex[i].Timestamp), ex[i].TimeSeriesOHLCV, ac[i].TimeSeriesOHLCV)
+	}
+}
+
+func assertMetadataEqual(t *testing.T, expected, actual *m.TimeSeriesMetadata) {
+	t.Helper()
+	e.AssertAreEqual(t, "symbol", expected.Symbol, actual.Symbol)
+	if !expected.LastRefreshed.Equal(actual.LastRefreshed) {
+		t.Fatalf("last refreshed mismatch, expected %s, got %s", e.FmtLong(expected.LastRefreshed), e.FmtLong(actual.LastRefreshed))
+	}
+}
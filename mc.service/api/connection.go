@@ -3,15 +3,21 @@ package api
 import (
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 )
 
+const (
+	defaultScheme = "https"
+)
+
 type Connection interface {
 	Request(endpoint *url.URL) (*http.Response, error)
 }
 
 type ClientHost struct {
 	Client *http.Client
+	Scheme string
 	Host   string
 }
 
@@ -21,19 +27,26 @@ type Client struct {
 }
 
 func (conn *ClientHost) Request(endpoint *url.URL) (*http.Response, error) {
-	endpoint.Scheme = "https"
+	endpoint.Scheme = conn.Scheme
+	if endpoint.Scheme == "" {
+		endpoint.Scheme = defaultScheme
+	}
 	endpoint.Host = conn.Host
 	targetUrl := endpoint.String()
 	return conn.Client.Get(targetUrl)
 }
 
+// ClientFactory builds a client for a bare host (www.alphavantage.co, https is assumed)
+// or a full base url, e.g. http://localhost:8081 when pointing at a local stand in
 func ClientFactory(host string, apiKey string, timeout time.Duration) *Client {
 	client := &http.Client{
 		Timeout: timeout,
 	}
 
+	scheme, host := splitHost(host)
 	clientHost := &ClientHost{
 		Client: client,
+		Scheme: scheme,
 		Host:   host,
 	}
 
@@ -42,3 +55,16 @@ func ClientFactory(host string, apiKey string, timeout time.Duration) *Client {
 		ApiKey:     apiKey,
 	}
 }
+
+func splitHost(host string) (string, string) {
+	if !strings.Contains(host, "://") {
+		return defaultScheme, host
+	}
+
+	u, err := url.Parse(host)
+	if err != nil || u.Host == "" {
+		return defaultScheme, host
+	}
+
+	return u.Scheme, u.Host
+}
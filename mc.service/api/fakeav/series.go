@@ -0,0 +1,212 @@
+package fakeav
+
+import (
+	"hash/fnv"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"time"
+)
+
+const (
+	tradingDaysPerYear = 252
+	intradayOpenHour   = 4  // av intraday includes extended hours, 4:00 to 20:00 eastern
+	intradayCloseHour  = 20 // ^^
+)
+
+// SymbolParameters are the GBM inputs used to generate a symbol's synthetic history
+type SymbolParameters struct {
+	Name          string  `json:"name"`          // optional, served by SYMBOL_SEARCH and OVERVIEW
+	Mu            float64 `json:"mu"`            // annualized drift
+	Sigma         float64 `json:"sigma"`         // annualized volatility
+	StartPrice    float64 `json:"startprice"`    // price on the first generated day
+	DividendYield float64 `json:"dividendyield"` // annual yield, paid quarterly. zero for no dividends
+	// optional splits, ex date (YYYY-MM-DD) -> coefficient, e.g. 4 for a 4:1 split. raw prices drop by the coefficient on the ex date
+	Splits map[string]float64 `json:"splits"`
+}
+
+// DefaultSymbolParameters are used for any symbol that is not explicitly configured
+var DefaultSymbolParameters = SymbolParameters{
+	Mu:         0.07,
+	Sigma:      0.20,
+	StartPrice: 100,
+}
+
+type bar struct {
+	timestamp        time.Time
+	open             float64
+	high             float64
+	low              float64
+	close            float64
+	adjustedClose    float64
+	volume           float64
+	dividendAmount   float64
+	splitCoefficient float64
+}
+
+// generateDaily builds the daily bars for a symbol from origin through asOf (inclusive, weekdays only).
+// The path is always generated from the origin, so adding days never changes earlier bars.
+func generateDaily(symbol string, params SymbolParameters, seed uint64, origin, asOf time.Time) []*bar {
+	rng := rand.New(rand.NewPCG(seed, symbolSeed(symbol)))
+
+	dt := 1.0 / tradingDaysPerYear
+	drift := (params.Mu - 0.5*params.Sigma*params.Sigma) * dt
+	diffusion := params.Sigma * math.Sqrt(dt)
+
+	bars := []*bar{}
+	prevClose := params.StartPrice
+	for day := origin; !day.After(asOf); day = day.AddDate(0, 0, 1) {
+		if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			continue
+		}
+
+		split := 1.0
+		if k, ok := params.Splits[day.Format(time.DateOnly)]; ok && k > 0 {
+			split = k
+			prevClose /= k
+		}
+
+		// overnight gap, then the day's move
+		open := prevClose * math.Exp(0.1*diffusion*rng.NormFloat64())
+		closePrice := prevClose * math.Exp(drift+diffusion*rng.NormFloat64())
+		high := math.Max(open, closePrice) * math.Exp(0.5*diffusion*math.Abs(rng.NormFloat64()))
+		low := math.Min(open, closePrice) * math.Exp(-0.5*diffusion*math.Abs(rng.NormFloat64()))
+		volume := math.Round(1_000_000 * math.Exp(0.5*rng.NormFloat64()))
+
+		dividend := 0.0
+		if params.DividendYield > 0 && isDividendDay(day, bars) {
+			dividend = round4(prevClose * params.DividendYield / 4)
+		}
+
+		bars = append(bars, &bar{
+			timestamp:        day,
+			open:             round4(open),
+			high:             round4(high),
+			low:              round4(low),
+			close:            round4(closePrice),
+			volume:           volume,
+			dividendAmount:   dividend,
+			splitCoefficient: split,
+		})
+
+		prevClose = closePrice
+	}
+
+	applyAdjustments(bars)
+	return bars
+}
+
+// generateIntraday builds bars for the last trading day in daily, bridging from that day's open to its close
+func generateIntraday(symbol string, params SymbolParameters, seed uint64, daily []*bar, interval time.Duration) []*bar {
+	if len(daily) == 0 {
+		return nil
+	}
+
+	last := daily[len(daily)-1]
+	day := last.timestamp
+	rng := rand.New(rand.NewPCG(seed^uint64(day.Unix()), symbolSeed(symbol)))
+
+	start := time.Date(day.Year(), day.Month(), day.Day(), intradayOpenHour, 0, 0, 0, day.Location())
+	end := time.Date(day.Year(), day.Month(), day.Day(), intradayCloseHour, 0, 0, 0, day.Location())
+	n := int(end.Sub(start) / interval)
+	if n == 0 {
+		return nil
+	}
+
+	// brownian bridge so the last intraday close lands on the daily close
+	stepSigma := params.Sigma * math.Sqrt(interval.Hours()/(24*tradingDaysPerYear))
+	logTarget := math.Log(last.close / last.open)
+	logPrice := 0.0
+
+	bars := make([]*bar, 0, n)
+	prev := last.open
+	for i := range n {
+		remaining := float64(n - i)
+		logPrice += (logTarget-logPrice)/remaining + stepSigma*math.Sqrt((remaining-1)/remaining)*rng.NormFloat64()
+		closePrice := last.open * math.Exp(logPrice)
+		high := math.Max(prev, closePrice) * math.Exp(0.25*stepSigma*math.Abs(rng.NormFloat64()))
+		low := math.Min(prev, closePrice) * math.Exp(-0.25*stepSigma*math.Abs(rng.NormFloat64()))
+
+		bars = append(bars, &bar{
+			timestamp: start.Add(time.Duration(i+1) * interval),
+			open:      round4(prev),
+			high:      round4(high),
+			low:       round4(low),
+			close:     round4(closePrice),
+			volume:    math.Round(last.volume / float64(n) * math.Exp(0.3*rng.NormFloat64())),
+		})
+		prev = closePrice
+	}
+
+	return bars
+}
+
+// aggregateWeekly rolls daily bars into weeks keyed on the last trading day of the week, like av does
+func aggregateWeekly(daily []*bar) []*bar {
+	weeks := []*bar{}
+	var current *bar
+	var currentYear, currentWeek int
+
+	for _, d := range daily {
+		year, week := d.timestamp.ISOWeek()
+		if current == nil || year != currentYear || week != currentWeek {
+			current = &bar{
+				open:             d.open,
+				high:             d.high,
+				low:              d.low,
+				splitCoefficient: 1,
+			}
+			weeks = append(weeks, current)
+			currentYear, currentWeek = year, week
+		}
+
+		current.timestamp = d.timestamp
+		current.high = math.Max(current.high, d.high)
+		current.low = math.Min(current.low, d.low)
+		current.close = d.close
+		current.adjustedClose = d.adjustedClose
+		current.volume += d.volume
+		current.dividendAmount += d.dividendAmount
+		current.splitCoefficient *= d.splitCoefficient
+	}
+
+	return weeks
+}
+
+// applyAdjustments walks back from the latest bar, scaling prior closes by (1 - dividend / previous close)
+// and dividing them by any split coefficient
+func applyAdjustments(bars []*bar) {
+	factor := 1.0
+	for i := len(bars) - 1; i >= 0; i-- {
+		bars[i].adjustedClose = round4(bars[i].close * factor)
+		if bars[i].dividendAmount > 0 && i > 0 {
+			factor *= 1 - bars[i].dividendAmount/bars[i-1].close
+		}
+		factor /= bars[i].splitCoefficient
+	}
+}
+
+// dividends go ex on the first trading day of feb, may, aug and nov
+func isDividendDay(day time.Time, previous []*bar) bool {
+	switch day.Month() {
+	case time.February, time.May, time.August, time.November:
+	default:
+		return false
+	}
+
+	if len(previous) == 0 {
+		return false
+	}
+
+	return previous[len(previous)-1].timestamp.Month() != day.Month()
+}
+
+func symbolSeed(symbol string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(strings.ToUpper(symbol)))
+	return h.Sum64()
+}
+
+func round4(v float64) float64 {
+	return math.Round(v*10_000) / 10_000
+}
@@ -0,0 +1,463 @@
+package fakeav
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	compactSize = 100
+	timeZone    = "US/Eastern"
+
+	throttleNotice   = "Thank you for using Alpha Vantage! Our standard API call frequency is 5 calls per minute and 500 calls per day. Please visit https://www.alphavantage.co/premium/ if you would like to target a higher API call frequency."
+	missingKeyNotice = "the parameter apikey is invalid or missing. Please claim your free API key on (https://www.alphavantage.co/support/#api-key). It should take less than 20 seconds."
+)
+
+var (
+	// first generated trading day when a config does not provide one
+	DefaultOrigin = time.Date(2000, time.January, 3, 0, 0, 0, 0, time.UTC)
+
+	intervals = map[string]time.Duration{
+		"1min":  time.Minute,
+		"5min":  5 * time.Minute,
+		"15min": 15 * time.Minute,
+		"30min": 30 * time.Minute,
+		"60min": time.Hour,
+	}
+)
+
+// Config drives what the stand in serves. Everything is optional, the zero value serves
+// DefaultSymbolParameters for every symbol with no throttling.
+type Config struct {
+	Seed           uint64                      `json:"seed"`
+	Origin         time.Time                   `json:"origin"`         // first generated day
+	AsOf           time.Time                   `json:"asof"`           // last generated day, defaults to today
+	Symbols        map[string]SymbolParameters `json:"symbols"`        // per symbol GBM parameters
+	ErrorSymbols   []string                    `json:"errorsymbols"`   // symbols that always return an "Error Message"
+	ApiKey         string                      `json:"apikey"`         // when set, requests must present this key
+	CallsPerMinute int                         `json:"callsperminute"` // rolling per minute quota, 0 for unlimited
+	ThrottleEvery  int                         `json:"throttleevery"`  // throttle every nth request, 0 to disable
+}
+
+type Server struct {
+	config Config
+	now    func() time.Time
+
+	mu       sync.Mutex
+	requests int
+	calls    []time.Time
+}
+
+type request struct {
+	function   string
+	symbol     string
+	keywords   string
+	interval   string
+	outputSize string
+	dataType   string
+}
+
+// LoadConfig reads a json config, e.g.
+//
+//	{"seed": 7, "asof": "2025-10-31T00:00:00Z", "callsperminute": 5, "symbols": {"AAPL": {"mu": 0.12, "sigma": 0.28, "startprice": 25}}}
+func LoadConfig(path string) (Config, error) {
+	var config Config
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("error reading fakeav config %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return config, fmt.Errorf("error parsing fakeav config %s: %w", path, err)
+	}
+
+	return config, nil
+}
+
+func NewServer(config Config) *Server {
+	if config.Origin.IsZero() {
+		config.Origin = DefaultOrigin
+	}
+
+	// symbol lookups are case insensitive, same as av
+	symbols := make(map[string]SymbolParameters, len(config.Symbols))
+	for k, v := range config.Symbols {
+		symbols[strings.ToUpper(k)] = v
+	}
+	config.Symbols = symbols
+
+	return &Server{
+		config: config,
+		now:    time.Now,
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/query" {
+		http.NotFound(w, r)
+		return
+	}
+
+	q := r.URL.Query()
+	if key := q.Get("apikey"); key == "" || (s.config.ApiKey != "" && key != s.config.ApiKey) {
+		writeError(w, missingKeyNotice)
+		return
+	}
+
+	if s.throttled() {
+		writeJSON(w, map[string]string{"Note": throttleNotice})
+		return
+	}
+
+	req := request{
+		function:   strings.ToUpper(q.Get("function")),
+		symbol:     strings.ToUpper(q.Get("symbol")),
+		keywords:   q.Get("keywords"),
+		interval:   q.Get("interval"),
+		outputSize: strings.ToLower(q.Get("outputsize")),
+		dataType:   strings.ToLower(q.Get("datatype")),
+	}
+
+	if req.outputSize == "" {
+		req.outputSize = "compact"
+	}
+	if req.dataType == "" {
+		req.dataType = "json"
+	}
+
+	if err := s.validate(req); err != nil {
+		writeError(w, err.Error())
+		return
+	}
+
+	switch req.function {
+	case "TIME_SERIES_WEEKLY_ADJUSTED":
+		s.serveWeeklyAdjusted(w, req)
+	case "TIME_SERIES_DAILY_ADJUSTED":
+		s.serveDailyAdjusted(w, req)
+	case "TIME_SERIES_INTRADAY":
+		s.serveIntraday(w, req)
+	case "SYMBOL_SEARCH":
+		s.serveSymbolSearch(w, req)
+	case "OVERVIEW":
+		s.serveOverview(w, req)
+	}
+}
+
+func (s *Server) validate(req request) error {
+	invalid := fmt.Errorf("Invalid API call. Please retry or visit the documentation (https://www.alphavantage.co/documentation/) for %s.", req.function)
+
+	switch req.function {
+	case "TIME_SERIES_WEEKLY_ADJUSTED", "TIME_SERIES_DAILY_ADJUSTED", "OVERVIEW":
+	case "TIME_SERIES_INTRADAY":
+		if _, ok := intervals[req.interval]; !ok {
+			return invalid
+		}
+	case "SYMBOL_SEARCH":
+		if req.keywords == "" {
+			return invalid
+		}
+		return nil
+	default:
+		return fmt.Errorf("This API function (%s) does not exist.", req.function)
+	}
+
+	if req.symbol == "" || slices.ContainsFunc(s.config.ErrorSymbols, func(v string) bool { return strings.EqualFold(v, req.symbol) }) {
+		return invalid
+	}
+
+	if req.outputSize != "compact" && req.outputSize != "full" {
+		return invalid
+	}
+
+	if req.dataType != "json" && req.dataType != "csv" {
+		return invalid
+	}
+
+	return nil
+}
+
+func (s *Server) serveWeeklyAdjusted(w http.ResponseWriter, req request) {
+	weeks := trim(aggregateWeekly(s.daily(req.symbol)), req.outputSize)
+	if req.dataType == "csv" {
+		rows := make([][]string, len(weeks))
+		for i, b := range weeks {
+			rows[i] = []string{fmtDate(b.timestamp), fmtPrice(b.open), fmtPrice(b.high), fmtPrice(b.low), fmtPrice(b.close), fmtPrice(b.adjustedClose), fmtVolume(b.volume), fmtPrice(b.dividendAmount)}
+		}
+		writeCSV(w, []string{"timestamp", "open", "high", "low", "close", "adjusted close", "volume", "dividend amount"}, rows)
+		return
+	}
+
+	series := make(map[string]map[string]string, len(weeks))
+	for _, b := range weeks {
+		series[fmtDate(b.timestamp)] = map[string]string{
+			"1. open":            fmtPrice(b.open),
+			"2. high":            fmtPrice(b.high),
+			"3. low":             fmtPrice(b.low),
+			"4. close":           fmtPrice(b.close),
+			"5. adjusted close":  fmtPrice(b.adjustedClose),
+			"6. volume":          fmtVolume(b.volume),
+			"7. dividend amount": fmtPrice(b.dividendAmount),
+		}
+	}
+
+	writeJSON(w, map[string]any{
+		"Meta Data": map[string]string{
+			"1. Information":    "Weekly Adjusted Prices and Volumes",
+			"2. Symbol":         req.symbol,
+			"3. Last Refreshed": lastRefreshed(weeks, fmtDate),
+			"4. Time Zone":      timeZone,
+		},
+		"Weekly Adjusted Time Series": series,
+	})
+}
+
+func (s *Server) serveDailyAdjusted(w http.ResponseWriter, req request) {
+	days := trim(s.daily(req.symbol), req.outputSize)
+	if req.dataType == "csv" {
+		rows := make([][]string, len(days))
+		for i, b := range days {
+			rows[i] = []string{fmtDate(b.timestamp), fmtPrice(b.open), fmtPrice(b.high), fmtPrice(b.low), fmtPrice(b.close), fmtPrice(b.adjustedClose), fmtVolume(b.volume), fmtPrice(b.dividendAmount), fmt.Sprintf("%.1f", b.splitCoefficient)}
+		}
+		writeCSV(w, []string{"timestamp", "open", "high", "low", "close", "adjusted_close", "volume", "dividend_amount", "split_coefficient"}, rows)
+		return
+	}
+
+	series := make(map[string]map[string]string, len(days))
+	for _, b := range days {
+		series[fmtDate(b.timestamp)] = map[string]string{
+			"1. open":              fmtPrice(b.open),
+			"2. high":              fmtPrice(b.high),
+			"3. low":               fmtPrice(b.low),
+			"4. close":             fmtPrice(b.close),
+			"5. adjusted close":    fmtPrice(b.adjustedClose),
+			"6. volume":            fmtVolume(b.volume),
+			"7. dividend amount":   fmtPrice(b.dividendAmount),
+			"8. split coefficient": fmt.Sprintf("%.1f", b.splitCoefficient),
+		}
+	}
+
+	writeJSON(w, map[string]any{
+		"Meta Data": map[string]string{
+			"1. Information":    "Daily Time Series with Splits and Dividend Events",
+			"2. Symbol":         req.symbol,
+			"3. Last Refreshed": lastRefreshed(days, fmtDate),
+			"4. Output Size":    outputSizeLabel(req.outputSize),
+			"5. Time Zone":      timeZone,
+		},
+		"Time Series (Daily)": series,
+	})
+}
+
+func (s *Server) serveIntraday(w http.ResponseWriter, req request) {
+	interval := intervals[req.interval]
+	bars := trim(generateIntraday(req.symbol, s.parameters(req.symbol), s.config.Seed, s.daily(req.symbol), interval), req.outputSize)
+	if req.dataType == "csv" {
+		rows := make([][]string, len(bars))
+		for i, b := range bars {
+			rows[i] = []string{fmtDateTime(b.timestamp), fmtPrice(b.open), fmtPrice(b.high), fmtPrice(b.low), fmtPrice(b.close), fmtVolume(b.volume)}
+		}
+		writeCSV(w, []string{"timestamp", "open", "high", "low", "close", "volume"}, rows)
+		return
+	}
+
+	series := make(map[string]map[string]string, len(bars))
+	for _, b := range bars {
+		series[fmtDateTime(b.timestamp)] = map[string]string{
+			"1. open":   fmtPrice(b.open),
+			"2. high":   fmtPrice(b.high),
+			"3. low":    fmtPrice(b.low),
+			"4. close":  fmtPrice(b.close),
+			"5. volume": fmtVolume(b.volume),
+		}
+	}
+
+	writeJSON(w, map[string]any{
+		"Meta Data": map[string]string{
+			"1. Information":    fmt.Sprintf("Intraday (%s) open, high, low, close prices and volume", req.interval),
+			"2. Symbol":         req.symbol,
+			"3. Last Refreshed": lastRefreshed(bars, fmtDateTime),
+			"4. Interval":       req.interval,
+			"5. Output Size":    outputSizeLabel(req.outputSize),
+			"6. Time Zone":      timeZone,
+		},
+		fmt.Sprintf("Time Series (%s)", req.interval): series,
+	})
+}
+
+// serveSymbolSearch matches configured symbols on symbol or name, an exact symbol always matches
+func (s *Server) serveSymbolSearch(w http.ResponseWriter, req request) {
+	keywords := strings.ToUpper(req.keywords)
+	candidates := slices.Sorted(maps.Keys(s.config.Symbols))
+	if !slices.Contains(candidates, keywords) {
+		candidates = append([]string{keywords}, candidates...)
+	}
+
+	matches := []map[string]string{}
+	for _, symbol := range candidates {
+		name := s.name(symbol)
+		score := 0.0
+		switch {
+		case symbol == keywords:
+			score = 1
+		case strings.HasPrefix(symbol, keywords):
+			score = float64(len(keywords)) / float64(len(symbol))
+		case strings.Contains(strings.ToUpper(name), keywords):
+			score = 0.5
+		default:
+			continue
+		}
+
+		matches = append(matches, map[string]string{
+			"1. symbol":      symbol,
+			"2. name":        name,
+			"3. type":        "Equity",
+			"4. region":      "United States",
+			"5. marketOpen":  "09:30",
+			"6. marketClose": "16:00",
+			"7. timezone":    "UTC-04",
+			"8. currency":    "USD",
+			"9. matchScore":  fmt.Sprintf("%.4f", score),
+		})
+	}
+
+	writeJSON(w, map[string]any{"bestMatches": matches})
+}
+
+func (s *Server) serveOverview(w http.ResponseWriter, req request) {
+	p := s.parameters(req.symbol)
+	writeJSON(w, map[string]string{
+		"Symbol":        req.symbol,
+		"AssetType":     "Common Stock",
+		"Name":          s.name(req.symbol),
+		"Description":   fmt.Sprintf("Synthetic GBM series (mu %.4f, sigma %.4f) served by fakeav.", p.Mu, p.Sigma),
+		"Exchange":      "FAKE",
+		"Currency":      "USD",
+		"Country":       "USA",
+		"Sector":        "SYNTHETIC",
+		"Industry":      "SYNTHETIC",
+		"DividendYield": fmt.Sprintf("%.4f", p.DividendYield),
+	})
+}
+
+func (s *Server) name(symbol string) string {
+	if p, ok := s.config.Symbols[symbol]; ok && p.Name != "" {
+		return p.Name
+	}
+	return symbol + " Synthetic Inc"
+}
+
+func (s *Server) daily(symbol string) []*bar {
+	asOf := s.config.AsOf
+	if asOf.IsZero() {
+		now := s.now().UTC()
+		asOf = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return generateDaily(symbol, s.parameters(symbol), s.config.Seed, s.config.Origin, asOf)
+}
+
+func (s *Server) parameters(symbol string) SymbolParameters {
+	if p, ok := s.config.Symbols[strings.ToUpper(symbol)]; ok {
+		return p
+	}
+	return DefaultSymbolParameters
+}
+
+// throttled applies both the every nth request rule and the rolling per minute quota
+func (s *Server) throttled() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests++
+	if s.config.ThrottleEvery > 0 && s.requests%s.config.ThrottleEvery == 0 {
+		return true
+	}
+
+	if s.config.CallsPerMinute <= 0 {
+		return false
+	}
+
+	now := s.now()
+	s.calls = slices.DeleteFunc(s.calls, func(t time.Time) bool { return now.Sub(t) >= time.Minute })
+	if len(s.calls) >= s.config.CallsPerMinute {
+		return true
+	}
+
+	s.calls = append(s.calls, now)
+	return false
+}
+
+// trim returns the bars newest first, limited to the latest compactSize for compact requests
+func trim(bars []*bar, outputSize string) []*bar {
+	res := slices.Clone(bars)
+	slices.Reverse(res)
+	if outputSize == "compact" && len(res) > compactSize {
+		res = res[:compactSize]
+	}
+	return res
+}
+
+func lastRefreshed(bars []*bar, format func(time.Time) string) string {
+	if len(bars) == 0 {
+		return ""
+	}
+	return format(bars[0].timestamp)
+}
+
+func outputSizeLabel(outputSize string) string {
+	if outputSize == "full" {
+		return "Full size"
+	}
+	return "Compact"
+}
+
+func writeJSON(w http.ResponseWriter, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	if err := enc.Encode(data); err != nil {
+		log.Printf("fakeav: error writing json response: %v", err)
+	}
+}
+
+// av reports errors as a 200 with an "Error Message" payload, regardless of datatype
+func writeError(w http.ResponseWriter, message string) {
+	writeJSON(w, map[string]string{"Error Message": message})
+}
+
+func writeCSV(w http.ResponseWriter, header []string, rows [][]string) {
+	w.Header().Set("Content-Type", "application/x-download")
+	w.WriteHeader(http.StatusOK)
+	cw := csv.NewWriter(w)
+	cw.Write(header)
+	cw.WriteAll(rows)
+	if err := cw.Error(); err != nil {
+		log.Printf("fakeav: error writing csv response: %v", err)
+	}
+}
+
+func fmtDate(t time.Time) string {
+	return t.Format(time.DateOnly)
+}
+
+func fmtDateTime(t time.Time) string {
+	return t.Format(time.DateTime)
+}
+
+func fmtPrice(v float64) string {
+	return fmt.Sprintf("%.4f", v)
+}
+
+func fmtVolume(v float64) string {
+	return fmt.Sprintf("%.0f", v)
+}
@@ -0,0 +1,197 @@
+package fakeav_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	e "mc.data/extensions"
+	av "mc.service/api/alpha_vantage"
+	"mc.service/api/fakeav"
+)
+
+var asOf = time.Date(2025, time.October, 31, 0, 0, 0, 0, time.UTC)
+
+func getTestServer(t *testing.T, config fakeav.Config) *httptest.Server {
+	t.Helper()
+	if config.AsOf.IsZero() {
+		config.AsOf = asOf
+	}
+
+	ts := httptest.NewServer(fakeav.NewServer(config))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func Test_FakeAv_WeeklyAdjustedParsesThroughClient(t *testing.T) {
+	ts := getTestServer(t, fakeav.Config{
+		Seed: 7,
+		Symbols: map[string]fakeav.SymbolParameters{
+			"SPY": {Mu: 0.08, Sigma: 0.16, StartPrice: 140, DividendYield: 0.015},
+		},
+	})
+
+	c := av.GetClientForHost(ts.URL, "demo")
+	res, err := c.GetStockWeeklyAdjustedMetrics("SPY", av.DataTypeJSON)
+	if err != nil {
+		t.Fatalf("error getting weekly adjusted series from fakeav: %s", err)
+	}
+
+	e.AssertAreEqual(t, "symbol", "SPY", res.Metadata.Symbol)
+	e.AssertAreEqual(t, "last refreshed", "2025-10-31", e.FmtShort(res.Metadata.LastRefreshed))
+	e.AssertAreEqual(t, "compact length", 100, len(res.TimeSeries))
+
+	dividends := 0
+	for _, b := range res.TimeSeries {
+		if b.Low > b.High || b.Close > b.High || b.Close < b.Low || b.Close <= 0 {
+			t.Fatalf("inconsistent bar at %s: %+v", e.FmtShort(b.Timestamp), b.TimeSeriesOHLCV)
+		}
+		if b.DividendAmount > 0 {
+			dividends++
+		}
+		if b.AdjustedClose > b.Close {
+			t.Fatalf("adjusted close should never exceed close with only dividends, %s: %v > %v", e.FmtShort(b.Timestamp), b.AdjustedClose, b.Close)
+		}
+	}
+
+	// 100 weeks covers at least 7 quarterly payments
+	if dividends < 7 {
+		t.Fatalf("expected quarterly dividends, found %d", dividends)
+	}
+}
+
+func Test_FakeAv_IntradayParsesThroughClient(t *testing.T) {
+	ts := getTestServer(t, fakeav.Config{Seed: 7})
+
+	c := av.GetClientForHost(ts.URL, "demo")
+	res, err := c.GetStockIntradayMetrics("MSFT", av.DataTypeJSON)
+	if err != nil {
+		t.Fatalf("error getting intraday series from fakeav: %s", err)
+	}
+
+	e.AssertAreEqual(t, "last refreshed", "2025-10-31 20:00:00", res.Metadata.LastRefreshed.Format(time.DateTime))
+	e.AssertAreEqual(t, "compact length", 100, len(res.TimeSeries))
+}
+
+func Test_FakeAv_IsDeterministic(t *testing.T) {
+	first := getBody(t, getTestServer(t, fakeav.Config{Seed: 11}), "/query?function=TIME_SERIES_WEEKLY_ADJUSTED&symbol=IBM&apikey=demo&outputsize=full&datatype=csv")
+	second := getBody(t, getTestServer(t, fakeav.Config{Seed: 11}), "/query?function=TIME_SERIES_WEEKLY_ADJUSTED&symbol=IBM&apikey=demo&outputsize=full&datatype=csv")
+	e.AssertAreEqual(t, "body", first, second)
+
+	// moving as of forward should only add newer weeks, the older history stays put (no dividends configured)
+	later := getBody(t, getTestServer(t, fakeav.Config{Seed: 11, AsOf: asOf.AddDate(0, 0, 14)}), "/query?function=TIME_SERIES_WEEKLY_ADJUSTED&symbol=IBM&apikey=demo&outputsize=full&datatype=csv")
+	_, rows, _ := strings.Cut(first, "\n")
+	if len(later) <= len(first) || !strings.HasSuffix(later, rows) {
+		t.Fatalf("expected history to be extended, not regenerated, when as of moves forward")
+	}
+}
+
+func Test_FakeAv_ThrottlesAndErrors(t *testing.T) {
+	ts := getTestServer(t, fakeav.Config{ThrottleEvery: 2, ErrorSymbols: []string{"BAD"}})
+	c := av.GetClientForHost(ts.URL, "demo")
+
+	if _, err := c.GetStockWeeklyAdjustedMetrics("BAD", av.DataTypeJSON); !errors.Is(err, av.ErrApiError) {
+		t.Fatalf("expected ErrApiError for configured error symbol, got %v", err)
+	}
+
+	if _, err := c.GetStockWeeklyAdjustedMetrics("AAPL", av.DataTypeJSON); !errors.Is(err, av.ErrThrottled) {
+		t.Fatalf("expected ErrThrottled on the second request, got %v", err)
+	}
+
+	if _, err := c.GetStockWeeklyAdjustedMetrics("AAPL", av.DataTypeJSON); err != nil {
+		t.Fatalf("expected the third request to succeed, got %v", err)
+	}
+
+	missingKey := av.GetClientForHost(ts.URL, "")
+	if _, err := missingKey.GetStockIntradayMetrics("AAPL", av.DataTypeJSON); !errors.Is(err, av.ErrApiError) {
+		t.Fatalf("expected ErrApiError for a missing api key, got %v", err)
+	}
+}
+
+func Test_FakeAv_CallsPerMinute(t *testing.T) {
+	ts := getTestServer(t, fakeav.Config{CallsPerMinute: 2})
+	c := av.GetClientForHost(ts.URL, "demo")
+
+	for i := range 2 {
+		if _, err := c.GetStockWeeklyAdjustedMetrics("AAPL", av.DataTypeJSON); err != nil {
+			t.Fatalf("request %d should be inside the quota, got %v", i, err)
+		}
+	}
+
+	if _, err := c.GetStockWeeklyAdjustedMetrics("AAPL", av.DataTypeJSON); !errors.Is(err, av.ErrThrottled) {
+		t.Fatalf("expected ErrThrottled once the quota is used, got %v", err)
+	}
+}
+
+func getBody(t *testing.T, ts *httptest.Server, path string) string {
+	t.Helper()
+	resp, err := http.Get(ts.URL + path)
+	if err != nil {
+		t.Fatalf("error requesting %s: %s", path, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("error reading body: %s", err)
+	}
+	return string(body)
+}
+
+func Test_FakeAv_CsvMatchesJson(t *testing.T) {
+	ts := getTestServer(t, fakeav.Config{
+		Seed: 3,
+		Symbols: map[string]fakeav.SymbolParameters{
+			"VTI": {Mu: 0.09, Sigma: 0.18, StartPrice: 55, DividendYield: 0.014},
+		},
+	})
+	c := av.GetClientForHost(ts.URL, "demo")
+
+	jsonRes, err := c.GetStockWeeklyAdjustedMetrics("VTI", av.DataTypeJSON)
+	if err != nil {
+		t.Fatalf("error getting json weekly series: %s", err)
+	}
+	csvRes, err := c.GetStockWeeklyAdjustedMetrics("VTI", av.DataTypeCSV)
+	if err != nil {
+		t.Fatalf("error getting csv weekly series: %s", err)
+	}
+	av.AssertTimeSeriesResultsEqual(t, jsonRes, csvRes)
+
+	jsonIntraday, err := c.GetStockIntradayMetrics("VTI", av.DataTypeJSON)
+	if err != nil {
+		t.Fatalf("error getting json intraday series: %s", err)
+	}
+	csvIntraday, err := c.GetStockIntradayMetrics("VTI", av.DataTypeCSV)
+	if err != nil {
+		t.Fatalf("error getting csv intraday series: %s", err)
+	}
+	av.AssertTimeSeriesIntradayResultsEqual(t, jsonIntraday, csvIntraday)
+}
+
+func Test_FakeAv_SearchAndOverview(t *testing.T) {
+	ts := getTestServer(t, fakeav.Config{
+		Symbols: map[string]fakeav.SymbolParameters{
+			"AAPL": {Name: "Apple Inc", Mu: 0.12, Sigma: 0.28, StartPrice: 25},
+			"AMZN": {Name: "Amazon.com Inc", Mu: 0.15, Sigma: 0.35, StartPrice: 40},
+		},
+	})
+	c := av.GetClientForHost(ts.URL, "demo")
+
+	matches, err := c.SearchSymbols("aa")
+	if err != nil {
+		t.Fatalf("error searching symbols: %s", err)
+	}
+	if len(matches) == 0 || matches[len(matches)-1].Symbol != "AAPL" {
+		t.Fatalf("expected AAPL in the search results, got %+v", matches)
+	}
+
+	md, err := c.GetSymbolMetadata("AMZN")
+	if err != nil {
+		t.Fatalf("error getting overview: %s", err)
+	}
+	e.AssertAreEqual(t, "name", "Amazon.com Inc", md.Name)
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// query parameters that make up a fixture name, in order. the api key is intentionally left out
+var fixtureKeyParams = []string{"function", "symbol", "keywords", "interval"}
+
+var fixtureUnsafeChars = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// RecordingConnection passes requests through to the wrapped connection and writes
+// every response body to Dir, so it can be served later by a ReplayConnection
+type RecordingConnection struct {
+	Connection Connection
+	Dir        string
+}
+
+// ReplayConnection serves responses previously captured by a RecordingConnection
+type ReplayConnection struct {
+	Dir string
+}
+
+func (rc *RecordingConnection) Request(endpoint *url.URL) (*http.Response, error) {
+	response, err := rc.Connection.Request(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body for recording: %w", err)
+	}
+
+	if err := os.MkdirAll(rc.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating fixture directory %s: %w", rc.Dir, err)
+	}
+
+	path := filepath.Join(rc.Dir, FixtureName(endpoint))
+	if err := os.WriteFile(path, body, 0o644); err != nil {
+		return nil, fmt.Errorf("error writing fixture %s: %w", path, err)
+	}
+
+	// hand back a fresh body, the original has been drained
+	response.Body = io.NopCloser(bytes.NewReader(body))
+	return response, nil
+}
+
+func (rc *ReplayConnection) Request(endpoint *url.URL) (*http.Response, error) {
+	path := filepath.Join(rc.Dir, FixtureName(endpoint))
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading fixture %s: %w", path, err)
+	}
+
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Header:        http.Header{"Content-Type": []string{contentType(endpoint)}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       &http.Request{Method: http.MethodGet, URL: endpoint},
+	}, nil
+}
+
+// FixtureName maps a request to a stable file name, e.g. time_series_weekly_adjusted_aapl.json
+func FixtureName(endpoint *url.URL) string {
+	query := endpoint.Query()
+
+	parts := []string{}
+	for _, key := range fixtureKeyParams {
+		if v := query.Get(key); v != "" {
+			parts = append(parts, fixtureUnsafeChars.ReplaceAllString(strings.ToLower(v), "-"))
+		}
+	}
+
+	name := strings.Join(parts, "_")
+	if name == "" {
+		name = "response"
+	}
+
+	return name + "." + fixtureExtension(endpoint)
+}
+
+func fixtureExtension(endpoint *url.URL) string {
+	if strings.EqualFold(endpoint.Query().Get("datatype"), "csv") {
+		return "csv"
+	}
+	return "json"
+}
+
+func contentType(endpoint *url.URL) string {
+	if fixtureExtension(endpoint) == "csv" {
+		return "application/x-download"
+	}
+	return "application/json"
+}
@@ -0,0 +1,22 @@
+package api
+
+import (
+	"errors"
+
+	m "mc.data/models"
+)
+
+// ErrThrottled is wrapped by providers when the vendor turns a call away for exceeding its quota
+var ErrThrottled = errors.New("market data request was throttled")
+
+// MarketDataProvider is everything sync and simulation need from a market data vendor.
+// Alpha Vantage is the only implementation today, see core.GetMarketDataProvider for selection.
+type MarketDataProvider interface {
+	// Name is recorded against every series synced through the provider
+	Name() string
+	// GetHistoricalBars returns the adjusted weekly history used for simulations
+	GetHistoricalBars(symbol string) (*m.TimeSeriesResult, error)
+	GetIntradayBars(symbol string) (*m.TimeSeriesIntradayResult, error)
+	SearchSymbols(keywords string) ([]*m.SymbolMatch, error)
+	GetSymbolMetadata(symbol string) (*m.SymbolMetadata, error)
+}
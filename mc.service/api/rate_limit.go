@@ -0,0 +1,103 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	m "mc.data/models"
+)
+
+const (
+	// throttled calls are retried this many times, each after the limiter holds every caller back
+	maxThrottleRetries = 3
+	// a throttled vendor has used up its minute, so the next call waits out a full one
+	defaultThrottleBackoff = time.Minute
+)
+
+// RateLimiter spaces calls evenly so a burst never exceeds the vendor's per minute quota
+type RateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func NewRateLimiter(callsPerMinute int) *RateLimiter {
+	return &RateLimiter{interval: time.Minute / time.Duration(callsPerMinute)}
+}
+
+// Wait blocks until the caller is allowed to make its call
+func (rl *RateLimiter) Wait() {
+	rl.mu.Lock()
+	now := time.Now()
+	if rl.next.Before(now) {
+		rl.next = now
+	}
+	wait := rl.next.Sub(now)
+	rl.next = rl.next.Add(rl.interval)
+	rl.mu.Unlock()
+
+	time.Sleep(wait)
+}
+
+// Pause holds every caller back until at least d from now
+func (rl *RateLimiter) Pause(d time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if until := time.Now().Add(d); rl.next.Before(until) {
+		rl.next = until
+	}
+}
+
+// RateLimitedProvider shares one quota across every caller of the wrapped provider,
+// so batch syncs and single requests can run at the same time without tripping throttling
+type RateLimitedProvider struct {
+	MarketDataProvider
+	limiter *RateLimiter
+	backoff time.Duration // pause after a throttled call before it is retried
+}
+
+// NewRateLimitedProvider returns provider unchanged when callsPerMinute is not positive
+func NewRateLimitedProvider(provider MarketDataProvider, callsPerMinute int) MarketDataProvider {
+	if callsPerMinute <= 0 {
+		return provider
+	}
+
+	return &RateLimitedProvider{
+		MarketDataProvider: provider,
+		limiter:            NewRateLimiter(callsPerMinute),
+		backoff:            defaultThrottleBackoff,
+	}
+}
+
+// withRetry waits its turn for every attempt, a throttled call pauses the shared limiter and tries again
+func withRetry[T any](p *RateLimitedProvider, call func() (T, error)) (T, error) {
+	for attempt := 1; ; attempt++ {
+		p.limiter.Wait()
+		res, err := call()
+		if err == nil || !errors.Is(err, ErrThrottled) || attempt > maxThrottleRetries {
+			return res, err
+		}
+
+		log.Printf("%s throttled a call, retrying in %s (%d of %d)", p.Name(), p.backoff, attempt, maxThrottleRetries)
+		p.limiter.Pause(p.backoff)
+	}
+}
+
+func (p *RateLimitedProvider) GetHistoricalBars(symbol string) (*m.TimeSeriesResult, error) {
+	return withRetry(p, func() (*m.TimeSeriesResult, error) { return p.MarketDataProvider.GetHistoricalBars(symbol) })
+}
+
+func (p *RateLimitedProvider) GetIntradayBars(symbol string) (*m.TimeSeriesIntradayResult, error) {
+	return withRetry(p, func() (*m.TimeSeriesIntradayResult, error) { return p.MarketDataProvider.GetIntradayBars(symbol) })
+}
+
+func (p *RateLimitedProvider) SearchSymbols(keywords string) ([]*m.SymbolMatch, error) {
+	return withRetry(p, func() ([]*m.SymbolMatch, error) { return p.MarketDataProvider.SearchSymbols(keywords) })
+}
+
+func (p *RateLimitedProvider) GetSymbolMetadata(symbol string) (*m.SymbolMetadata, error) {
+	return withRetry(p, func() (*m.SymbolMetadata, error) { return p.MarketDataProvider.GetSymbolMetadata(symbol) })
+}
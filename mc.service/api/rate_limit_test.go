@@ -0,0 +1,78 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	m "mc.data/models"
+)
+
+func Test_RateLimiter_SpacesConcurrentCalls(t *testing.T) {
+	// 1200 a minute is one call every 50ms
+	rl := NewRateLimiter(1200)
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rl.Wait()
+		}()
+	}
+	wg.Wait()
+
+	// the first call goes straight through, the other four wait their turn
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Fatalf("expected 5 calls to take at least 200ms, took %s", elapsed)
+	}
+}
+
+func Test_RateLimiter_UnlimitedReturnsProvider(t *testing.T) {
+	var p MarketDataProvider
+	if NewRateLimitedProvider(p, 0) != p {
+		t.Fatalf("expected the provider back unwrapped when there is no limit")
+	}
+}
+
+// throttledProvider turns away the first calls it gets
+type throttledProvider struct {
+	MarketDataProvider
+	throttle int
+	calls    int
+}
+
+func (p *throttledProvider) Name() string { return "test" }
+
+func (p *throttledProvider) GetHistoricalBars(symbol string) (*m.TimeSeriesResult, error) {
+	p.calls++
+	if p.calls <= p.throttle {
+		return nil, fmt.Errorf("%w: slow down", ErrThrottled)
+	}
+	return &m.TimeSeriesResult{}, nil
+}
+
+func Test_RateLimiter_RetriesThrottledCalls(t *testing.T) {
+	inner := &throttledProvider{throttle: 2}
+	p := NewRateLimitedProvider(inner, 60_000).(*RateLimitedProvider)
+	p.backoff = 50 * time.Millisecond
+
+	start := time.Now()
+	if _, err := p.GetHistoricalBars("SPY"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if inner.calls != 3 {
+		t.Fatalf("expected 3 calls, got %d", inner.calls)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Fatalf("expected each retry to wait out the backoff, took %s", elapsed)
+	}
+
+	inner.calls, inner.throttle = 0, maxThrottleRetries+1
+	if _, err := p.GetHistoricalBars("SPY"); !errors.Is(err, ErrThrottled) {
+		t.Fatalf("expected the last throttled error once retries run out, got %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+// fakeav serves deterministic synthetic alpha vantage responses for local development and
+// integration tests. Point the service at it with ALPHAVANTAGE_HOST=http://localhost:8081
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"time"
+
+	"mc.service/api/fakeav"
+)
+
+func main() {
+	addr := flag.String("addr", ":8081", "address to listen on")
+	configPath := flag.String("config", "", "optional json config with per symbol GBM parameters, see fakeav.LoadConfig")
+	seed := flag.Uint64("seed", 0, "rng seed, overrides the config file when non zero")
+	asOf := flag.String("asof", "", "last generated date (YYYY-MM-DD), defaults to today")
+	callsPerMinute := flag.Int("calls-per-minute", 0, "throttle after this many calls in a rolling minute, 0 for unlimited")
+	throttleEvery := flag.Int("throttle-every", 0, "throttle every nth request, 0 to disable")
+	flag.Parse()
+
+	config := fakeav.Config{}
+	if *configPath != "" {
+		c, err := fakeav.LoadConfig(*configPath)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		config = c
+	}
+
+	if *seed != 0 {
+		config.Seed = *seed
+	}
+	if *asOf != "" {
+		t, err := time.Parse(time.DateOnly, *asOf)
+		if err != nil {
+			log.Fatalf("error parsing -asof %s: %v", *asOf, err)
+		}
+		config.AsOf = t
+	}
+	if *callsPerMinute != 0 {
+		config.CallsPerMinute = *callsPerMinute
+	}
+	if *throttleEvery != 0 {
+		config.ThrottleEvery = *throttleEvery
+	}
+
+	log.Printf("Starting fake alpha vantage server on %s", *addr)
+	if err := http.ListenAndServe(*addr, fakeav.NewServer(config)); err != nil {
+		log.Fatalf("Server error: %v", err)
+	}
+}
@@ -0,0 +1,209 @@
+// gmcgctl runs service operations from the command line against the database in DATABASE_URL.
+//
+//	gmcgctl import -file prices.csv -symbol SPY [-map "Date=timestamp,Adj Close=adjusted_close"] [-date-format 01/02/2006] [-dry-run]
+//	gmcgctl sync (-symbols SPY,QQQ | -watchlist core) [-workers 2]
+//	gmcgctl refresh [-workers 2]
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/joho/godotenv"
+
+	m "mc.data/models"
+	r "mc.data/repos"
+	c "mc.service/core"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := godotenv.Load(); err != nil {
+		log.Printf(".env not loaded: %v", err)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "import":
+		err = runImport(ctx, os.Args[2:])
+	case "sync":
+		err = runSync(ctx, os.Args[2:])
+	case "refresh":
+		err = runRefresh(ctx, os.Args[2:])
+	default:
+		usage()
+	}
+
+	if err != nil {
+		log.Fatalf("%s failed: %v", os.Args[1], err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: gmcgctl <command> [flags]\n\ncommands:\n  import    load a csv of daily or weekly bars, see gmcgctl import -h\n  sync      sync symbols or a watchlist from the market data provider, see gmcgctl sync -h\n  refresh   sync every stored symbol that is stale, like the scheduled refresh")
+	os.Exit(2)
+}
+
+// getServiceContext connects to the database, and to the market data provider when withProvider is set
+func getServiceContext(ctx context.Context, withProvider bool) (c.ServiceContext, func(), error) {
+	sc := c.ServiceContext{Context: ctx}
+
+	refreshConfig, err := c.GetRefreshConfigFromEnv()
+	if err != nil {
+		return sc, nil, err
+	}
+	sc.StalenessPolicy = refreshConfig.Staleness
+
+	if withProvider {
+		providerConfig, err := c.GetProviderConfigFromEnv()
+		if err != nil {
+			return sc, nil, err
+		}
+
+		sc.MarketDataProvider, err = c.GetMarketDataProvider(providerConfig)
+		if err != nil {
+			return sc, nil, fmt.Errorf("failed to configure market data provider: %w", err)
+		}
+	}
+
+	postgresConnection, err := r.GetPostgresConnection(ctx, os.Getenv("DATABASE_URL"))
+	if err != nil {
+		return sc, nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	sc.PostgresConnection = postgresConnection
+	return sc, postgresConnection.Close, nil
+}
+
+func runImport(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	file := fs.String("file", "", "csv file to import (required)")
+	symbol := fs.String("symbol", "", "symbol for every row, required unless the file has a symbol column")
+	mapping := fs.String("map", "", "comma separated header=field pairs, e.g. \"Date=timestamp,Adj Close=adjusted_close\"")
+	dateFormat := fs.String("date-format", "", "go time layout for the date column, common formats are tried when empty")
+	dryRun := fs.Bool("dry-run", false, "validate and report what would change without writing")
+	allowMismatch := fs.Bool("allow-frequency-mismatch", false, "load into an existing series stored at another frequency")
+	fs.Parse(args)
+
+	if *file == "" {
+		fs.Usage()
+		return fmt.Errorf("-file is required")
+	}
+
+	req := c.CsvImportRequest{
+		Symbol:     *symbol,
+		DateFormat: *dateFormat,
+		DryRun:     *dryRun,
+
+		AllowFrequencyMismatch: *allowMismatch,
+	}
+
+	if *mapping != "" {
+		req.Columns = map[string]string{}
+		for pair := range strings.SplitSeq(*mapping, ",") {
+			column, field, ok := strings.Cut(pair, "=")
+			if !ok {
+				return fmt.Errorf("invalid -map entry %q, expected header=field", pair)
+			}
+			req.Columns[strings.TrimSpace(column)] = strings.TrimSpace(field)
+		}
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		return fmt.Errorf("error opening %s: %w", *file, err)
+	}
+	defer f.Close()
+
+	sc, closeConnection, err := getServiceContext(ctx, false)
+	if err != nil {
+		return err
+	}
+	defer closeConnection()
+
+	report, importErr := sc.ImportTimeSeriesCsv(f, req)
+	if report != nil {
+		out := json.NewEncoder(os.Stdout)
+		out.SetIndent("", "  ")
+		out.Encode(report)
+	}
+
+	return importErr
+}
+
+func runSync(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	symbols := fs.String("symbols", "", "comma separated symbols to sync")
+	watchlist := fs.String("watchlist", "", "name of a stored watchlist to sync instead of -symbols")
+	workers := fs.Int("workers", c.DefaultSyncWorkers, "concurrent syncs, MARKET_DATA_CALLS_PER_MINUTE still bounds provider calls")
+	fs.Parse(args)
+
+	if (*symbols == "") == (*watchlist == "") {
+		fs.Usage()
+		return fmt.Errorf("exactly one of -symbols or -watchlist is required")
+	}
+
+	sc, closeConnection, err := getServiceContext(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer closeConnection()
+
+	var report *c.BatchSyncReport
+	if *watchlist != "" {
+		report, err = sc.SyncWatchlist(ctx, *watchlist, *workers)
+	} else {
+		report, err = sc.SyncSymbols(ctx, strings.Split(*symbols, ","), *workers)
+	}
+	if err != nil {
+		return err
+	}
+
+	out := json.NewEncoder(os.Stdout)
+	out.SetIndent("", "  ")
+	out.Encode(report)
+
+	if report.Failed > 0 {
+		return fmt.Errorf("%d of %d symbols failed", report.Failed, len(report.Results))
+	}
+	return nil
+}
+
+func runRefresh(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("refresh", flag.ExitOnError)
+	workers := fs.Int("workers", c.DefaultSyncWorkers, "concurrent syncs, MARKET_DATA_CALLS_PER_MINUTE still bounds provider calls")
+	fs.Parse(args)
+
+	sc, closeConnection, err := getServiceContext(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer closeConnection()
+
+	run, err := sc.RefreshStaleSymbols(m.RefreshTriggerManual, *workers)
+	if err != nil {
+		return err
+	}
+
+	out := json.NewEncoder(os.Stdout)
+	out.SetIndent("", "  ")
+	out.Encode(run)
+
+	if run.Status == m.RefreshStatusSkipped {
+		return fmt.Errorf("another instance is already refreshing")
+	}
+	return nil
+}
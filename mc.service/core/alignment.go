@@ -0,0 +1,293 @@
+package core
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat"
+)
+
+// how returns are lined up when the assets in an allocation have different histories
+const (
+	AlignTruncate  = "truncate" // inner join on date, every asset is cut to the dates they all share
+	AlignDropAsset = "drop"     // assets that cover too little of the window are removed, the rest are inner joined
+	AlignPairwise  = "pairwise" // every asset keeps its own history, covariance uses pairwise complete observations
+)
+
+const (
+	// an asset is dropped under AlignDropAsset when it has returns on less than this share of all observed dates
+	DefaultAlignmentCoverage = 0.9
+	minAlignedObservations   = 2
+)
+
+// EffectiveWindow is the slice of history the simulation statistics were estimated from
+type EffectiveWindow struct {
+	Policy       string         `json:"policy"`
+	Start        time.Time      `json:"start"`
+	End          time.Time      `json:"end"`
+	Observations int            `json:"observations"` // shared dates, or the smallest pairwise overlap for AlignPairwise
+	Assets       []*AssetWindow `json:"assets"`
+	Dropped      []string       `json:"dropped"`
+}
+
+// AssetWindow is the history an individual asset brought to the alignment
+type AssetWindow struct {
+	Id           int32     `json:"id"`
+	Ticker       string    `json:"ticker"`
+	Start        time.Time `json:"start"`
+	End          time.Time `json:"end"`
+	Observations int       `json:"observations"`
+	Dropped      bool      `json:"dropped"`
+}
+
+func normalizeAlignmentPolicy(policy string) (string, error) {
+	switch p := strings.ToLower(strings.TrimSpace(policy)); p {
+	case "":
+		return AlignTruncate, nil
+	case AlignTruncate, AlignDropAsset, AlignPairwise:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown alignment policy %q, expected %s, %s or %s", policy, AlignTruncate, AlignDropAsset, AlignPairwise)
+	}
+}
+
+// AlignSeriesReturns lines up the returns of every allocation on date according to the policy.
+// Series are returned in allocation order with dates ascending, dropped assets are removed and the
+// remaining weights are scaled back up to 1.
+func AlignSeriesReturns(policy string, allocations []SimulationAllocation, series []*SeriesReturns) ([]*SeriesReturns, *EffectiveWindow, error) {
+	policy, err := normalizeAlignmentPolicy(policy)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	byId := make(map[int32]*SeriesReturns, len(series))
+	for _, s := range series {
+		byId[s.Id] = s
+	}
+
+	window := &EffectiveWindow{Policy: policy, Dropped: []string{}}
+	ordered := make([]*SeriesReturns, 0, len(allocations))
+	for _, a := range allocations {
+		s := byId[a.Id]
+		if s == nil || len(s.Returns) == 0 {
+			if policy != AlignDropAsset {
+				return nil, nil, fmt.Errorf("no returns found for %s within the lookback", a.Ticker)
+			}
+			window.Assets = append(window.Assets, &AssetWindow{Id: a.Id, Ticker: a.Ticker, Dropped: true})
+			window.Dropped = append(window.Dropped, a.Ticker)
+			continue
+		}
+
+		s = sortedSeries(s)
+		window.Assets = append(window.Assets, &AssetWindow{
+			Id:           s.Id,
+			Ticker:       s.Ticker,
+			Start:        s.Dates[0],
+			End:          s.Dates[len(s.Dates)-1],
+			Observations: len(s.Dates),
+		})
+		ordered = append(ordered, s)
+	}
+
+	if policy != AlignPairwise {
+		if err := checkSharedFrequency(ordered); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if policy == AlignDropAsset {
+		ordered = dropSparseSeries(ordered, window)
+		if len(ordered) == 0 {
+			return nil, nil, fmt.Errorf("every asset was dropped, no returns left to align")
+		}
+	}
+
+	var res []*SeriesReturns
+	if policy == AlignPairwise {
+		res, err = pairwiseWindow(ordered, window)
+	} else {
+		res, err = innerJoinWindow(ordered, window)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(window.Dropped) > 0 {
+		reweight(res)
+	}
+
+	return res, window, nil
+}
+
+// sortedSeries copies a series with its returns ordered by ascending date
+func sortedSeries(s *SeriesReturns) *SeriesReturns {
+	idx := make([]int, len(s.Dates))
+	for i := range idx {
+		idx[i] = i
+	}
+	slices.SortStableFunc(idx, func(i, j int) int { return s.Dates[i].Compare(s.Dates[j]) })
+
+	res := &SeriesReturns{
+		SimulationAllocation: s.SimulationAllocation,
+		Returns:              make([]float64, len(idx)),
+		Dates:                make([]time.Time, len(idx)),
+		AnnualizationFactor:  s.AnnualizationFactor,
+	}
+	for i, k := range idx {
+		res.Returns[i] = s.Returns[k]
+		res.Dates[i] = s.Dates[k]
+	}
+	return res
+}
+
+func dateKey(t time.Time) string {
+	return t.UTC().Format(time.DateOnly)
+}
+
+// checkSharedFrequency refuses to join series stored at different frequencies on date. The shared dates
+// would pair daily returns with weekly ones, and drop would measure the weekly series against daily dates
+func checkSharedFrequency(series []*SeriesReturns) error {
+	for _, s := range series {
+		if s.AnnualizationFactor != series[0].AnnualizationFactor {
+			return fmt.Errorf("%s has %d returns a year and %s has %d, %s and %s alignment need every asset at one frequency",
+				series[0].Ticker, series[0].AnnualizationFactor, s.Ticker, s.AnnualizationFactor, AlignTruncate, AlignDropAsset)
+		}
+	}
+	return nil
+}
+
+func dropSparseSeries(series []*SeriesReturns, window *EffectiveWindow) []*SeriesReturns {
+	all := make(map[string]bool)
+	for _, s := range series {
+		for _, d := range s.Dates {
+			all[dateKey(d)] = true
+		}
+	}
+
+	kept := make([]*SeriesReturns, 0, len(series))
+	for _, s := range series {
+		if float64(len(s.Dates)) >= DefaultAlignmentCoverage*float64(len(all)) {
+			kept = append(kept, s)
+			continue
+		}
+
+		window.Dropped = append(window.Dropped, s.Ticker)
+		for _, a := range window.Assets {
+			if a.Id == s.Id {
+				a.Dropped = true
+			}
+		}
+	}
+	return kept
+}
+
+// innerJoinWindow keeps only the dates every series has a return for
+func innerJoinWindow(series []*SeriesReturns, window *EffectiveWindow) ([]*SeriesReturns, error) {
+	counts := make(map[string]int)
+	for _, s := range series {
+		for _, d := range s.Dates {
+			counts[dateKey(d)]++
+		}
+	}
+
+	res := make([]*SeriesReturns, len(series))
+	for i, s := range series {
+		res[i] = &SeriesReturns{SimulationAllocation: s.SimulationAllocation, AnnualizationFactor: s.AnnualizationFactor}
+		for k, d := range s.Dates {
+			if counts[dateKey(d)] == len(series) {
+				res[i].Returns = append(res[i].Returns, s.Returns[k])
+				res[i].Dates = append(res[i].Dates, d)
+			}
+		}
+	}
+
+	window.Observations = len(res[0].Dates)
+	if window.Observations < minAlignedObservations {
+		return nil, fmt.Errorf("only %d dates are shared by every asset, need at least %d", window.Observations, minAlignedObservations)
+	}
+	window.Start = res[0].Dates[0]
+	window.End = res[0].Dates[window.Observations-1]
+
+	return res, nil
+}
+
+// pairwiseWindow leaves every history intact, the window spans all of them and the observation
+// count is the smallest overlap between any two assets
+func pairwiseWindow(series []*SeriesReturns, window *EffectiveWindow) ([]*SeriesReturns, error) {
+	window.Observations = len(series[0].Dates)
+	window.Start = series[0].Dates[0]
+	window.End = series[0].Dates[len(series[0].Dates)-1]
+	for i, s := range series {
+		window.Observations = min(window.Observations, len(s.Dates))
+		if s.Dates[0].Before(window.Start) {
+			window.Start = s.Dates[0]
+		}
+		if s.Dates[len(s.Dates)-1].After(window.End) {
+			window.End = s.Dates[len(s.Dates)-1]
+		}
+
+		for _, o := range series[i+1:] {
+			a, _ := overlappingReturns(s, o)
+			if len(a) < minAlignedObservations {
+				return nil, fmt.Errorf("%s and %s only share %d dates, need at least %d", s.Ticker, o.Ticker, len(a), minAlignedObservations)
+			}
+			window.Observations = min(window.Observations, len(a))
+		}
+	}
+
+	return series, nil
+}
+
+// overlappingReturns returns the returns of both series on the dates they share
+func overlappingReturns(a, b *SeriesReturns) (x, y []float64) {
+	lookup := make(map[string]float64, len(b.Dates))
+	for k, d := range b.Dates {
+		lookup[dateKey(d)] = b.Returns[k]
+	}
+
+	for k, d := range a.Dates {
+		if v, ok := lookup[dateKey(d)]; ok {
+			x = append(x, a.Returns[k])
+			y = append(y, v)
+		}
+	}
+	return
+}
+
+// GetPairwiseCovarianceMatrix estimates each covariance from the dates both assets have returns for,
+// and each variance from the asset's full history. The result is not guaranteed to be positive definite.
+func GetPairwiseCovarianceMatrix(series []*SeriesReturns) (*mat.SymDense, error) {
+	n := len(series)
+	cov := mat.NewSymDense(n, nil)
+	for i := range n {
+		if len(series[i].Returns) < minAlignedObservations {
+			return nil, fmt.Errorf("%s has %d returns, need at least %d", series[i].Ticker, len(series[i].Returns), minAlignedObservations)
+		}
+		cov.SetSym(i, i, stat.Variance(series[i].Returns, nil))
+
+		for j := range i {
+			x, y := overlappingReturns(series[i], series[j])
+			if len(x) < minAlignedObservations {
+				return nil, fmt.Errorf("%s and %s only share %d dates, need at least %d", series[i].Ticker, series[j].Ticker, len(x), minAlignedObservations)
+			}
+			cov.SetSym(i, j, stat.Covariance(x, y, nil))
+		}
+	}
+	return cov, nil
+}
+
+func reweight(series []*SeriesReturns) {
+	total := 0.0
+	for _, s := range series {
+		total += s.Weight
+	}
+	if total <= 0 {
+		return
+	}
+	for _, s := range series {
+		s.Weight /= total
+	}
+}
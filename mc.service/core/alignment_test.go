@@ -0,0 +1,130 @@
+package core
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	e "mc.data/extensions"
+)
+
+// weeklySeries builds a series with one return per week starting at start, dates are newest first like the repo returns them
+func weeklySeries(id int32, ticker string, weight float64, start time.Time, returns []float64) *SeriesReturns {
+	s := &SeriesReturns{
+		SimulationAllocation: SimulationAllocation{Id: id, Ticker: ticker, Weight: weight},
+		AnnualizationFactor:  Weekly,
+	}
+	for i := len(returns) - 1; i >= 0; i-- {
+		s.Returns = append(s.Returns, returns[i])
+		s.Dates = append(s.Dates, start.AddDate(0, 0, 7*i))
+	}
+	return s
+}
+
+func alignmentFixture() ([]SimulationAllocation, []*SeriesReturns) {
+	start := time.Date(2024, time.January, 5, 0, 0, 0, 0, time.UTC)
+	long := []float64{0.01, -0.02, 0.03, 0.01, -0.01, 0.02, 0.00, 0.01, -0.03, 0.02}
+	series := []*SeriesReturns{
+		weeklySeries(1, "SPY", 0.5, start, long),
+		weeklySeries(2, "QQQ", 0.3, start, []float64{0.02, -0.01, 0.04, 0.02, -0.02, 0.03, 0.01, 0.00, -0.04, 0.03}),
+		weeklySeries(3, "IBIT", 0.2, start.AddDate(0, 0, 7*6), []float64{0.05, -0.08, 0.10, 0.02}), // listed later
+	}
+	allocations := []SimulationAllocation{series[0].SimulationAllocation, series[1].SimulationAllocation, series[2].SimulationAllocation}
+	return allocations, series
+}
+
+func Test_Alignment_TruncatesToCommonWindow(t *testing.T) {
+	allocations, series := alignmentFixture()
+
+	aligned, window, err := AlignSeriesReturns("", allocations, series)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	e.AssertAreEqual(t, "policy", AlignTruncate, window.Policy)
+	e.AssertAreEqual(t, "observations", 4, window.Observations)
+	e.AssertAreEqual(t, "start", "2024-02-16", e.FmtShort(window.Start))
+	e.AssertAreEqual(t, "end", "2024-03-08", e.FmtShort(window.End))
+	e.AssertAreEqual(t, "spy observations", 10, window.Assets[0].Observations)
+	for _, s := range aligned {
+		e.AssertAreEqual(t, s.Ticker+" length", 4, len(s.Returns))
+		e.AssertAreEqual(t, s.Ticker+" ascending", true, s.Dates[0].Before(s.Dates[3]))
+	}
+	e.AssertAreEqual(t, "spy first aligned return", 0.00, aligned[0].Returns[0])
+	e.AssertAreEqual(t, "weight untouched", 0.2, aligned[2].Weight)
+}
+
+func Test_Alignment_DropsShortHistories(t *testing.T) {
+	allocations, series := alignmentFixture()
+
+	aligned, window, err := AlignSeriesReturns(AlignDropAsset, allocations, series)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	e.AssertAreEqual(t, "assets kept", 2, len(aligned))
+	e.AssertAreEqual(t, "dropped", "IBIT", window.Dropped[0])
+	e.AssertAreEqual(t, "dropped flagged", true, window.Assets[2].Dropped)
+	e.AssertAreEqual(t, "observations", 10, window.Observations)
+	e.AssertAreEqual(t, "spy reweighted", true, math.Abs(aligned[0].Weight-0.625) < 1e-12)
+	e.AssertAreEqual(t, "qqq reweighted", true, math.Abs(aligned[1].Weight-0.375) < 1e-12)
+}
+
+func Test_Alignment_PairwiseKeepsHistories(t *testing.T) {
+	allocations, series := alignmentFixture()
+
+	aligned, window, err := AlignSeriesReturns(AlignPairwise, allocations, series)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	e.AssertAreEqual(t, "spy length", 10, len(aligned[0].Returns))
+	e.AssertAreEqual(t, "ibit length", 4, len(aligned[2].Returns))
+	e.AssertAreEqual(t, "observations", 4, window.Observations)
+	e.AssertAreEqual(t, "start", "2024-01-05", e.FmtShort(window.Start))
+
+	cov, err := GetPairwiseCovarianceMatrix(aligned)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the spy/ibit covariance only uses the last four weeks, spy's variance uses all ten
+	spyTail := aligned[0].Returns[6:]
+	mean := func(v []float64) float64 { return e.Sum(v) / float64(len(v)) }
+	expected := 0.0
+	for i := range spyTail {
+		expected += (spyTail[i] - mean(spyTail)) * (aligned[2].Returns[i] - mean(aligned[2].Returns))
+	}
+	expected /= float64(len(spyTail) - 1)
+	e.AssertAreEqual(t, "pairwise covariance", true, math.Abs(cov.At(0, 2)-expected) < 1e-12)
+	e.AssertAreEqual(t, "spy variance", true, math.Abs(cov.At(0, 0)-0.00036) < 1e-12)
+}
+
+func Test_Alignment_Errors(t *testing.T) {
+	allocations, series := alignmentFixture()
+
+	if _, _, err := AlignSeriesReturns("outer", allocations, series); err == nil {
+		t.Fatalf("expected an unknown policy to be rejected")
+	}
+
+	allocations = append(allocations, SimulationAllocation{Id: 4, Ticker: "NEW", Weight: 0})
+	if _, _, err := AlignSeriesReturns(AlignTruncate, allocations, series); err == nil {
+		t.Fatalf("expected an asset without returns to be rejected")
+	}
+
+	// daily returns cannot be joined on date with weekly ones
+	allocations, series = alignmentFixture()
+	series[1].AnnualizationFactor = Daily
+	for _, policy := range []string{AlignTruncate, AlignDropAsset} {
+		if _, _, err := AlignSeriesReturns(policy, allocations, series); err == nil || !strings.Contains(err.Error(), "one frequency") {
+			t.Fatalf("expected mixed frequencies to be rejected under %s, got %v", policy, err)
+		}
+	}
+	series[1].AnnualizationFactor = Weekly
+
+	// unaligned series are refused rather than building a malformed matrix
+	if _, err := GetStatisticalResources(SimulationRequest{}, series); err == nil {
+		t.Fatalf("expected unaligned returns to be rejected")
+	}
+}
@@ -0,0 +1,298 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"gonum.org/v1/gonum/stat"
+
+	m "mc.data/models"
+)
+
+// when a backtest trades back to the target weights
+const (
+	RebalanceNone      = "none" // buy and hold
+	RebalanceMonthly   = "monthly"
+	RebalanceQuarterly = "quarterly"
+	RebalanceYearly    = "yearly"
+	RebalanceThreshold = "threshold" // whenever any weight drifts more than Threshold from its target
+)
+
+const (
+	DefaultBacktestCapital   = 10_000.0
+	DefaultRebalanceDrift    = 0.05
+	minBacktestObservations  = 2
+	daysPerYear              = 365.25
+	basisPointsPerPercentage = 10_000.0
+)
+
+type BacktestRequest struct {
+	Allocations        []SimulationAllocation `json:"allocations"`
+	Start              time.Time              `json:"start"`
+	End                time.Time              `json:"end"`                // zero runs through the latest stored price
+	InitialCapital     float64                `json:"initialcapital"`     // zero uses DefaultBacktestCapital
+	Rebalance          string                 `json:"rebalance"`          // none (default), monthly, quarterly, yearly or threshold
+	Threshold          float64                `json:"threshold"`          // absolute weight drift for threshold rebalancing, zero uses DefaultRebalanceDrift
+	TransactionCostBps float64                `json:"transactioncostbps"` // charged on the traded notional of every rebalance
+	RiskFreeRate       float64                `json:"riskfreerate"`       // annual, used by sharpe and sortino
+}
+
+type BacktestResult struct {
+	Start            time.Time         `json:"start"`
+	End              time.Time         `json:"end"`
+	InitialCapital   float64           `json:"initialcapital"`
+	FinalValue       float64           `json:"finalvalue"`
+	TotalReturn      float64           `json:"totalreturn"`
+	Cagr             float64           `json:"cagr"`
+	Volatility       float64           `json:"volatility"` // annualized
+	Sharpe           float64           `json:"sharpe"`
+	Sortino          float64           `json:"sortino"`
+	MaxDrawdown      float64           `json:"maxdrawdown"`
+	MaxDrawdownDate  time.Time         `json:"maxdrawdowndate"`
+	Turnover         float64           `json:"turnover"` // annualized one way turnover as a share of portfolio value
+	Rebalances       int               `json:"rebalances"`
+	TransactionCosts float64           `json:"transactioncosts"`
+	Observations     int               `json:"observations"`
+	EquityCurve      []*BacktestPoint  `json:"equitycurve"`
+	FinalWeights     []*BacktestWeight `json:"finalweights"`
+}
+
+type BacktestPoint struct {
+	Date  time.Time `json:"date"`
+	Value float64   `json:"value"`
+}
+
+type BacktestWeight struct {
+	Id     int32   `json:"id"`
+	Ticker string  `json:"ticker"`
+	Weight float64 `json:"weight"`
+}
+
+func (br *BacktestRequest) Validate() error {
+	if err := (SimulationRequest{Allocations: br.Allocations}).Validate(); err != nil {
+		return err
+	}
+	if br.Start.IsZero() {
+		return fmt.Errorf("start is required")
+	}
+	if !br.End.IsZero() && !br.Start.Before(br.End) {
+		return fmt.Errorf("start must be before end")
+	}
+	if br.InitialCapital < 0 || br.TransactionCostBps < 0 || br.Threshold < 0 {
+		return fmt.Errorf("initial capital, transaction costs and threshold cannot be negative")
+	}
+
+	br.Rebalance = strings.ToLower(strings.TrimSpace(br.Rebalance))
+	switch br.Rebalance {
+	case "":
+		br.Rebalance = RebalanceNone
+	case RebalanceNone, RebalanceMonthly, RebalanceQuarterly, RebalanceYearly, RebalanceThreshold:
+	default:
+		return fmt.Errorf("unknown rebalance rule %q", br.Rebalance)
+	}
+
+	if br.InitialCapital == 0 {
+		br.InitialCapital = DefaultBacktestCapital
+	}
+	if br.Threshold == 0 {
+		br.Threshold = DefaultRebalanceDrift
+	}
+	return nil
+}
+
+// RunBacktest replays the allocation over the stored adjusted closes, so dividends are reinvested
+func (sc *ServiceContext) RunBacktest(req BacktestRequest) (*BacktestResult, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	if req.End.IsZero() {
+		req.End = time.Now()
+	}
+
+	ids := make([]int32, len(req.Allocations))
+	annualizationFactor := Daily
+	for i, a := range req.Allocations {
+		md, err := sc.PostgresConnection.GetMetaDataById(sc.Context, a.Id)
+		if err != nil {
+			return nil, err
+		}
+		if md == nil {
+			return nil, fmt.Errorf("no stored series for allocation %d", a.Id)
+		}
+
+		ids[i] = a.Id
+		req.Allocations[i].Ticker = md.Symbol
+		// the joined dates are only as fine as the coarsest series
+		annualizationFactor = min(annualizationFactor, FrequencyAnnualizationFactor(md.Frequency))
+	}
+
+	bars, err := sc.PostgresConnection.GetTimeSeriesDataBetween(sc.Context, ids, req.Start, req.End)
+	if err != nil {
+		return nil, err
+	}
+
+	bySource := make(map[int32][]*m.TimeSeriesData, len(ids))
+	for _, b := range bars {
+		bySource[b.SourceId] = append(bySource[b.SourceId], b)
+	}
+
+	return runBacktest(req, bySource, annualizationFactor)
+}
+
+// runBacktest steps through the dates every asset has a price for. The initial purchase is free, every
+// rebalance pays TransactionCostBps on the notional traded.
+func runBacktest(req BacktestRequest, bars map[int32][]*m.TimeSeriesData, annualizationFactor int) (*BacktestResult, error) {
+	dates, prices := joinBacktestPrices(req.Allocations, bars)
+	if len(dates) < minBacktestObservations {
+		return nil, fmt.Errorf("the allocation only shares %d priced dates between %s and %s, need at least %d",
+			len(dates), req.Start.Format(time.DateOnly), req.End.Format(time.DateOnly), minBacktestObservations)
+	}
+
+	n := len(req.Allocations)
+	holdings := make([]float64, n)
+	for i, a := range req.Allocations {
+		holdings[i] = req.InitialCapital * a.Weight / prices[0][i]
+	}
+
+	res := &BacktestResult{
+		Start:          dates[0],
+		End:            dates[len(dates)-1],
+		InitialCapital: req.InitialCapital,
+		Observations:   len(dates),
+		EquityCurve:    []*BacktestPoint{{Date: dates[0], Value: req.InitialCapital}},
+	}
+
+	totalTurnover := 0.0
+	values := make([]float64, n)
+	for t := 1; t < len(dates); t++ {
+		total := 0.0
+		for i := range n {
+			values[i] = holdings[i] * prices[t][i]
+			total += values[i]
+		}
+
+		if shouldRebalance(req, dates[t-1], dates[t], values, total) {
+			traded := 0.0
+			for i, a := range req.Allocations {
+				traded += math.Abs(total*a.Weight - values[i])
+			}
+			cost := traded * req.TransactionCostBps / basisPointsPerPercentage
+			totalTurnover += traded / 2 / total
+
+			total -= cost
+			for i, a := range req.Allocations {
+				holdings[i] = total * a.Weight / prices[t][i]
+			}
+			res.TransactionCosts += cost
+			res.Rebalances++
+		}
+
+		res.EquityCurve = append(res.EquityCurve, &BacktestPoint{Date: dates[t], Value: total})
+	}
+
+	final := res.EquityCurve[len(res.EquityCurve)-1].Value
+	res.FinalValue = final
+	res.TotalReturn = final/req.InitialCapital - 1
+	for i, a := range req.Allocations {
+		res.FinalWeights = append(res.FinalWeights, &BacktestWeight{Id: a.Id, Ticker: a.Ticker, Weight: holdings[i] * prices[len(dates)-1][i] / final})
+	}
+
+	years := res.End.Sub(res.Start).Hours() / 24 / daysPerYear
+	if years > 0 {
+		res.Cagr = math.Pow(final/req.InitialCapital, 1/years) - 1
+		res.Turnover = totalTurnover / years
+	}
+
+	fillBacktestRisk(res, annualizationFactor, req.RiskFreeRate)
+	return res, nil
+}
+
+// joinBacktestPrices keeps the dates every asset has an adjusted close for, prices[t][i] lines up with the allocations
+func joinBacktestPrices(allocations []SimulationAllocation, bars map[int32][]*m.TimeSeriesData) ([]time.Time, [][]float64) {
+	byDate := make(map[string][]float64)
+	dateOf := make(map[string]time.Time)
+	for i, a := range allocations {
+		for _, b := range bars[a.Id] {
+			key := dateKey(b.Timestamp)
+			if byDate[key] == nil {
+				byDate[key] = make([]float64, len(allocations))
+				dateOf[key] = b.Timestamp
+			}
+			byDate[key][i] = b.AdjustedClose
+		}
+	}
+
+	dates := []time.Time{}
+	for key, p := range byDate {
+		complete := true
+		for _, v := range p {
+			complete = complete && v > 0
+		}
+		if complete {
+			dates = append(dates, dateOf[key])
+		}
+	}
+	slices.SortFunc(dates, func(a, b time.Time) int { return a.Compare(b) })
+
+	prices := make([][]float64, len(dates))
+	for t, d := range dates {
+		prices[t] = byDate[dateKey(d)]
+	}
+	return dates, prices
+}
+
+func shouldRebalance(req BacktestRequest, previous, current time.Time, values []float64, total float64) bool {
+	switch req.Rebalance {
+	case RebalanceMonthly:
+		return previous.Month() != current.Month() || previous.Year() != current.Year()
+	case RebalanceQuarterly:
+		return (previous.Month()-1)/3 != (current.Month()-1)/3 || previous.Year() != current.Year()
+	case RebalanceYearly:
+		return previous.Year() != current.Year()
+	case RebalanceThreshold:
+		for i, a := range req.Allocations {
+			if math.Abs(values[i]/total-a.Weight) > req.Threshold {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fillBacktestRisk computes the return based statistics from the equity curve
+func fillBacktestRisk(res *BacktestResult, annualizationFactor int, riskFreeRate float64) {
+	af := float64(annualizationFactor)
+	returns := make([]float64, len(res.EquityCurve)-1)
+	peak := res.EquityCurve[0].Value
+	for t := 1; t < len(res.EquityCurve); t++ {
+		value := res.EquityCurve[t].Value
+		returns[t-1] = value/res.EquityCurve[t-1].Value - 1
+
+		peak = math.Max(peak, value)
+		if drawdown := 1 - value/peak; drawdown > res.MaxDrawdown {
+			res.MaxDrawdown = drawdown
+			res.MaxDrawdownDate = res.EquityCurve[t].Date
+		}
+	}
+
+	excess := stat.Mean(returns, nil)*af - riskFreeRate
+	if len(returns) > 1 {
+		res.Volatility = stat.StdDev(returns, nil) * math.Sqrt(af)
+	}
+	if res.Volatility > 0 {
+		res.Sharpe = excess / res.Volatility
+	}
+
+	downside := 0.0
+	for _, r := range returns {
+		if d := math.Min(0, r-riskFreeRate/af); d < 0 {
+			downside += d * d
+		}
+	}
+	if downside > 0 {
+		res.Sortino = excess / (math.Sqrt(downside/float64(len(returns))) * math.Sqrt(af))
+	}
+}
@@ -0,0 +1,105 @@
+package core
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	e "mc.data/extensions"
+	m "mc.data/models"
+)
+
+func backtestFixture() (BacktestRequest, map[int32][]*m.TimeSeriesData) {
+	start := time.Date(2021, time.January, 4, 0, 0, 0, 0, time.UTC)
+	req := BacktestRequest{
+		Allocations:        []SimulationAllocation{{Id: 1, Ticker: "SPY", Weight: 0.5}, {Id: 2, Ticker: "BIL", Weight: 0.5}},
+		Start:              start,
+		End:                start.AddDate(0, 0, 70),
+		TransactionCostBps: 10,
+	}
+	// SPY jumps 20% on the first bar of February, BIL stays flat
+	bars := map[int32][]*m.TimeSeriesData{
+		1: stressBars(1, start, 100, 100, 100, 100, 120, 120, 120, 120, 120, 120),
+		2: stressBars(2, start, 100, 100, 100, 100, 100, 100, 100, 100, 100, 100),
+	}
+	return req, bars
+}
+
+func Test_Backtest_BuyAndHoldDrifts(t *testing.T) {
+	req, bars := backtestFixture()
+	if err := req.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	res, err := runBacktest(req, bars, Weekly)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	e.AssertAreEqual(t, "observations", 10, res.Observations)
+	e.AssertAreEqual(t, "default capital", DefaultBacktestCapital, res.InitialCapital)
+	e.AssertAreEqual(t, "final value", true, math.Abs(res.FinalValue-11_000) < 1e-9)
+	e.AssertAreEqual(t, "no rebalances", 0, res.Rebalances)
+	e.AssertAreEqual(t, "no costs", 0.0, res.TransactionCosts)
+	e.AssertAreEqual(t, "spy drifted", true, math.Abs(res.FinalWeights[0].Weight-6.0/11) < 1e-12)
+	e.AssertAreEqual(t, "no drawdown", 0.0, res.MaxDrawdown)
+}
+
+func Test_Backtest_RebalancesWithCosts(t *testing.T) {
+	req, bars := backtestFixture()
+	req.Rebalance = RebalanceMonthly
+	if err := req.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	res, err := runBacktest(req, bars, Weekly)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// february trades 500 of each asset back to 50/50 and pays 10bps on the 1000 traded, march has nothing to trade
+	e.AssertAreEqual(t, "rebalances", 2, res.Rebalances)
+	e.AssertAreEqual(t, "costs", true, math.Abs(res.TransactionCosts-1) < 1e-9)
+	e.AssertAreEqual(t, "final value", true, math.Abs(res.FinalValue-10_999) < 1e-9)
+	e.AssertAreEqual(t, "back on target", true, math.Abs(res.FinalWeights[0].Weight-0.5) < 1e-12)
+	years := res.End.Sub(res.Start).Hours() / 24 / daysPerYear
+	e.AssertAreEqual(t, "turnover", true, math.Abs(res.Turnover-500.0/11_000/years) < 1e-12)
+
+	// a 4.5% drift only trips the tighter band
+	req.Rebalance = RebalanceThreshold
+	res, _ = runBacktest(req, bars, Weekly)
+	e.AssertAreEqual(t, "inside the default band", 0, res.Rebalances)
+	req.Threshold = 0.04
+	res, _ = runBacktest(req, bars, Weekly)
+	e.AssertAreEqual(t, "outside the tighter band", 1, res.Rebalances)
+}
+
+func Test_Backtest_RiskStatistics(t *testing.T) {
+	start := time.Date(2021, time.January, 4, 0, 0, 0, 0, time.UTC)
+	res := &BacktestResult{}
+	for i, v := range []float64{100, 110, 99, 104, 121} {
+		res.EquityCurve = append(res.EquityCurve, &BacktestPoint{Date: start.AddDate(0, 0, 7*i), Value: v})
+	}
+
+	fillBacktestRisk(res, Weekly, 0)
+
+	e.AssertAreEqual(t, "max drawdown", true, math.Abs(res.MaxDrawdown-0.1) < 1e-12)
+	e.AssertAreEqual(t, "trough date", res.EquityCurve[2].Date, res.MaxDrawdownDate)
+	e.AssertAreEqual(t, "positive sharpe", true, res.Sharpe > 0)
+	// only one return is below zero so downside deviation is smaller than volatility
+	e.AssertAreEqual(t, "sortino above sharpe", true, res.Sortino > res.Sharpe)
+}
+
+func Test_Backtest_ValidatesRequest(t *testing.T) {
+	req, _ := backtestFixture()
+	req.Rebalance = "daily"
+	if err := req.Validate(); err == nil {
+		t.Fatalf("expected an unknown rebalance rule to fail")
+	}
+
+	req, _ = backtestFixture()
+	req.End = req.Start.AddDate(0, 0, -1)
+	if err := req.Validate(); err == nil {
+		t.Fatalf("expected an end before the start to fail")
+	}
+}
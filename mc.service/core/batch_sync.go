@@ -0,0 +1,153 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	ex "mc.data/extensions"
+)
+
+const (
+	// the provider's rate limit is what actually bounds throughput, workers only overlap db work with waiting
+	DefaultSyncWorkers = 2
+	MaxSyncWorkers     = 8
+	MaxBatchSymbols    = 500
+)
+
+type SyncStatus string
+
+const (
+	SyncStatusSynced SyncStatus = "synced"
+	SyncStatusFresh  SyncStatus = "fresh"
+	SyncStatusFailed SyncStatus = "failed"
+)
+
+type SymbolSyncResult struct {
+	Symbol        string     `json:"symbol"`
+	Status        SyncStatus `json:"status"`
+	LastRefreshed string     `json:"lastrefreshed,omitempty"`
+	Error         string     `json:"error,omitempty"`
+}
+
+type BatchSyncReport struct {
+	Watchlist string              `json:"watchlist,omitempty"`
+	Synced    int                 `json:"synced"`
+	Fresh     int                 `json:"fresh"`
+	Failed    int                 `json:"failed"`
+	Results   []*SymbolSyncResult `json:"results"`
+}
+
+// SyncWatchlist syncs every symbol on a stored watchlist, see SyncSymbols for ctx
+func (sc *ServiceContext) SyncWatchlist(ctx context.Context, name string, workers int) (*BatchSyncReport, error) {
+	wl, err := sc.PostgresConnection.GetWatchlist(sc.Context, name)
+	if err != nil {
+		return nil, err
+	}
+	if wl == nil {
+		return nil, fmt.Errorf("watchlist %s does not exist", name)
+	}
+
+	res, err := sc.SyncSymbols(ctx, wl.Symbols, workers)
+	if err != nil {
+		return nil, err
+	}
+
+	res.Watchlist = wl.Name
+	return res, nil
+}
+
+// SyncSymbols runs SyncSymbolTimeSeriesData over a bounded worker pool. A failing symbol does not stop the
+// batch, every symbol gets an outcome in the report, in the order given. ctx is the caller's, a request
+// or the scheduler, once it is done the symbols not yet started fail
+func (sc *ServiceContext) SyncSymbols(ctx context.Context, symbols []string, workers int) (*BatchSyncReport, error) {
+	symbols = NormalizeSymbols(symbols)
+	if len(symbols) == 0 {
+		return nil, fmt.Errorf("at least one symbol is required")
+	}
+	if len(symbols) > MaxBatchSymbols {
+		return nil, fmt.Errorf("a batch can sync at most %d symbols, got %d", MaxBatchSymbols, len(symbols))
+	}
+
+	if workers <= 0 {
+		workers = DefaultSyncWorkers
+	}
+	workers = min(workers, MaxSyncWorkers, len(symbols))
+
+	results := make([]*SymbolSyncResult, len(symbols))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = sc.syncForBatch(ctx, symbols[i])
+			}
+		}()
+	}
+
+	for i := range symbols {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	report := &BatchSyncReport{Results: results}
+	for _, r := range results {
+		switch r.Status {
+		case SyncStatusSynced:
+			report.Synced++
+		case SyncStatusFresh:
+			report.Fresh++
+		default:
+			report.Failed++
+		}
+	}
+
+	return report, nil
+}
+
+func (sc *ServiceContext) syncForBatch(ctx context.Context, symbol string) *SymbolSyncResult {
+	res := &SymbolSyncResult{Symbol: symbol}
+
+	// a cancelled request or shutdown fails whatever has not started yet
+	if err := ctx.Err(); err != nil {
+		res.Status = SyncStatusFailed
+		res.Error = err.Error()
+		return res
+	}
+
+	lut, err := sc.SyncSymbolTimeSeriesData(symbol)
+	if !lut.IsZero() {
+		res.LastRefreshed = ex.FmtShort(lut)
+	}
+
+	switch {
+	case err == nil:
+		res.Status = SyncStatusSynced
+	case errors.Is(err, ErrDataIsFresh):
+		res.Status = SyncStatusFresh
+	default:
+		res.Status = SyncStatusFailed
+		res.Error = err.Error()
+	}
+
+	return res
+}
+
+// NormalizeSymbols trims and upper cases symbols, dropping blanks and duplicates but keeping the order
+func NormalizeSymbols(symbols []string) []string {
+	res := make([]string, 0, len(symbols))
+	for _, s := range symbols {
+		s = strings.ToUpper(strings.TrimSpace(s))
+		if s != "" && !slices.Contains(res, s) {
+			res = append(res, s)
+		}
+	}
+	return res
+}
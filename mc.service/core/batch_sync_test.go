@@ -0,0 +1,49 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	e "mc.data/extensions"
+)
+
+func Test_BatchSync_NormalizeSymbols(t *testing.T) {
+	res := NormalizeSymbols([]string{" spy", "QQQ", "", "Spy ", "vti"})
+	if !slices.Equal([]string{"SPY", "QQQ", "VTI"}, res) {
+		t.Fatalf("unexpected normalized symbols %v", res)
+	}
+}
+
+func Test_BatchSync_RejectsEmptyAndOversizedBatches(t *testing.T) {
+	sc := ServiceContext{Context: context.Background()}
+
+	if _, err := sc.SyncSymbols(sc.Context, []string{" ", ""}, 0); err == nil {
+		t.Fatalf("expected an error for a batch with no symbols")
+	}
+
+	symbols := make([]string, MaxBatchSymbols+1)
+	for i := range symbols {
+		symbols[i] = fmt.Sprintf("S%d", i)
+	}
+	_, err := sc.SyncSymbols(sc.Context, symbols, 0)
+	if err == nil {
+		t.Fatalf("expected an error for a batch over %d symbols", MaxBatchSymbols)
+	}
+	e.AssertAreEqual(t, "error mentions limit", true, strings.Contains(err.Error(), "at most"))
+}
+
+func Test_BatchSync_CancelledRequestFailsTheRest(t *testing.T) {
+	sc := ServiceContext{Context: context.Background()}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	report, err := sc.SyncSymbols(ctx, []string{"SPY", "QQQ"}, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	e.AssertAreEqual(t, "failed", 2, report.Failed)
+	e.AssertAreEqual(t, "reason", context.Canceled.Error(), report.Results[0].Error)
+}
@@ -0,0 +1,239 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+const (
+	DefaultRiskAversion = 2.5  // a typical market price of risk, excess return over variance
+	DefaultTau          = 0.05 // uncertainty of the equilibrium returns relative to the covariance
+
+	// a view held with full confidence still gets a sliver of uncertainty so the system stays invertible
+	minViewUncertainty = 1e-10
+)
+
+// BlackLittermanConfig replaces the historical mean returns with Black-Litterman posterior returns, the
+// equilibrium implied by the benchmark weights blended with the views
+type BlackLittermanConfig struct {
+	BenchmarkWeights map[string]float64 `json:"benchmarkweights"` // market cap or benchmark weight per ticker, empty uses the allocation
+	RiskAversion     float64            `json:"riskaversion"`     // zero uses DefaultRiskAversion
+	Tau              float64            `json:"tau"`              // zero uses DefaultTau
+	RiskFreeRate     float64            `json:"riskfreerate"`     // annual, added to the implied excess returns
+	Views            []*View            `json:"views"`
+}
+
+// View is an opinion on annual returns. With only Long it is absolute, the equally weighted Long assets
+// return Return. With Short as well it is relative, Long outperforms Short by Return.
+type View struct {
+	Long       []string `json:"long"`
+	Short      []string `json:"short"`
+	Return     float64  `json:"return"`
+	Confidence float64  `json:"confidence"` // in (0, 1], how far the posterior moves from equilibrium towards the view
+}
+
+// BlackLittermanResult reports how the expected returns moved, all figures are annualized
+type BlackLittermanResult struct {
+	RiskAversion float64                `json:"riskaversion"`
+	Tau          float64                `json:"tau"`
+	Assets       []*BlackLittermanAsset `json:"assets"`
+}
+
+type BlackLittermanAsset struct {
+	Id              int32   `json:"id"`
+	Ticker          string  `json:"ticker"`
+	BenchmarkWeight float64 `json:"benchmarkweight"`
+	Historical      float64 `json:"historical"`  // the mean return it replaces
+	Equilibrium     float64 `json:"equilibrium"` // implied by the benchmark weights
+	Posterior       float64 `json:"posterior"`
+	Sigma           float64 `json:"sigma"` // posterior volatility
+}
+
+func (bl *BlackLittermanConfig) Validate() error {
+	if bl.RiskAversion < 0 {
+		return fmt.Errorf("risk aversion cannot be negative")
+	}
+	if bl.Tau < 0 || bl.Tau > 1 {
+		return fmt.Errorf("tau must be between 0 and 1, got %v", bl.Tau)
+	}
+	for ticker, w := range bl.BenchmarkWeights {
+		if w < 0 {
+			return fmt.Errorf("benchmark weight for %s cannot be negative", ticker)
+		}
+	}
+	for i, v := range bl.Views {
+		if len(v.Long) == 0 {
+			return fmt.Errorf("view %d needs at least one long ticker", i+1)
+		}
+		if v.Confidence <= 0 || v.Confidence > 1 {
+			return fmt.Errorf("view %d needs a confidence in (0, 1], got %v", i+1, v.Confidence)
+		}
+	}
+	return nil
+}
+
+// BlackLittermanPosterior blends the equilibrium returns with the views. cov and mu are annualized and in
+// the order of assets, the returned covariance includes the uncertainty of the posterior mean.
+func BlackLittermanPosterior(config *BlackLittermanConfig, assets []*SeriesReturns, mu []float64, cov *mat.SymDense) ([]float64, *mat.SymDense, *BlackLittermanResult, error) {
+	n := len(assets)
+	index := make(map[string]int, n)
+	for i, a := range assets {
+		index[strings.ToUpper(a.Ticker)] = i
+	}
+
+	res := &BlackLittermanResult{RiskAversion: config.RiskAversion, Tau: config.Tau}
+	if res.RiskAversion == 0 {
+		res.RiskAversion = DefaultRiskAversion
+	}
+	if res.Tau == 0 {
+		res.Tau = DefaultTau
+	}
+
+	weights, err := benchmarkWeights(config.BenchmarkWeights, assets, index)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// reverse optimization, the excess returns that make the benchmark mean-variance optimal
+	var pi mat.VecDense
+	pi.MulVec(cov, mat.NewVecDense(n, weights))
+	pi.ScaleVec(res.RiskAversion, &pi)
+
+	var tauCov mat.SymDense
+	tauCov.ScaleSym(res.Tau, cov)
+
+	posteriorMu := mat.VecDenseCopyOf(&pi)
+	posteriorCov := mat.NewSymDense(n, nil)
+	posteriorCov.AddSym(cov, &tauCov)
+
+	if k := len(config.Views); k > 0 {
+		p := mat.NewDense(k, n, nil)
+		q := mat.NewVecDense(k, nil)
+		for row, v := range config.Views {
+			if err := setViewRow(p, row, v, index); err != nil {
+				return nil, nil, nil, err
+			}
+			// views are on total returns, the equilibrium is in excess of the risk free rate
+			excess := v.Return
+			if len(v.Short) == 0 {
+				excess -= config.RiskFreeRate
+			}
+			q.SetVec(row, excess)
+		}
+
+		// omega is diagonal, scaled from each view portfolio's own prior variance by its confidence
+		var viewCov mat.Dense
+		viewCov.Product(p, &tauCov, p.T())
+		omega := mat.NewDiagDense(k, nil)
+		for row, v := range config.Views {
+			omega.SetDiag(row, math.Max((1-v.Confidence)/v.Confidence*viewCov.At(row, row), minViewUncertainty))
+		}
+
+		var precision mat.Dense
+		precision.Add(&viewCov, omega)
+		if err := precision.Inverse(&precision); err != nil {
+			return nil, nil, nil, fmt.Errorf("error inverting the view covariance: %w", err)
+		}
+
+		// gain = tau cov p' (p tau cov p' + omega)^-1
+		var gain mat.Dense
+		gain.Product(&tauCov, p.T(), &precision)
+
+		var surprise, implied mat.VecDense
+		implied.MulVec(p, &pi)
+		surprise.SubVec(q, &implied)
+
+		var shift mat.VecDense
+		shift.MulVec(&gain, &surprise)
+		posteriorMu.AddVec(posteriorMu, &shift)
+
+		var reduction mat.Dense
+		reduction.Product(&gain, p, &tauCov)
+		for i := range n {
+			for j := i; j < n; j++ {
+				// symmetrize, the product is symmetric up to rounding
+				r := (reduction.At(i, j) + reduction.At(j, i)) / 2
+				posteriorCov.SetSym(i, j, posteriorCov.At(i, j)-r)
+			}
+		}
+	}
+
+	posterior := make([]float64, n)
+	for i, a := range assets {
+		posterior[i] = posteriorMu.AtVec(i) + config.RiskFreeRate
+		res.Assets = append(res.Assets, &BlackLittermanAsset{
+			Id:              a.Id,
+			Ticker:          a.Ticker,
+			BenchmarkWeight: weights[i],
+			Historical:      mu[i],
+			Equilibrium:     pi.AtVec(i) + config.RiskFreeRate,
+			Posterior:       posterior[i],
+			Sigma:           math.Sqrt(posteriorCov.At(i, i)),
+		})
+	}
+	return posterior, posteriorCov, res, nil
+}
+
+// benchmarkWeights lines the benchmark up with the assets, falling back to the allocation's weights
+func benchmarkWeights(requested map[string]float64, assets []*SeriesReturns, index map[string]int) ([]float64, error) {
+	res := make([]float64, len(assets))
+	if len(requested) == 0 {
+		for i, a := range assets {
+			res[i] = a.Weight
+		}
+		return res, nil
+	}
+
+	total := 0.0
+	for ticker, w := range requested {
+		i, ok := index[strings.ToUpper(strings.TrimSpace(ticker))]
+		if !ok {
+			return nil, fmt.Errorf("benchmark weight for %s which is not allocated", ticker)
+		}
+		res[i] = w
+		total += w
+	}
+	if total <= 0 {
+		return nil, fmt.Errorf("benchmark weights must sum to more than zero")
+	}
+	for i := range res {
+		res[i] /= total
+	}
+	return res, nil
+}
+
+// setViewRow writes the view's portfolio, long legs sum to one and short legs to minus one
+func setViewRow(p *mat.Dense, row int, v *View, index map[string]int) error {
+	for _, leg := range []struct {
+		tickers []string
+		sign    float64
+	}{{v.Long, 1}, {v.Short, -1}} {
+		for _, t := range leg.tickers {
+			i, ok := index[strings.ToUpper(strings.TrimSpace(t))]
+			if !ok {
+				return fmt.Errorf("view %d references %s which is not allocated", row+1, t)
+			}
+			if p.At(row, i) != 0 {
+				return fmt.Errorf("view %d has %s more than once", row+1, t)
+			}
+			p.Set(row, i, leg.sign/float64(len(leg.tickers)))
+		}
+	}
+	return nil
+}
+
+// periodCovariance undoes annualizedCovariance
+func periodCovariance(cov *mat.SymDense, seriesReturns []*SeriesReturns) *mat.SymDense {
+	n := cov.SymmetricDim()
+	res := mat.NewSymDense(n, nil)
+	for i := range n {
+		for j := i; j < n; j++ {
+			af := math.Sqrt(float64(seriesReturns[i].AnnualizationFactor * seriesReturns[j].AnnualizationFactor))
+			res.SetSym(i, j, cov.At(i, j)/af)
+		}
+	}
+	return res
+}
@@ -0,0 +1,106 @@
+package core
+
+import (
+	"math"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat"
+
+	e "mc.data/extensions"
+)
+
+func blackLittermanFixture() ([]*SeriesReturns, []float64, *mat.SymDense) {
+	assets := optimizationAssets("SPY", "EFA", "BND")
+	assets[0].Weight, assets[1].Weight, assets[2].Weight = 0.5, 0.2, 0.3
+	cov := mat.NewSymDense(3, []float64{
+		0.0225, 0.0180, 0.0015,
+		0.0180, 0.0289, 0.0010,
+		0.0015, 0.0010, 0.0036,
+	})
+	return assets, []float64{0.12, 0.02, 0.03}, cov
+}
+
+func Test_BlackLitterman_WithoutViewsReturnsEquilibrium(t *testing.T) {
+	assets, mu, cov := blackLittermanFixture()
+	config := &BlackLittermanConfig{RiskFreeRate: 0.02}
+
+	posterior, posteriorCov, res, err := BlackLittermanPosterior(config, assets, mu, cov)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// 2.5 * (0.0225 * 0.5 + 0.018 * 0.2 + 0.0015 * 0.3) + 0.02
+	e.AssertAreEqual(t, "spy equilibrium", true, math.Abs(posterior[0]-0.05825) < 1e-12)
+	e.AssertAreEqual(t, "historical kept for reference", 0.12, res.Assets[0].Historical)
+	e.AssertAreEqual(t, "default tau", DefaultTau, res.Tau)
+	e.AssertAreEqual(t, "posterior covariance", true, math.Abs(posteriorCov.At(0, 1)-0.018*1.05) < 1e-12)
+	for i, a := range res.Assets {
+		e.AssertAreEqual(t, a.Ticker+" unchanged", true, math.Abs(a.Posterior-a.Equilibrium) < 1e-12)
+		e.AssertAreEqual(t, a.Ticker+" posterior", posterior[i], a.Posterior)
+	}
+}
+
+func Test_BlackLitterman_BlendsViews(t *testing.T) {
+	assets, mu, cov := blackLittermanFixture()
+	equilibrium, _, _, _ := BlackLittermanPosterior(&BlackLittermanConfig{}, assets, mu, cov)
+
+	// a fully confident absolute view is met exactly
+	certain := &BlackLittermanConfig{Views: []*View{{Long: []string{"bnd"}, Return: 0.05, Confidence: 1}}}
+	posterior, _, _, err := BlackLittermanPosterior(certain, assets, mu, cov)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	e.AssertAreEqual(t, "bnd meets the view", true, math.Abs(posterior[2]-0.05) < 1e-6)
+	// correlated assets move with it
+	e.AssertAreEqual(t, "spy moves up", true, posterior[0] > equilibrium[0])
+
+	// a half confident relative view lands between the equilibrium spread and the view
+	relative := &BlackLittermanConfig{Views: []*View{{Long: []string{"EFA"}, Short: []string{"SPY"}, Return: 0.03, Confidence: 0.5}}}
+	posterior, posteriorCov, _, err := BlackLittermanPosterior(relative, assets, mu, cov)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	prior, spread := equilibrium[1]-equilibrium[0], posterior[1]-posterior[0]
+	e.AssertAreEqual(t, "spread moves towards the view", true, spread > prior && spread < 0.03)
+	// omega equals the prior variance of the view at 50% confidence, so the spread moves half way
+	e.AssertAreEqual(t, "half way", true, math.Abs(spread-(prior+0.03)/2) < 1e-12)
+	if _, err := GetCholeskyDecomposition(posteriorCov); err != nil {
+		t.Fatalf("posterior covariance is not positive definite: %v", err)
+	}
+
+	unknown := &BlackLittermanConfig{Views: []*View{{Long: []string{"BTC"}, Return: 1, Confidence: 0.5}}}
+	if _, _, _, err := BlackLittermanPosterior(unknown, assets, mu, cov); err == nil {
+		t.Fatalf("expected a view on an asset that is not allocated to fail")
+	}
+}
+
+func Test_BlackLitterman_ReplacesStatisticalResources(t *testing.T) {
+	seriesReturns := generateMockSeriesReturns(t, 500)
+	request := SimulationRequest{
+		DistType:       StandardNormal,
+		BlackLitterman: &BlackLittermanConfig{Views: []*View{{Long: []string{seriesReturns[0].Ticker}, Return: 0.2, Confidence: 1}}},
+	}
+
+	sr, err := GetStatisticalResources(request, seriesReturns)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	e.AssertAreEqual(t, "report", true, sr.BlackLitterman != nil)
+	e.AssertAreEqual(t, "mu is the posterior", true, math.Abs(sr.Mu[0]-0.2) < 1e-6)
+	e.AssertAreEqual(t, "sigma follows the posterior covariance", true, math.Abs(sr.Sigma[1]-sr.BlackLitterman.Assets[1].Sigma) < 1e-12)
+
+	worker := NewWorkerResources(sr, 42, 0)
+	draws := make([]float64, 20_000)
+	for i := range draws {
+		draws[i] = worker.GetCorrelatedReturns(Yearly)[1]
+	}
+	e.AssertAreEqual(t, "simulated with the posterior sigma", true, math.Abs(stat.StdDev(draws, nil)-sr.Sigma[1]) < 0.01)
+
+	request.Regimes = &RegimeConfig{}
+	request.Allocations = []SimulationAllocation{{Id: 1, Weight: 1}}
+	if err := request.Validate(); err == nil {
+		t.Fatalf("expected black-litterman with regimes to fail validation")
+	}
+}
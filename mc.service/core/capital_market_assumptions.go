@@ -0,0 +1,358 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"gonum.org/v1/gonum/mat"
+
+	m "mc.data/models"
+)
+
+// how supplied capital market assumptions combine with the historical estimates
+const (
+	CmaOverride = "override" // the assumptions replace history wherever they are given
+	CmaBlend    = "blend"    // Weight of the assumptions and 1 - Weight of history
+)
+
+// where an asset's assumptions came from
+const (
+	CmaSourceHistory    = "history"
+	CmaSourceAsset      = "asset"
+	CmaSourceAssetClass = "assetclass"
+)
+
+const (
+	DefaultCmaBlendWeight = 0.5
+	correlationTolerance  = 1e-8
+)
+
+// CapitalMarketAssumptionConfig overrides or blends the historical mu, sigma and correlations with forward
+// looking ones. Per asset values win over a stored set's asset class values, anything not given keeps history.
+type CapitalMarketAssumptionConfig struct {
+	Mode        string                     `json:"mode"`   // override (default) or blend
+	Weight      float64                    `json:"weight"` // blend weight of the assumptions, zero uses DefaultCmaBlendWeight
+	Assets      map[string]AssetAssumption `json:"assets"` // per ticker, annualized
+	Correlation *CorrelationAssumption     `json:"correlation"`
+
+	Stored       string            `json:"stored"`       // name of a stored assumption set
+	AssetClasses map[string]string `json:"assetclasses"` // ticker -> asset class in the stored set
+
+	stored *m.CapitalMarketAssumptions // loaded from Stored before the statistics are built
+}
+
+// AssetAssumption leaves history in place for whichever of the two is nil
+type AssetAssumption struct {
+	Mu    *float64 `json:"mu"`
+	Sigma *float64 `json:"sigma"`
+}
+
+// CorrelationAssumption is a correlation matrix over Tickers, which can be a subset of the allocation
+type CorrelationAssumption struct {
+	Tickers []string    `json:"tickers"`
+	Matrix  [][]float64 `json:"matrix"`
+}
+
+// CapitalMarketAssumptionReport shows each asset's inputs before and after the assumptions, annualized
+type CapitalMarketAssumptionReport struct {
+	Mode   string                   `json:"mode"`
+	Weight float64                  `json:"weight"`
+	Stored string                   `json:"stored,omitempty"`
+	Assets []*AssetAssumptionReport `json:"assets"`
+}
+
+type AssetAssumptionReport struct {
+	Id              int32   `json:"id"`
+	Ticker          string  `json:"ticker"`
+	AssetClass      string  `json:"assetclass,omitempty"`
+	Source          string  `json:"source"`
+	HistoricalMu    float64 `json:"historicalmu"`
+	HistoricalSigma float64 `json:"historicalsigma"`
+	Mu              float64 `json:"mu"`
+	Sigma           float64 `json:"sigma"`
+}
+
+func normalizeCmaMode(mode string) (string, error) {
+	switch v := strings.ToLower(strings.TrimSpace(mode)); v {
+	case "":
+		return CmaOverride, nil
+	case CmaOverride, CmaBlend:
+		return v, nil
+	default:
+		return "", fmt.Errorf("unknown capital market assumption mode %q, expected %s or %s", mode, CmaOverride, CmaBlend)
+	}
+}
+
+func (cc *CapitalMarketAssumptionConfig) Validate() error {
+	if _, err := normalizeCmaMode(cc.Mode); err != nil {
+		return err
+	}
+	if cc.Weight < 0 || cc.Weight > 1 {
+		return fmt.Errorf("capital market assumption weight must be between 0 and 1, got %v", cc.Weight)
+	}
+	if cc.Stored != "" && len(cc.AssetClasses) == 0 {
+		return fmt.Errorf("stored capital market assumptions need the asset class of each ticker")
+	}
+	if cc.Stored == "" && len(cc.AssetClasses) > 0 {
+		return fmt.Errorf("asset classes need a stored set of capital market assumptions")
+	}
+	for ticker, a := range cc.Assets {
+		if a.Sigma != nil && *a.Sigma <= 0 {
+			return fmt.Errorf("%s needs a positive sigma, got %v", ticker, *a.Sigma)
+		}
+	}
+
+	if c := cc.Correlation; c != nil {
+		tickers := map[string]bool{}
+		for _, t := range c.Tickers {
+			tickers[strings.ToUpper(strings.TrimSpace(t))] = true
+		}
+		if len(tickers) != len(c.Tickers) {
+			return fmt.Errorf("correlation tickers must be unique")
+		}
+		if _, err := ValidateCorrelationMatrix(c.Matrix); err != nil {
+			return err
+		}
+		if len(c.Matrix) != len(c.Tickers) {
+			return fmt.Errorf("correlation matrix is %dx%d but has %d tickers", len(c.Matrix), len(c.Matrix), len(c.Tickers))
+		}
+	}
+	return nil
+}
+
+// ValidateCorrelationMatrix checks the matrix is square, symmetric, has a unit diagonal, entries within
+// [-1, 1] and is positive definite
+func ValidateCorrelationMatrix(matrix [][]float64) (*mat.SymDense, error) {
+	n := len(matrix)
+	if n == 0 {
+		return nil, fmt.Errorf("correlation matrix is empty")
+	}
+
+	res := mat.NewSymDense(n, nil)
+	for i, row := range matrix {
+		if len(row) != n {
+			return nil, fmt.Errorf("correlation matrix is not square, row %d has %d entries for %d rows", i+1, len(row), n)
+		}
+		if math.Abs(row[i]-1) > correlationTolerance {
+			return nil, fmt.Errorf("correlation matrix needs ones on the diagonal, row %d has %v", i+1, row[i])
+		}
+		for j := range i {
+			if math.Abs(row[j]-matrix[j][i]) > correlationTolerance {
+				return nil, fmt.Errorf("correlation matrix is not symmetric at %d,%d", i+1, j+1)
+			}
+			if math.Abs(row[j]) > 1 {
+				return nil, fmt.Errorf("correlation at %d,%d is outside [-1, 1]", i+1, j+1)
+			}
+			res.SetSym(i, j, row[j])
+		}
+		res.SetSym(i, i, 1)
+	}
+
+	if !isPositiveDefinite(res) {
+		return nil, fmt.Errorf("correlation matrix is not positive definite")
+	}
+	return res, nil
+}
+
+// ValidateCapitalMarketAssumptions checks a stored set before it is saved, every pair of asset classes
+// needs a correlation so the class correlation matrix is complete
+func ValidateCapitalMarketAssumptions(cma *m.CapitalMarketAssumptions) error {
+	cma.Name = strings.TrimSpace(cma.Name)
+	if cma.Name == "" || len(cma.Name) > 100 {
+		return fmt.Errorf("assumption set name is required and can be at most 100 characters")
+	}
+	if len(cma.AssetClasses) == 0 {
+		return fmt.Errorf("assumption set %s needs at least one asset class", cma.Name)
+	}
+	for class, a := range cma.AssetClasses {
+		if a.Volatility <= 0 {
+			return fmt.Errorf("asset class %s needs a positive volatility", class)
+		}
+	}
+	_, _, err := classCorrelations(cma)
+	return err
+}
+
+// classCorrelations builds the correlation matrix between a stored set's asset classes
+func classCorrelations(cma *m.CapitalMarketAssumptions) ([]string, *mat.SymDense, error) {
+	classes := make([]string, 0, len(cma.AssetClasses))
+	for class := range cma.AssetClasses {
+		classes = append(classes, class)
+	}
+	slices.Sort(classes)
+
+	matrix := make([][]float64, len(classes))
+	for i, a := range classes {
+		matrix[i] = make([]float64, len(classes))
+		matrix[i][i] = 1
+		for j, b := range classes {
+			if i == j {
+				continue
+			}
+			ab, okAb := cma.Correlations[a][b]
+			ba, okBa := cma.Correlations[b][a]
+			switch {
+			case okAb && okBa && ab != ba:
+				return nil, nil, fmt.Errorf("the correlation of %s and %s is given twice with different values", a, b)
+			case okAb:
+				matrix[i][j] = ab
+			case okBa:
+				matrix[i][j] = ba
+			default:
+				return nil, nil, fmt.Errorf("the correlation of %s and %s is missing", a, b)
+			}
+		}
+	}
+	for a, row := range cma.Correlations {
+		for b := range row {
+			if _, ok := cma.AssetClasses[a]; !ok {
+				return nil, nil, fmt.Errorf("correlation for unknown asset class %s", a)
+			}
+			if _, ok := cma.AssetClasses[b]; !ok {
+				return nil, nil, fmt.Errorf("correlation for unknown asset class %s", b)
+			}
+		}
+	}
+
+	res, err := ValidateCorrelationMatrix(matrix)
+	if err != nil {
+		return nil, nil, fmt.Errorf("asset class correlations: %w", err)
+	}
+	return classes, res, nil
+}
+
+// loadCapitalMarketAssumptions fetches the stored set the request refers to
+func (sc *ServiceContext) loadCapitalMarketAssumptions(request SimulationRequest) error {
+	cc := request.CapitalMarketAssumptions
+	if cc == nil || cc.Stored == "" {
+		return nil
+	}
+
+	cma, err := sc.PostgresConnection.GetCapitalMarketAssumption(sc.Context, cc.Stored)
+	if err != nil {
+		return err
+	}
+	if cma == nil {
+		return fmt.Errorf("capital market assumptions %s do not exist", cc.Stored)
+	}
+	cc.stored = cma
+	return nil
+}
+
+// ApplyCapitalMarketAssumptions moves mu and cov, both annualized and in asset order, to the assumptions.
+// Pairs without an assumed correlation, e.g. two assets in the same class, keep their historical one.
+func ApplyCapitalMarketAssumptions(config *CapitalMarketAssumptionConfig, assets []*SeriesReturns, mu []float64, cov *mat.SymDense) ([]float64, *mat.SymDense, *CapitalMarketAssumptionReport, error) {
+	mode, err := normalizeCmaMode(config.Mode)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	report := &CapitalMarketAssumptionReport{Mode: mode, Weight: 1, Stored: config.Stored}
+	if mode == CmaBlend {
+		report.Weight = config.Weight
+		if report.Weight == 0 {
+			report.Weight = DefaultCmaBlendWeight
+		}
+	}
+
+	n := len(assets)
+	index := make(map[string]int, n)
+	sigma := make([]float64, n)
+	for i, a := range assets {
+		index[strings.ToUpper(a.Ticker)] = i
+		sigma[i] = math.Sqrt(cov.At(i, i))
+	}
+	lookup := func(ticker string) (int, error) {
+		i, ok := index[strings.ToUpper(strings.TrimSpace(ticker))]
+		if !ok {
+			return 0, fmt.Errorf("capital market assumptions reference %s which is not allocated", ticker)
+		}
+		return i, nil
+	}
+
+	targetMu, targetSigma := slices.Clone(mu), slices.Clone(sigma)
+	targetCorr := GetCorrelationMatrix(cov, sigma)
+	for i, a := range assets {
+		report.Assets = append(report.Assets, &AssetAssumptionReport{Id: a.Id, Ticker: a.Ticker, Source: CmaSourceHistory, HistoricalMu: mu[i], HistoricalSigma: sigma[i]})
+	}
+
+	if config.stored != nil {
+		classes, classCorr, err := classCorrelations(config.stored)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		assetClass := make([]int, n)
+		for i := range assetClass {
+			assetClass[i] = -1
+		}
+		for ticker, class := range config.AssetClasses {
+			i, err := lookup(ticker)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			c, ok := slices.BinarySearch(classes, class)
+			if !ok {
+				return nil, nil, nil, fmt.Errorf("%s is in asset class %s which is not in %s", ticker, class, config.Stored)
+			}
+			assetClass[i] = c
+			targetMu[i] = config.stored.AssetClasses[class].ExpectedReturn
+			targetSigma[i] = config.stored.AssetClasses[class].Volatility
+			report.Assets[i].AssetClass, report.Assets[i].Source = class, CmaSourceAssetClass
+		}
+		for i := range n {
+			for j := range i {
+				if assetClass[i] >= 0 && assetClass[j] >= 0 && assetClass[i] != assetClass[j] {
+					targetCorr.SetSym(i, j, classCorr.At(assetClass[i], assetClass[j]))
+				}
+			}
+		}
+	}
+
+	for ticker, a := range config.Assets {
+		i, err := lookup(ticker)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if a.Mu != nil {
+			targetMu[i] = *a.Mu
+		}
+		if a.Sigma != nil {
+			targetSigma[i] = *a.Sigma
+		}
+		report.Assets[i].Source = CmaSourceAsset
+	}
+
+	if c := config.Correlation; c != nil {
+		rows := make([]int, len(c.Tickers))
+		for k, ticker := range c.Tickers {
+			if rows[k], err = lookup(ticker); err != nil {
+				return nil, nil, nil, err
+			}
+		}
+		for k := range rows {
+			for l := range k {
+				targetCorr.SetSym(rows[k], rows[l], c.Matrix[k][l])
+			}
+		}
+	}
+
+	// a convex combination of correlation matrices is still one
+	w := report.Weight
+	res := mat.NewSymDense(n, nil)
+	resMu := make([]float64, n)
+	histCorr := GetCorrelationMatrix(cov, sigma)
+	for i := range n {
+		resMu[i] = (1-w)*mu[i] + w*targetMu[i]
+		report.Assets[i].Mu = resMu[i]
+		report.Assets[i].Sigma = (1-w)*sigma[i] + w*targetSigma[i]
+	}
+	for i := range n {
+		for j := range i + 1 {
+			corr := (1-w)*histCorr.At(i, j) + w*targetCorr.At(i, j)
+			res.SetSym(i, j, corr*report.Assets[i].Sigma*report.Assets[j].Sigma)
+		}
+	}
+	return resMu, res, report, nil
+}
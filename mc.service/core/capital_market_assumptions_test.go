@@ -0,0 +1,141 @@
+package core
+
+import (
+	"math"
+	"testing"
+
+	"gonum.org/v1/gonum/stat"
+
+	e "mc.data/extensions"
+	m "mc.data/models"
+)
+
+func Test_CapitalMarketAssumptions_ValidatesCorrelationMatrix(t *testing.T) {
+	if _, err := ValidateCorrelationMatrix([][]float64{{1, 0.5}, {0.4, 1}}); err == nil {
+		t.Fatalf("expected an asymmetric matrix to fail")
+	}
+	if _, err := ValidateCorrelationMatrix([][]float64{{1, 0.9, -0.9}, {0.9, 1, 0.9}, {-0.9, 0.9, 1}}); err == nil {
+		t.Fatalf("expected a matrix that is not positive definite to fail")
+	}
+	if _, err := ValidateCorrelationMatrix([][]float64{{1, 0.2}, {0.2}}); err == nil {
+		t.Fatalf("expected a ragged matrix to fail")
+	}
+	if _, err := ValidateCorrelationMatrix([][]float64{{1, 0.2}, {0.2, 1}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func Test_CapitalMarketAssumptions_OverridesAndBlends(t *testing.T) {
+	assets, mu, cov := blackLittermanFixture()
+	muSpy, sigmaSpy := 0.06, 0.2
+	config := &CapitalMarketAssumptionConfig{
+		Assets:      map[string]AssetAssumption{"spy": {Mu: &muSpy, Sigma: &sigmaSpy}},
+		Correlation: &CorrelationAssumption{Tickers: []string{"SPY", "BND"}, Matrix: [][]float64{{1, -0.3}, {-0.3, 1}}},
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resMu, resCov, report, err := ApplyCapitalMarketAssumptions(config, assets, mu, cov)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	e.AssertAreEqual(t, "spy mu", 0.06, resMu[0])
+	e.AssertAreEqual(t, "efa mu kept", 0.02, resMu[1])
+	e.AssertAreEqual(t, "spy variance", true, math.Abs(resCov.At(0, 0)-0.04) < 1e-12)
+	e.AssertAreEqual(t, "spy bnd covariance", true, math.Abs(resCov.At(0, 2)-(-0.3*0.2*0.06)) < 1e-12)
+	// the historical correlation of 0.8 with efa stays and scales with the new sigma
+	e.AssertAreEqual(t, "spy efa covariance", true, math.Abs(resCov.At(0, 1)-0.018/0.15*0.2) < 1e-12)
+	e.AssertAreEqual(t, "source", CmaSourceAsset, report.Assets[0].Source)
+	e.AssertAreEqual(t, "historical sigma", true, math.Abs(report.Assets[0].HistoricalSigma-0.15) < 1e-12)
+
+	config.Mode, config.Weight = CmaBlend, 0.25
+	resMu, _, report, err = ApplyCapitalMarketAssumptions(config, assets, mu, cov)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	e.AssertAreEqual(t, "blended mu", true, math.Abs(resMu[0]-(0.75*0.12+0.25*0.06)) < 1e-12)
+	e.AssertAreEqual(t, "blended sigma", true, math.Abs(report.Assets[0].Sigma-(0.75*0.15+0.25*0.2)) < 1e-12)
+}
+
+func Test_CapitalMarketAssumptions_AppliesStoredAssetClasses(t *testing.T) {
+	stored := &m.CapitalMarketAssumptions{
+		Name: "2026 outlook",
+		AssetClasses: map[string]m.AssetClassAssumption{
+			"equity": {ExpectedReturn: 0.065, Volatility: 0.17},
+			"bonds":  {ExpectedReturn: 0.045, Volatility: 0.05},
+		},
+		Correlations: map[string]map[string]float64{"bonds": {"equity": 0.2}},
+	}
+	if err := ValidateCapitalMarketAssumptions(stored); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	assets, mu, cov := blackLittermanFixture()
+	config := &CapitalMarketAssumptionConfig{
+		Stored:       stored.Name,
+		AssetClasses: map[string]string{"SPY": "equity", "EFA": "equity", "BND": "bonds"},
+		stored:       stored,
+	}
+	resMu, resCov, report, err := ApplyCapitalMarketAssumptions(config, assets, mu, cov)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	e.AssertAreEqual(t, "efa takes the equity return", 0.065, resMu[1])
+	e.AssertAreEqual(t, "class", "bonds", report.Assets[2].AssetClass)
+	e.AssertAreEqual(t, "across classes", true, math.Abs(resCov.At(1, 2)-0.2*0.17*0.05) < 1e-12)
+	// two equities keep their historical correlation, an assumed 1 would be singular
+	historical := 0.018 / (0.15 * 0.17)
+	e.AssertAreEqual(t, "within a class", true, math.Abs(resCov.At(0, 1)-historical*0.17*0.17) < 1e-12)
+
+	delete(stored.Correlations, "bonds")
+	if err := ValidateCapitalMarketAssumptions(stored); err == nil {
+		t.Fatalf("expected a missing class correlation to fail")
+	}
+}
+
+func Test_CapitalMarketAssumptions_ReplaceStatisticalResources(t *testing.T) {
+	seriesReturns := generateMockSeriesReturns(t, 500)
+	mu, sigma := 0.05, 0.3
+	request := SimulationRequest{
+		DistType: StandardNormal,
+		CapitalMarketAssumptions: &CapitalMarketAssumptionConfig{
+			Assets: map[string]AssetAssumption{seriesReturns[1].Ticker: {Mu: &mu, Sigma: &sigma}},
+		},
+	}
+
+	sr, err := GetStatisticalResources(request, seriesReturns)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	e.AssertAreEqual(t, "mu", 0.05, sr.Mu[1])
+	e.AssertAreEqual(t, "sigma", true, math.Abs(sr.Sigma[1]-0.3) < 1e-12)
+	e.AssertAreEqual(t, "period variance", true, math.Abs(sr.CovMatrix.At(1, 1)-0.09/Daily) < 1e-12)
+	e.AssertAreEqual(t, "report", CmaOverride, sr.CapitalMarketAssumptions.Mode)
+}
+
+func Test_CapitalMarketAssumptions_SetSimulatedDispersion(t *testing.T) {
+	seriesReturns := generateMockSeriesReturns(t, 500)
+	sigma := 0.3
+	request := SimulationRequest{
+		DistType: StandardNormal,
+		CapitalMarketAssumptions: &CapitalMarketAssumptionConfig{
+			Assets: map[string]AssetAssumption{seriesReturns[1].Ticker: {Sigma: &sigma}},
+		},
+	}
+
+	sr, err := GetStatisticalResources(request, seriesReturns)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	worker := NewWorkerResources(sr, 42, 0)
+	yearly, monthly := make([]float64, 20_000), make([]float64, 20_000)
+	for i := range yearly {
+		yearly[i] = worker.GetCorrelatedReturns(Yearly)[1]
+		monthly[i] = worker.GetCorrelatedReturns(Monthly)[1]
+	}
+	e.AssertAreEqual(t, "yearly dispersion", true, math.Abs(stat.StdDev(yearly, nil)-sigma) < 0.01)
+	e.AssertAreEqual(t, "monthly dispersion", true, math.Abs(stat.StdDev(monthly, nil)-sigma/math.Sqrt(Monthly)) < 0.003)
+}
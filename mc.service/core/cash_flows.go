@@ -0,0 +1,39 @@
+package core
+
+import "fmt"
+
+// CashFlow adds to or withdraws from every path at the end of each period from Start to End
+type CashFlow struct {
+	Start   int     `json:"start"`   // first period, 1 is the first simulated period
+	End     int     `json:"end"`     // last period, zero runs to the end of the simulation
+	Amount  float64 `json:"amount"`  // per period, positive contributes and negative withdraws
+	Indexed bool    `json:"indexed"` // Amount is in today's money and grows with simulated inflation
+}
+
+func validateCashFlows(flows []*CashFlow, duration int) error {
+	for i, f := range flows {
+		if f.Start < 1 || f.Start > duration {
+			return fmt.Errorf("cash flow %d starts in period %d, outside the %d simulated periods", i+1, f.Start, duration)
+		}
+		if f.End != 0 && f.End < f.Start {
+			return fmt.Errorf("cash flow %d ends before it starts", i+1)
+		}
+	}
+	return nil
+}
+
+// cashFlowAt sums the flows landing at the end of the zero based period, indexed ones scaled by the price level
+func cashFlowAt(flows []*CashFlow, period int, priceIndex float64) float64 {
+	res := 0.0
+	for _, f := range flows {
+		if period+1 < f.Start || (f.End != 0 && period+1 > f.End) {
+			continue
+		}
+		if f.Indexed {
+			res += f.Amount * priceIndex
+		} else {
+			res += f.Amount
+		}
+	}
+	return res
+}
@@ -4,11 +4,12 @@ import (
 	"context"
 
 	r "mc.data/repos"
-	av "mc.service/api/alpha_vantage"
+	a "mc.service/api"
 )
 
 type ServiceContext struct {
 	Context            context.Context
 	PostgresConnection r.Postgres
-	AlphaVantageClient av.AlphaVantageClient
+	MarketDataProvider a.MarketDataProvider
+	StalenessPolicy    StalenessPolicy // how old a series can get before it is synced again, nil for the defaults
 }
@@ -0,0 +1,212 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	ex "mc.data/extensions"
+	m "mc.data/models"
+)
+
+const (
+	// implied adjustment ratios further than this from 1 are treated as splits rather than dividend noise
+	splitDetectionThreshold = 0.2
+	// snapped ratios have a denominator up to this, covers 3:2, 5:4, 1:10 and the like
+	maxSplitDenominator = 4
+
+	DefaultAdjustedCloseTolerance = 0.005
+)
+
+type AdjustedCloseCheck struct {
+	Symbol          string                   `json:"symbol"`
+	Bars            int                      `json:"bars"`
+	Actions         int                      `json:"actions"`
+	Tolerance       float64                  `json:"tolerance"`
+	MaxRelativeDiff float64                  `json:"maxrelativediff"`
+	Consistent      bool                     `json:"consistent"`
+	Mismatches      []*AdjustedCloseMismatch `json:"mismatches"`
+}
+
+type AdjustedCloseMismatch struct {
+	Date         string  `json:"date"`
+	Vendor       float64 `json:"vendor"`
+	Recomputed   float64 `json:"recomputed"`
+	RelativeDiff float64 `json:"relativediff"`
+}
+
+// CheckAdjustedCloses rebuilds the adjusted series from stored closes and corporate actions and
+// compares it to the vendor's adjusted close
+func (sc *ServiceContext) CheckAdjustedCloses(symbol string, tolerance float64) (*AdjustedCloseCheck, error) {
+	bars, err := sc.PostgresConnection.GetTimeSeriesData(sc.Context, symbol)
+	if err != nil {
+		return nil, err
+	}
+	if len(bars) == 0 {
+		return nil, fmt.Errorf("no time series data stored for %s", symbol)
+	}
+
+	actions, err := sc.PostgresConnection.GetCorporateActions(sc.Context, symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	res := compareAdjustedCloses(bars, actions, tolerance)
+	res.Symbol = symbol
+	return res, nil
+}
+
+// extractCorporateActions pulls dividends and splits out of a fetched series. Splits come from the
+// split coefficient when the provider reports one, otherwise they are inferred from the adjusted closes
+func extractCorporateActions(bars []*m.TimeSeriesData, origin string) []*m.CorporateAction {
+	sorted := sortedAscending(bars)
+
+	res := []*m.CorporateAction{}
+	reportsSplits := false
+	for _, b := range sorted {
+		if b.DividendAmount > 0 {
+			res = append(res, &m.CorporateAction{ExDate: b.Timestamp, ActionType: m.CorporateActionDividend, Amount: b.DividendAmount, Origin: origin})
+		}
+		if b.SplitCoefficient > 0 {
+			reportsSplits = true
+			if b.SplitCoefficient != 1 {
+				res = append(res, &m.CorporateAction{ExDate: b.Timestamp, ActionType: m.CorporateActionSplit, Amount: b.SplitCoefficient, Origin: origin})
+			}
+		}
+	}
+
+	if !reportsSplits {
+		res = append(res, inferSplits(sorted)...)
+	}
+
+	return res
+}
+
+// inferSplits backs the split coefficient out of consecutive bars. with F = adjusted / close the vendor
+// applies F[i-1] = F[i] * (1 - dividend[i] / close[i-1]) / k, so k = F[i] / F[i-1] * (1 - dividend[i] / close[i-1])
+func inferSplits(sorted []*m.TimeSeriesData) []*m.CorporateAction {
+	res := []*m.CorporateAction{}
+	for i := 1; i < len(sorted); i++ {
+		prev, cur := sorted[i-1], sorted[i]
+		if prev.Close <= 0 || cur.Close <= 0 || prev.AdjustedClose <= 0 || cur.AdjustedClose <= 0 {
+			continue
+		}
+
+		k := (cur.AdjustedClose / cur.Close) / (prev.AdjustedClose / prev.Close) * (1 - cur.DividendAmount/prev.Close)
+		if math.Abs(k-1) < splitDetectionThreshold {
+			continue
+		}
+
+		if snapped, ok := snapSplitRatio(k); ok {
+			res = append(res, &m.CorporateAction{ExDate: cur.Timestamp, ActionType: m.CorporateActionSplit, Amount: snapped, Origin: m.CorporateActionInferred})
+		}
+	}
+	return res
+}
+
+// snapSplitRatio rounds to the nearest simple fraction, reverse splits are snapped on the inverse ratio.
+// anything not close to one is not treated as a split
+func snapSplitRatio(k float64) (float64, bool) {
+	if k <= 0 {
+		return 0, false
+	}
+	if k < 1 {
+		inverse, ok := snapSplitRatio(1 / k)
+		if !ok {
+			return 0, false
+		}
+		return 1 / inverse, true
+	}
+
+	for q := 1.0; q <= maxSplitDenominator; q++ {
+		p := math.Round(k * q)
+		if math.Abs(p/q-k)/k < 0.01 {
+			return p / q, true
+		}
+	}
+	return 0, false
+}
+
+// RecomputeAdjustedCloses rebuilds adjusted closes from raw closes, walking back from the latest bar.
+// Each action applies to the first bar on or after its ex date, dividends scale earlier bars by
+// (1 - dividend / previous close) and splits divide them by the coefficient. Returns copies sorted ascending.
+func RecomputeAdjustedCloses(bars []*m.TimeSeriesData, actions []*m.CorporateAction) []*m.TimeSeriesData {
+	sorted := sortedAscending(bars)
+
+	byBar := make(map[int][]*m.CorporateAction)
+	for _, a := range actions {
+		// dates compared as strings, stored bars are utc while providers parse in the exchange time zone
+		i, _ := slices.BinarySearchFunc(sorted, ex.FmtShort(a.ExDate), func(b *m.TimeSeriesData, date string) int {
+			return strings.Compare(ex.FmtShort(b.Timestamp), date)
+		})
+		if i < len(sorted) {
+			byBar[i] = append(byBar[i], a)
+		}
+	}
+
+	res := make([]*m.TimeSeriesData, len(sorted))
+	factor := 1.0
+	for i := len(sorted) - 1; i >= 0; i-- {
+		v := *sorted[i]
+		v.AdjustedClose = v.Close * factor
+		res[i] = &v
+
+		for _, a := range byBar[i] {
+			switch a.ActionType {
+			case m.CorporateActionDividend:
+				if i > 0 && sorted[i-1].Close > 0 {
+					factor *= 1 - a.Amount/sorted[i-1].Close
+				}
+			case m.CorporateActionSplit:
+				if a.Amount > 0 {
+					factor /= a.Amount
+				}
+			}
+		}
+	}
+
+	return res
+}
+
+func compareAdjustedCloses(bars []*m.TimeSeriesData, actions []*m.CorporateAction, tolerance float64) *AdjustedCloseCheck {
+	if tolerance <= 0 {
+		tolerance = DefaultAdjustedCloseTolerance
+	}
+
+	recomputed := RecomputeAdjustedCloses(bars, actions)
+	vendor := sortedAscending(bars)
+
+	res := &AdjustedCloseCheck{
+		Bars:       len(bars),
+		Actions:    len(actions),
+		Tolerance:  tolerance,
+		Mismatches: []*AdjustedCloseMismatch{},
+	}
+
+	for i, v := range vendor {
+		if v.AdjustedClose <= 0 {
+			continue
+		}
+
+		diff := math.Abs(recomputed[i].AdjustedClose-v.AdjustedClose) / v.AdjustedClose
+		res.MaxRelativeDiff = math.Max(res.MaxRelativeDiff, diff)
+		if diff > tolerance {
+			res.Mismatches = append(res.Mismatches, &AdjustedCloseMismatch{
+				Date:         ex.FmtShort(v.Timestamp),
+				Vendor:       v.AdjustedClose,
+				Recomputed:   math.Round(recomputed[i].AdjustedClose*10_000) / 10_000,
+				RelativeDiff: diff,
+			})
+		}
+	}
+
+	res.Consistent = len(res.Mismatches) == 0
+	return res
+}
+
+func sortedAscending(bars []*m.TimeSeriesData) []*m.TimeSeriesData {
+	res := slices.Clone(bars)
+	slices.SortFunc(res, func(a, b *m.TimeSeriesData) int { return a.Timestamp.Compare(b.Timestamp) })
+	return res
+}
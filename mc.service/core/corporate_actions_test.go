@@ -0,0 +1,89 @@
+package core
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	e "mc.data/extensions"
+	m "mc.data/models"
+	av "mc.service/api/alpha_vantage"
+	"mc.service/api/fakeav"
+)
+
+func getSplitClient(t *testing.T) av.AlphaVantageClient {
+	t.Helper()
+	ts := httptest.NewServer(fakeav.NewServer(fakeav.Config{
+		Seed: 5,
+		AsOf: time.Date(2025, time.October, 31, 0, 0, 0, 0, time.UTC),
+		Symbols: map[string]fakeav.SymbolParameters{
+			"NVDA": {Mu: 0.2, Sigma: 0.4, StartPrice: 400, DividendYield: 0.02, Splits: map[string]float64{"2025-08-04": 4}},
+		},
+	}))
+	t.Cleanup(ts.Close)
+	return av.GetClientForHost(ts.URL, "demo")
+}
+
+func Test_CorporateActions_ReportedSplitsRebuildDailyAdjustedCloses(t *testing.T) {
+	c := getSplitClient(t)
+	res, err := c.GetStockDailyAdjustedMetrics("NVDA", av.DataTypeJSON)
+	if err != nil {
+		t.Fatalf("error getting daily series: %s", err)
+	}
+
+	actions := extractCorporateActions(res.TimeSeries, av.ProviderName)
+	splits := e.FilterMultiplePtr(actions, func(a *m.CorporateAction) bool { return a.ActionType == m.CorporateActionSplit })
+	e.AssertAreEqual(t, "split count", 1, len(splits))
+	e.AssertAreEqual(t, "split date", "2025-08-04", e.FmtShort(splits[0].ExDate))
+	e.AssertAreEqual(t, "split ratio", 4.0, splits[0].Amount)
+	e.AssertAreEqual(t, "split origin", av.ProviderName, splits[0].Origin)
+
+	check := compareAdjustedCloses(res.TimeSeries, actions, 0.0005)
+	if !check.Consistent {
+		t.Fatalf("expected rebuilt adjusted closes to match, max relative diff %v, first mismatch %+v", check.MaxRelativeDiff, check.Mismatches[0])
+	}
+}
+
+func Test_CorporateActions_InfersSplitsFromWeeklyAdjustedCloses(t *testing.T) {
+	c := getSplitClient(t)
+	res, err := c.GetStockWeeklyAdjustedMetrics("NVDA", av.DataTypeJSON)
+	if err != nil {
+		t.Fatalf("error getting weekly series: %s", err)
+	}
+
+	actions := extractCorporateActions(res.TimeSeries, av.ProviderName)
+	splits := e.FilterMultiplePtr(actions, func(a *m.CorporateAction) bool { return a.ActionType == m.CorporateActionSplit })
+	e.AssertAreEqual(t, "split count", 1, len(splits))
+	e.AssertAreEqual(t, "split week", "2025-08-08", e.FmtShort(splits[0].ExDate))
+	e.AssertAreEqual(t, "split ratio", 4.0, splits[0].Amount)
+	e.AssertAreEqual(t, "split origin", m.CorporateActionInferred, splits[0].Origin)
+
+	// weekly bars approximate the dividend adjustment with the prior week's close
+	check := compareAdjustedCloses(res.TimeSeries, actions, DefaultAdjustedCloseTolerance)
+	if !check.Consistent {
+		t.Fatalf("expected rebuilt adjusted closes to match, max relative diff %v, first mismatch %+v", check.MaxRelativeDiff, check.Mismatches[0])
+	}
+
+	// without the split the history before it is off by the full ratio
+	dividendsOnly := e.FilterMultiplePtr(actions, func(a *m.CorporateAction) bool { return a.ActionType == m.CorporateActionDividend })
+	if compareAdjustedCloses(res.TimeSeries, dividendsOnly, DefaultAdjustedCloseTolerance).Consistent {
+		t.Fatalf("expected the check to flag adjusted closes rebuilt without the split")
+	}
+}
+
+func Test_CorporateActions_SnapSplitRatio(t *testing.T) {
+	for _, tc := range []struct {
+		k        float64
+		expected float64
+		ok       bool
+	}{
+		{3.98, 4, true},
+		{1.502, 1.5, true},
+		{0.1003, 0.1, true},
+		{2.71, 0, false},
+	} {
+		snapped, ok := snapSplitRatio(tc.k)
+		e.AssertAreEqual(t, "ok", tc.ok, ok)
+		e.AssertAreEqual(t, "snapped", tc.expected, snapped)
+	}
+}
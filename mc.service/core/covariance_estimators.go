@@ -0,0 +1,228 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// how the covariance matrix is estimated from the aligned returns
+const (
+	EstimatorSample     = "sample"     // plain sample covariance
+	EstimatorLedoitWolf = "ledoitwolf" // Ledoit-Wolf shrinkage toward a structured target
+	EstimatorEwma       = "ewma"       // exponentially weighted, RiskMetrics style
+)
+
+// what the Ledoit-Wolf estimator shrinks toward
+const (
+	TargetConstantCorrelation = "constantcorrelation" // every pair gets the average correlation (Ledoit & Wolf 2004)
+	TargetDiagonal            = "diagonal"            // the variances only, correlations shrink toward zero
+)
+
+// RiskMetrics uses a decay of 0.94 for daily data, which is a half-life of about 11.2 trading days.
+// The default keeps that half-life in time, so weekly returns decay over about 2.3 weeks
+var DefaultEwmaHalfLifeDays = math.Log(0.5) / math.Log(0.94)
+
+// CovarianceEstimate records which estimator built the covariance matrix and how
+type CovarianceEstimate struct {
+	Estimator string  `json:"estimator"`
+	Target    string  `json:"target,omitempty"`
+	Shrinkage float64 `json:"shrinkage"`          // ledoit-wolf intensity, 0 is the sample matrix and 1 is the target
+	HalfLife  float64 `json:"halflife,omitempty"` // ewma, in periods of the stored returns
+	Lambda    float64 `json:"lambda,omitempty"`   // ewma decay per period
+}
+
+func normalizeEstimator(estimator, target string) (string, string, error) {
+	e := strings.ToLower(strings.TrimSpace(estimator))
+	switch e {
+	case "":
+		e = EstimatorSample
+	case EstimatorSample, EstimatorLedoitWolf, EstimatorEwma:
+	default:
+		return "", "", fmt.Errorf("unknown covariance estimator %q, expected %s, %s or %s", estimator, EstimatorSample, EstimatorLedoitWolf, EstimatorEwma)
+	}
+
+	t := strings.ToLower(strings.TrimSpace(target))
+	if e != EstimatorLedoitWolf {
+		return e, "", nil
+	}
+	switch t {
+	case "":
+		t = TargetConstantCorrelation
+	case TargetConstantCorrelation, TargetDiagonal:
+	default:
+		return "", "", fmt.Errorf("unknown shrinkage target %q, expected %s or %s", target, TargetConstantCorrelation, TargetDiagonal)
+	}
+	return e, t, nil
+}
+
+// EstimateCovariance builds the per period covariance matrix of equal length, date ascending returns
+// with the estimator chosen on the request. annualizationFactor is the returns' periods per year
+func EstimateCovariance(request SimulationRequest, returns [][]float64, annualizationFactor int) (*mat.SymDense, *CovarianceEstimate, error) {
+	estimator, target, err := normalizeEstimator(request.Estimator, request.ShrinkageTarget)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch estimator {
+	case EstimatorLedoitWolf:
+		cov, shrinkage := ledoitWolfCovariance(returns, target)
+		return cov, &CovarianceEstimate{Estimator: estimator, Target: target, Shrinkage: shrinkage}, nil
+	case EstimatorEwma:
+		halfLife := request.HalfLife
+		if halfLife == 0 {
+			halfLife = defaultEwmaHalfLife(annualizationFactor)
+		}
+		if halfLife < 0 {
+			return nil, nil, fmt.Errorf("ewma half-life must be positive, got %v", halfLife)
+		}
+		lambda := math.Pow(0.5, 1/halfLife)
+		return ewmaCovariance(returns, lambda), &CovarianceEstimate{Estimator: estimator, HalfLife: halfLife, Lambda: lambda}, nil
+	default:
+		return GetCovarianceMatrix(returns), &CovarianceEstimate{Estimator: estimator}, nil
+	}
+}
+
+// defaultEwmaHalfLife converts DefaultEwmaHalfLifeDays to periods of the returns, unknown frequencies
+// are taken as daily
+func defaultEwmaHalfLife(annualizationFactor int) float64 {
+	if annualizationFactor <= 0 {
+		annualizationFactor = Daily
+	}
+	return DefaultEwmaHalfLifeDays * float64(annualizationFactor) / Daily
+}
+
+// ledoitWolfCovariance shrinks the sample covariance toward the target with the optimal intensity from
+// Ledoit & Wolf, "Honey, I Shrunk the Sample Covariance Matrix" (2004). The intensity is estimated on the
+// 1/T scaled matrix as in the paper and applied to the unbiased sample covariance, the intensity is scale free.
+func ledoitWolfCovariance(returns [][]float64, target string) (*mat.SymDense, float64) {
+	n := len(returns)
+	sample := GetCovarianceMatrix(returns)
+	if n < 2 {
+		return sample, 0
+	}
+
+	nObs := len(returns[0])
+	t := float64(nObs)
+	x := make([][]float64, n) // demeaned
+	for i, r := range returns {
+		mean := 0.0
+		for _, v := range r {
+			mean += v
+		}
+		mean /= t
+
+		x[i] = make([]float64, nObs)
+		for k, v := range r {
+			x[i][k] = v - mean
+		}
+	}
+
+	s := make([][]float64, n) // 1/T covariance
+	for i := range n {
+		s[i] = make([]float64, n)
+		for j := range n {
+			for k := range nObs {
+				s[i][j] += x[i][k] * x[j][k]
+			}
+			s[i][j] /= t
+		}
+	}
+
+	avgCorr := 0.0
+	if target == TargetConstantCorrelation {
+		for i := range n {
+			for j := range i {
+				avgCorr += s[i][j] / math.Sqrt(s[i][i]*s[j][j])
+			}
+		}
+		avgCorr /= float64(n*(n-1)) / 2
+	}
+
+	targetAt := func(i, j int) float64 {
+		switch {
+		case i == j:
+			return s[i][i]
+		case target == TargetDiagonal:
+			return 0
+		default:
+			return avgCorr * math.Sqrt(s[i][i]*s[j][j])
+		}
+	}
+
+	// pi is the asymptotic variance of the sample entries, rho the covariance of the target with
+	// the sample and gamma the misspecification of the target
+	pi, rho, gamma := 0.0, 0.0, 0.0
+	for i := range n {
+		for j := range n {
+			piij := 0.0
+			for k := range nObs {
+				d := x[i][k]*x[j][k] - s[i][j]
+				piij += d * d
+			}
+			piij /= t
+			pi += piij
+
+			f := targetAt(i, j)
+			gamma += (f - s[i][j]) * (f - s[i][j])
+
+			if i == j {
+				rho += piij
+				continue
+			}
+			if target == TargetConstantCorrelation {
+				thetaii, thetajj := 0.0, 0.0
+				for k := range nObs {
+					cross := x[i][k]*x[j][k] - s[i][j]
+					thetaii += (x[i][k]*x[i][k] - s[i][i]) * cross
+					thetajj += (x[j][k]*x[j][k] - s[j][j]) * cross
+				}
+				thetaii /= t
+				thetajj /= t
+				rho += avgCorr / 2 * (math.Sqrt(s[j][j]/s[i][i])*thetaii + math.Sqrt(s[i][i]/s[j][j])*thetajj)
+			}
+		}
+	}
+
+	shrinkage := 0.0
+	if gamma > 0 {
+		shrinkage = math.Max(0, math.Min(1, (pi-rho)/gamma/t))
+	}
+
+	res := mat.NewSymDense(n, nil)
+	scale := t / (t - 1) // back onto the unbiased scale the rest of the package uses
+	for i := range n {
+		for j := range i + 1 {
+			res.SetSym(i, j, shrinkage*targetAt(i, j)*scale+(1-shrinkage)*sample.At(i, j))
+		}
+	}
+	return res, shrinkage
+}
+
+// ewmaCovariance weights the most recent return by 1 and each earlier one by another factor of lambda.
+// Like RiskMetrics the mean is taken to be zero.
+func ewmaCovariance(returns [][]float64, lambda float64) *mat.SymDense {
+	n := len(returns)
+	nObs := len(returns[0])
+
+	weights := make([]float64, nObs)
+	total := 0.0
+	for k := range nObs {
+		weights[k] = math.Pow(lambda, float64(nObs-1-k))
+		total += weights[k]
+	}
+
+	res := mat.NewSymDense(n, nil)
+	for i := range n {
+		for j := range i + 1 {
+			v := 0.0
+			for k := range nObs {
+				v += weights[k] * returns[i][k] * returns[j][k]
+			}
+			res.SetSym(i, j, v/total)
+		}
+	}
+	return res
+}
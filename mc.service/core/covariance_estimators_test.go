@@ -0,0 +1,102 @@
+package core
+
+import (
+	"math"
+	"testing"
+
+	e "mc.data/extensions"
+)
+
+func Test_CovarianceEstimators_LedoitWolfShrinksShortHistories(t *testing.T) {
+	returns := generateMockReturns(t, Daily*4)
+	short := [][]float64{returns[0][:30], returns[1][:30], returns[2][:30]}
+
+	for _, target := range []string{TargetConstantCorrelation, TargetDiagonal} {
+		request := SimulationRequest{Estimator: EstimatorLedoitWolf, ShrinkageTarget: target}
+		longCov, longEst, err := EstimateCovariance(request, returns, Daily)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		shortCov, shortEst, _ := EstimateCovariance(request, short, Daily)
+
+		e.AssertAreEqual(t, target+" estimator", EstimatorLedoitWolf, longEst.Estimator)
+		e.AssertAreEqual(t, target+" target", target, longEst.Target)
+		e.AssertAreEqual(t, target+" intensity in range", true, shortEst.Shrinkage > 0 && shortEst.Shrinkage <= 1)
+		e.AssertAreEqual(t, target+" less shrinkage with more data", true, longEst.Shrinkage < shortEst.Shrinkage)
+
+		// the targets share the sample variances so shrinking leaves them alone
+		sample := GetCovarianceMatrix(short)
+		for i := range 3 {
+			e.AssertAreEqual(t, target+" variance kept", true, math.Abs(shortCov.At(i, i)-sample.At(i, i)) < 1e-15)
+		}
+		if _, err := GetCholeskyDecomposition(longCov); err != nil {
+			t.Fatalf("shrunk matrix should factorize: %v", err)
+		}
+	}
+
+	// toward the diagonal the correlated pair loses correlation
+	request := SimulationRequest{Estimator: EstimatorLedoitWolf, ShrinkageTarget: TargetDiagonal}
+	shrunk, _, _ := EstimateCovariance(request, short, Daily)
+	sample := GetCovarianceMatrix(short)
+	e.AssertAreEqual(t, "covariance shrunk toward zero", true, math.Abs(shrunk.At(0, 1)) < math.Abs(sample.At(0, 1)))
+}
+
+func Test_CovarianceEstimators_EwmaWeightsRecentReturns(t *testing.T) {
+	returns := [][]float64{
+		{0.01, 0.02, -0.03},
+		{0.02, -0.01, 0.01},
+	}
+
+	cov, est, err := EstimateCovariance(SimulationRequest{Estimator: EstimatorEwma, HalfLife: 1}, returns, Weekly)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	e.AssertAreEqual(t, "lambda", 0.5, est.Lambda)
+
+	// weights 0.25, 0.5, 1 oldest to newest, zero mean
+	total := 1.75
+	expectedVar := (0.25*0.0001 + 0.5*0.0004 + 1*0.0009) / total
+	expectedCov := (0.25*0.0002 + 0.5*-0.0002 + 1*-0.0003) / total
+	e.AssertAreEqual(t, "variance", true, math.Abs(cov.At(0, 0)-expectedVar) < 1e-15)
+	e.AssertAreEqual(t, "covariance", true, math.Abs(cov.At(0, 1)-expectedCov) < 1e-15)
+
+	_, est, _ = EstimateCovariance(SimulationRequest{Estimator: EstimatorEwma}, returns, Daily)
+	e.AssertAreEqual(t, "riskmetrics decay", true, math.Abs(est.Lambda-0.94) < 1e-12)
+
+	// the same half-life in time, each week decays like 252/52 trading days
+	_, est, _ = EstimateCovariance(SimulationRequest{Estimator: EstimatorEwma}, returns, Weekly)
+	e.AssertAreEqual(t, "weekly half-life", true, math.Abs(est.HalfLife-DefaultEwmaHalfLifeDays*Weekly/Daily) < 1e-12)
+	e.AssertAreEqual(t, "weekly decay", true, math.Abs(est.Lambda-math.Pow(0.94, float64(Daily)/Weekly)) < 1e-12)
+
+	if _, _, err := EstimateCovariance(SimulationRequest{Estimator: EstimatorEwma, HalfLife: -2}, returns, Daily); err == nil {
+		t.Fatalf("expected a negative half-life to be rejected")
+	}
+}
+
+func Test_CovarianceEstimators_ResourcesReportEstimator(t *testing.T) {
+	returns := generateMockSeriesReturns(t, Daily*2)
+
+	sr, err := GetStatisticalResources(SimulationRequest{Estimator: EstimatorEwma, HalfLife: 20}, returns)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	e.AssertAreEqual(t, "estimator", EstimatorEwma, sr.CovarianceEstimate.Estimator)
+	e.AssertAreEqual(t, "sigma from ewma", true, math.Abs(sr.Sigma[0]-math.Sqrt(sr.CovMatrix.At(0, 0)*Daily)) < 1e-12)
+
+	sr, _ = GetStatisticalResources(SimulationRequest{}, returns)
+	e.AssertAreEqual(t, "default estimator", EstimatorSample, sr.CovarianceEstimate.Estimator)
+
+	request := SimulationRequest{
+		Allocations: []SimulationAllocation{{Id: 1, Weight: 1}},
+		Alignment:   AlignPairwise,
+		Estimator:   EstimatorLedoitWolf,
+	}
+	if err := request.Validate(); err == nil {
+		t.Fatalf("expected shrinkage with pairwise alignment to be rejected")
+	}
+	request.Estimator = "robust"
+	request.Alignment = ""
+	if err := request.Validate(); err == nil {
+		t.Fatalf("expected an unknown estimator to be rejected")
+	}
+}
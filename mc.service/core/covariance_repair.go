@@ -0,0 +1,218 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// how a covariance matrix that is not positive definite gets repaired before the cholesky decomposition
+const (
+	RepairNone   = "none"   // fail like before
+	RepairClip   = "clip"   // floor the eigenvalues of the correlation matrix
+	RepairHigham = "higham" // nearest correlation matrix by alternating projections (Higham 2002)
+)
+
+const (
+	minRepairEigenvalue = 1e-8 // floor on the correlation scale so the result factorizes
+	highamMaxIterations = 200
+	highamTolerance     = 1e-10
+)
+
+// CovarianceRepairReport describes how much a covariance matrix was altered to make it positive definite
+type CovarianceRepairReport struct {
+	Method               string  `json:"method"`
+	Repaired             bool    `json:"repaired"` // false when the matrix was already positive definite
+	MinEigenvalueBefore  float64 `json:"mineigenvaluebefore"`
+	MinEigenvalueAfter   float64 `json:"mineigenvalueafter"`
+	FrobeniusChange      float64 `json:"frobeniuschange"`      // ||repaired - original|| on the covariance
+	RelativeChange       float64 `json:"relativechange"`       // FrobeniusChange / ||original||
+	MaxCorrelationChange float64 `json:"maxcorrelationchange"` // largest absolute change of any pairwise correlation
+	Iterations           int     `json:"iterations"`
+}
+
+func normalizeRepairMethod(method string) (string, error) {
+	switch m := strings.ToLower(strings.TrimSpace(method)); m {
+	case "":
+		return RepairNone, nil
+	case RepairNone, RepairClip, RepairHigham:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unknown covariance repair %q, expected %s, %s or %s", method, RepairNone, RepairClip, RepairHigham)
+	}
+}
+
+// RepairCovariance returns the nearest positive definite matrix to cov using the given method.
+// The variances are kept, only the correlation structure is adjusted. A matrix that is already
+// positive definite is returned as is.
+func RepairCovariance(cov *mat.SymDense, method string) (*mat.SymDense, *CovarianceRepairReport, error) {
+	method, err := normalizeRepairMethod(method)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	report := &CovarianceRepairReport{Method: method}
+	report.MinEigenvalueBefore, err = minEigenvalue(cov)
+	if err != nil {
+		return nil, nil, err
+	}
+	report.MinEigenvalueAfter = report.MinEigenvalueBefore
+
+	if method == RepairNone || isPositiveDefinite(cov) {
+		return cov, report, nil
+	}
+
+	n := cov.SymmetricDim()
+	std := make([]float64, n)
+	for i := range n {
+		if cov.At(i, i) <= 0 {
+			return nil, nil, fmt.Errorf("asset %d has a non positive variance, the covariance matrix cannot be repaired", i)
+		}
+		std[i] = math.Sqrt(cov.At(i, i))
+	}
+
+	corr := mat.NewSymDense(n, nil)
+	for i := range n {
+		for j := range i + 1 {
+			corr.SetSym(i, j, cov.At(i, j)/(std[i]*std[j]))
+		}
+	}
+
+	var repaired *mat.SymDense
+	switch method {
+	case RepairClip:
+		repaired, err = clipEigenvalues(corr, minRepairEigenvalue)
+	case RepairHigham:
+		repaired, report.Iterations, err = nearestCorrelation(corr)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	res := mat.NewSymDense(n, nil)
+	for i := range n {
+		for j := range i + 1 {
+			report.MaxCorrelationChange = math.Max(report.MaxCorrelationChange, math.Abs(repaired.At(i, j)-corr.At(i, j)))
+			res.SetSym(i, j, repaired.At(i, j)*std[i]*std[j])
+		}
+	}
+
+	var diff mat.Dense
+	diff.Sub(res, cov)
+	report.Repaired = true
+	report.FrobeniusChange = mat.Norm(&diff, 2)
+	report.RelativeChange = report.FrobeniusChange / mat.Norm(cov, 2)
+	report.MinEigenvalueAfter, err = minEigenvalue(res)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return res, report, nil
+}
+
+func isPositiveDefinite(a *mat.SymDense) bool {
+	var chol mat.Cholesky
+	return chol.Factorize(a)
+}
+
+func minEigenvalue(a *mat.SymDense) (float64, error) {
+	var es mat.EigenSym
+	if ok := es.Factorize(a, false); !ok {
+		return 0, fmt.Errorf("eigen decomposition of the covariance matrix failed")
+	}
+	return es.Values(nil)[0], nil // ascending
+}
+
+// clipEigenvalues floors the eigenvalues of a correlation matrix and rescales it back to a unit diagonal
+func clipEigenvalues(corr *mat.SymDense, floor float64) (*mat.SymDense, error) {
+	psd, err := projectPSD(corr, floor)
+	if err != nil {
+		return nil, err
+	}
+	return unitDiagonal(psd), nil
+}
+
+// nearestCorrelation is Higham's alternating projections with Dykstra's correction between the positive
+// semidefinite cone and the unit diagonal matrices, followed by an eigenvalue floor so it factorizes
+func nearestCorrelation(corr *mat.SymDense) (*mat.SymDense, int, error) {
+	n := corr.SymmetricDim()
+	y := mat.NewSymDense(n, nil)
+	y.CopySym(corr)
+	correction := mat.NewSymDense(n, nil)
+	r := mat.NewSymDense(n, nil)
+
+	iterations := 0
+	for iterations < highamMaxIterations {
+		iterations++
+		subSym(r, y, correction)
+		x, err := projectPSD(r, 0)
+		if err != nil {
+			return nil, iterations, err
+		}
+		subSym(correction, x, r)
+
+		next := mat.NewSymDense(n, nil)
+		next.CopySym(x)
+		for i := range n {
+			next.SetSym(i, i, 1)
+		}
+
+		var step mat.Dense
+		step.Sub(next, y)
+		change := mat.Norm(&step, 2) / mat.Norm(next, 2)
+		y = next
+		if change < highamTolerance {
+			break
+		}
+	}
+
+	res, err := clipEigenvalues(y, minRepairEigenvalue)
+	return res, iterations, err
+}
+
+// projectPSD rebuilds a symmetric matrix with its eigenvalues floored at floor
+func projectPSD(a *mat.SymDense, floor float64) (*mat.SymDense, error) {
+	var es mat.EigenSym
+	if ok := es.Factorize(a, true); !ok {
+		return nil, fmt.Errorf("eigen decomposition of the correlation matrix failed")
+	}
+
+	values := es.Values(nil)
+	var vectors mat.Dense
+	es.VectorsTo(&vectors)
+
+	n := len(values)
+	res := mat.NewSymDense(n, nil)
+	for i := range n {
+		for j := range i + 1 {
+			v := 0.0
+			for k := range n {
+				v += vectors.At(i, k) * math.Max(values[k], floor) * vectors.At(j, k)
+			}
+			res.SetSym(i, j, v)
+		}
+	}
+	return res, nil
+}
+
+func unitDiagonal(a *mat.SymDense) *mat.SymDense {
+	n := a.SymmetricDim()
+	res := mat.NewSymDense(n, nil)
+	for i := range n {
+		for j := range i + 1 {
+			res.SetSym(i, j, a.At(i, j)/math.Sqrt(a.At(i, i)*a.At(j, j)))
+		}
+	}
+	return res
+}
+
+func subSym(dst, a, b *mat.SymDense) {
+	n := a.SymmetricDim()
+	for i := range n {
+		for j := range i + 1 {
+			dst.SetSym(i, j, a.At(i, j)-b.At(i, j))
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package core
+
+import (
+	"math"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+	e "mc.data/extensions"
+)
+
+// highamExample is the 3x3 example from Higham (2002), its nearest correlation matrix is known
+func highamExample() *mat.SymDense {
+	return mat.NewSymDense(3, []float64{
+		1, 1, 0,
+		1, 1, 1,
+		0, 1, 1,
+	})
+}
+
+func Test_CovarianceRepair_HighamMatchesPublishedResult(t *testing.T) {
+	repaired, report, err := RepairCovariance(highamExample(), RepairHigham)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	e.AssertAreEqual(t, "repaired", true, report.Repaired)
+	e.AssertAreEqual(t, "min eigenvalue before", true, report.MinEigenvalueBefore < 0)
+	e.AssertAreEqual(t, "min eigenvalue after", true, report.MinEigenvalueAfter > 0)
+	e.AssertAreEqual(t, "iterations", true, report.Iterations > 1)
+
+	expected := [][3]float64{{1, 0.7607, 0.1573}, {0.7607, 1, 0.7607}, {0.1573, 0.7607, 1}}
+	for i := range 3 {
+		for j := range 3 {
+			if math.Abs(repaired.At(i, j)-expected[i][j]) > 1e-3 {
+				t.Fatalf("element (%d,%d): expected %.4f, got %.4f", i, j, expected[i][j], repaired.At(i, j))
+			}
+		}
+	}
+
+	if _, err := GetCholeskyDecomposition(repaired); err != nil {
+		t.Fatalf("repaired matrix should factorize: %v", err)
+	}
+}
+
+func Test_CovarianceRepair_ClipKeepsVariances(t *testing.T) {
+	// two near duplicate etfs and a third asset whose pairwise correlations cannot all hold at once
+	std := []float64{0.15, 0.16, 0.30}
+	corr := []float64{
+		1, 0.99, 0.9,
+		0.99, 1, 0.7,
+		0.9, 0.7, 1,
+	}
+	cov := mat.NewSymDense(3, nil)
+	for i := range 3 {
+		for j := range i + 1 {
+			cov.SetSym(i, j, corr[i*3+j]*std[i]*std[j])
+		}
+	}
+	if _, err := GetCholeskyDecomposition(cov); err == nil {
+		t.Fatalf("fixture should not be positive definite")
+	}
+
+	repaired, report, err := RepairCovariance(cov, RepairClip)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for i := range 3 {
+		e.AssertAreEqual(t, "variance kept", true, math.Abs(repaired.At(i, i)-std[i]*std[i]) < 1e-12)
+	}
+	e.AssertAreEqual(t, "relative change reported", true, report.RelativeChange > 0 && report.RelativeChange < 0.1)
+	e.AssertAreEqual(t, "correlation change reported", true, report.MaxCorrelationChange > 0)
+	if _, err := GetCholeskyDecomposition(repaired); err != nil {
+		t.Fatalf("repaired matrix should factorize: %v", err)
+	}
+}
+
+func Test_CovarianceRepair_LeavesPositiveDefiniteAlone(t *testing.T) {
+	cov := mat.NewSymDense(2, []float64{0.04, 0.01, 0.01, 0.09})
+
+	repaired, report, err := RepairCovariance(cov, RepairHigham)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	e.AssertAreEqual(t, "repaired", false, report.Repaired)
+	e.AssertAreEqual(t, "same matrix", cov, repaired)
+
+	if _, _, err := RepairCovariance(cov, "shrink"); err == nil {
+		t.Fatalf("expected an unknown method to be rejected")
+	}
+}
+
+func Test_CovarianceRepair_PairwiseResourcesBuildWithRepair(t *testing.T) {
+	allocations, series := alignmentFixture()
+	aligned, _, err := AlignSeriesReturns(AlignPairwise, allocations, series)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := GetStatisticalResources(SimulationRequest{Alignment: AlignPairwise}, aligned); err == nil {
+		t.Fatalf("expected the unrepaired pairwise matrix to fail")
+	}
+
+	sr, err := GetStatisticalResources(SimulationRequest{Alignment: AlignPairwise, CovarianceRepair: RepairHigham}, aligned)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	e.AssertAreEqual(t, "repaired", true, sr.CovarianceRepair.Repaired)
+}
@@ -0,0 +1,159 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule is a standard five field cron expression, minute hour day-of-month month day-of-week.
+// Fields take *, numbers, ranges (1-5), lists (1,15) and steps (*/15, 0-30/10). Sunday is 0 or 7.
+// The @hourly, @daily (@midnight), @weekly, @monthly and @yearly (@annually) shorthands are accepted too.
+type CronSchedule struct {
+	expression    string
+	minutes       []bool
+	hours         []bool
+	daysOfMonth   []bool
+	months        []bool
+	daysOfWeek    []bool
+	domRestricted bool
+	dowRestricted bool
+}
+
+var cronShorthands = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+func ParseCron(expression string) (*CronSchedule, error) {
+	expanded := strings.TrimSpace(expression)
+	if v, ok := cronShorthands[strings.ToLower(expanded)]; ok {
+		expanded = v
+	}
+
+	fields := strings.Fields(expanded)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q should have 5 fields, got %d", expression, len(fields))
+	}
+
+	res := &CronSchedule{expression: expression}
+	var err error
+	if res.minutes, _, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("invalid minute in %q: %w", expression, err)
+	}
+	if res.hours, _, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("invalid hour in %q: %w", expression, err)
+	}
+	if res.daysOfMonth, res.domRestricted, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("invalid day of month in %q: %w", expression, err)
+	}
+	if res.months, _, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("invalid month in %q: %w", expression, err)
+	}
+	if res.daysOfWeek, res.dowRestricted, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("invalid day of week in %q: %w", expression, err)
+	}
+
+	// 7 is an alias for sunday
+	res.daysOfWeek[0] = res.daysOfWeek[0] || res.daysOfWeek[7]
+
+	return res, nil
+}
+
+// parseCronField returns a lookup indexed by value, and whether the field was anything other than *
+func parseCronField(field string, lo, hi int) ([]bool, bool, error) {
+	res := make([]bool, hi+1)
+	restricted := field != "*"
+
+	for item := range strings.SplitSeq(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(item, "/")
+
+		step := 1
+		if hasStep {
+			s, err := strconv.Atoi(stepPart)
+			if err != nil || s <= 0 {
+				return nil, false, fmt.Errorf("invalid step %q", stepPart)
+			}
+			step = s
+		}
+
+		start, end := lo, hi
+		if rangePart != "*" {
+			a, b, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if start, err = strconv.Atoi(a); err != nil {
+				return nil, false, fmt.Errorf("invalid value %q", a)
+			}
+			end = start
+			if isRange {
+				if end, err = strconv.Atoi(b); err != nil {
+					return nil, false, fmt.Errorf("invalid value %q", b)
+				}
+			} else if hasStep {
+				// 5/15 means every 15 starting at 5
+				end = hi
+			}
+		}
+
+		if start < lo || end > hi || start > end {
+			return nil, false, fmt.Errorf("%q is outside %d-%d", item, lo, hi)
+		}
+
+		for v := start; v <= end; v += step {
+			res[v] = true
+		}
+	}
+
+	return res, restricted, nil
+}
+
+// Next returns the first matching minute strictly after t, in t's location
+func (cs *CronSchedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+
+	// every valid expression matches within a few years, this only guards against 30 2 31 2 * style specs
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case !cs.months[t.Month()]:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !cs.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !cs.hours[t.Hour()]:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !cs.minutes[t.Minute()]:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+// when both day fields are restricted cron matches either of them
+func (cs *CronSchedule) dayMatches(t time.Time) bool {
+	dom := cs.daysOfMonth[t.Day()]
+	dow := cs.daysOfWeek[t.Weekday()]
+
+	switch {
+	case cs.domRestricted && cs.dowRestricted:
+		return dom || dow
+	case cs.domRestricted:
+		return dom
+	case cs.dowRestricted:
+		return dow
+	default:
+		return true
+	}
+}
+
+func (cs *CronSchedule) String() string {
+	return cs.expression
+}
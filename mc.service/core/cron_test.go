@@ -0,0 +1,43 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	e "mc.data/extensions"
+)
+
+func Test_Cron_Next(t *testing.T) {
+	// a wednesday
+	from := time.Date(2025, time.October, 29, 10, 17, 30, 0, time.UTC)
+
+	for _, tc := range []struct {
+		expression string
+		expected   string
+	}{
+		{"*/15 * * * *", "2025-10-29 10:30"},
+		{"0 6 * * *", "2025-10-30 06:00"},
+		{"@daily", "2025-10-30 00:00"},
+		{"@hourly", "2025-10-29 11:00"},
+		{"30 22 * * 1-5", "2025-10-29 22:30"},
+		{"0 9 * * 6,7", "2025-11-01 09:00"},
+		{"0 0 1 * *", "2025-11-01 00:00"},
+		{"0 0 15 * 1", "2025-11-03 00:00"}, // either the 15th or a monday
+		{"5/20 * * * *", "2025-10-29 10:25"},
+		{"0 0 29 2 *", "2028-02-29 00:00"},
+	} {
+		cs, err := ParseCron(tc.expression)
+		if err != nil {
+			t.Fatalf("error parsing %s: %s", tc.expression, err)
+		}
+		e.AssertAreEqual(t, tc.expression, tc.expected, cs.Next(from).Format("2006-01-02 15:04"))
+	}
+}
+
+func Test_Cron_RejectsInvalidExpressions(t *testing.T) {
+	for _, expression := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		if _, err := ParseCron(expression); err == nil {
+			t.Fatalf("expected %q to be rejected", expression)
+		}
+	}
+}
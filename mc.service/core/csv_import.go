@@ -0,0 +1,471 @@
+package core
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	ex "mc.data/extensions"
+	m "mc.data/models"
+)
+
+const (
+	// recorded as the provider for symbols first created by an import
+	CsvImportProvider = "csv"
+
+	// cap on row errors echoed back, the count is always complete
+	maxReportedImportErrors = 100
+
+	importTimestamp      = "timestamp"
+	importOpen           = "open"
+	importHigh           = "high"
+	importLow            = "low"
+	importClose          = "close"
+	importVolume         = "volume"
+	importAdjustedClose  = "adjusted close"
+	importDividendAmount = "dividend amount"
+	importSymbol         = "symbol"
+)
+
+var (
+	// ErrImportValidation is returned alongside a report when rows fail validation, nothing is loaded
+	ErrImportValidation = errors.New("csv import failed validation")
+
+	// ErrImportFrequency is returned when the file's bars are not at the stored series' frequency
+	ErrImportFrequency = errors.New("csv import frequency does not match the stored series")
+
+	importFields = []string{
+		importTimestamp, importOpen, importHigh, importLow, importClose,
+		importVolume, importAdjustedClose, importDividendAmount, importSymbol,
+	}
+
+	// common vendor headers, after normalization, for when no explicit column mapping is given
+	importHeaderAliases = map[string]string{
+		"date":        importTimestamp,
+		"time":        importTimestamp,
+		"datetime":    importTimestamp,
+		"adj close":   importAdjustedClose,
+		"adjclose":    importAdjustedClose,
+		"adjusted":    importAdjustedClose,
+		"dividend":    importDividendAmount,
+		"dividends":   importDividendAmount,
+		"ticker":      importSymbol,
+		"vol":         importVolume,
+		"close price": importClose,
+	}
+
+	importDateFormats = []string{"2006-01-02", "2006-01-02 15:04:05", time.RFC3339, "01/02/2006", "1/2/2006", "20060102"}
+)
+
+type CsvImportRequest struct {
+	Symbol     string            `json:"symbol"`     // used when the file has no symbol column
+	Columns    map[string]string `json:"columns"`    // csv header -> field, e.g. {"Adj Close": "adjusted_close"}. headers are matched by name when empty
+	DateFormat string            `json:"dateformat"` // go layout, several common formats are tried when empty
+	DryRun     bool              `json:"dryrun"`     // validate and report what would change without writing
+
+	// load bars into an existing series stored at another frequency, which mixes the two in its returns
+	AllowFrequencyMismatch bool `json:"allowfrequencymismatch"`
+}
+
+type CsvImportReport struct {
+	DryRun      bool                     `json:"dryrun"`
+	RowsRead    int                      `json:"rowsread"`
+	ErrorCount  int                      `json:"errorcount"`
+	Errors      []CsvImportRowError      `json:"errors"`
+	SymbolStats []*CsvImportSymbolReport `json:"symbols"`
+}
+
+type CsvImportRowError struct {
+	Row     int    `json:"row"` // 1 based, the header is row 1
+	Message string `json:"message"`
+}
+
+type CsvImportSymbolReport struct {
+	Symbol     string `json:"symbol"`
+	NewSymbol  bool   `json:"newsymbol"`
+	Rows       int    `json:"rows"`
+	Inserted   int    `json:"inserted"`
+	Updated    int    `json:"updated"`
+	Unchanged  int    `json:"unchanged"`
+	FirstDate  string `json:"firstdate"`
+	LastDate   string `json:"lastdate"`
+	RowsLoaded int64  `json:"rowsloaded"`
+}
+
+// ImportTimeSeriesCsv loads vendor price files. Every row is validated before anything is written,
+// new symbols get a metadata row, and overlapping dates are upserted.
+func (sc *ServiceContext) ImportTimeSeriesCsv(reader io.Reader, request CsvImportRequest) (*CsvImportReport, error) {
+	bySymbol, report, err := parseCsvImport(reader, request)
+	if err != nil {
+		return nil, err
+	}
+
+	if report.ErrorCount > 0 {
+		return report, fmt.Errorf("%w: %d invalid rows", ErrImportValidation, report.ErrorCount)
+	}
+
+	for _, symbol := range slices.Sorted(maps.Keys(bySymbol)) {
+		symbolReport, err := sc.importSymbol(symbol, bySymbol[symbol], request)
+		if err != nil {
+			return report, err
+		}
+		report.SymbolStats = append(report.SymbolStats, symbolReport)
+	}
+
+	return report, nil
+}
+
+func (sc *ServiceContext) importSymbol(symbol string, bars []*m.TimeSeriesData, request CsvImportRequest) (*CsvImportSymbolReport, error) {
+	md, err := sc.PostgresConnection.GetMetaDataBySymbol(sc.Context, symbol)
+	if err != nil {
+		return nil, fmt.Errorf("error determining if meta data exists for %s: %w", symbol, err)
+	}
+
+	if md != nil && !request.AllowFrequencyMismatch {
+		if err := checkImportFrequency(md, bars); err != nil {
+			return nil, err
+		}
+	}
+
+	var existing []*m.TimeSeriesData
+	if md != nil {
+		existing, err = sc.PostgresConnection.GetTimeSeriesData(sc.Context, symbol)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	diff := diffTimeSeriesData(existing, bars)
+	first, last := bars[0].Timestamp, bars[0].Timestamp
+	for _, b := range bars {
+		first = minTime(first, b.Timestamp)
+		last = maxTime(last, b.Timestamp)
+	}
+
+	res := &CsvImportSymbolReport{
+		Symbol:    symbol,
+		NewSymbol: md == nil,
+		Rows:      len(bars),
+		Inserted:  len(diff.Inserts),
+		Updated:   len(diff.Updates),
+		Unchanged: diff.Unchanged,
+		FirstDate: ex.FmtShort(first),
+		LastDate:  ex.FmtShort(last),
+	}
+
+	toLoad := append(diff.Inserts, diff.Updates...)
+	if request.DryRun || len(toLoad) == 0 {
+		return res, nil
+	}
+
+	tx, err := sc.PostgresConnection.GetTransaction(sc.Context)
+	if err != nil {
+		return nil, fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer tx.Rollback(sc.Context) // this will kick off if we return before committing
+
+	// existing symbols keep their provider, the import only fills in bars
+	if md == nil {
+		log.Printf("adding new symbol to db from csv import: %s", symbol)
+		md = &m.TimeSeriesMetadata{
+			Symbol:        symbol,
+			LastRefreshed: last,
+			Provider:      CsvImportProvider,
+			Frequency:     inferFrequency(bars),
+		}
+		if err := sc.PostgresConnection.InsertNewMetaData(sc.Context, md, &tx); err != nil {
+			return nil, fmt.Errorf("error adding %s to db: %w", symbol, err)
+		}
+	} else if last.After(md.LastRefreshed) {
+		if err := sc.PostgresConnection.UpdateLastRefreshedDate(sc.Context, symbol, last, &tx); err != nil {
+			return nil, err
+		}
+	}
+
+	res.RowsLoaded, err = sc.PostgresConnection.UpsertTimeSeriesData(sc.Context, toLoad, &md.Id, &tx)
+	if err != nil {
+		return nil, fmt.Errorf("error loading time series data for %s: %w", symbol, err)
+	}
+
+	if revisions := timeSeriesRevisions(diff, md.Id, CsvImportProvider); len(revisions) > 0 {
+		if _, err := sc.PostgresConnection.InsertTimeSeriesRevisions(sc.Context, revisions, &tx); err != nil {
+			return nil, fmt.Errorf("error logging time series revisions for %s: %w", symbol, err)
+		}
+	}
+
+	if _, err := sc.PostgresConnection.UpsertCorporateActions(sc.Context, extractCorporateActions(bars, CsvImportProvider), &md.Id, &tx); err != nil {
+		return nil, fmt.Errorf("error loading corporate actions for %s: %w", symbol, err)
+	}
+
+	if err := tx.Commit(sc.Context); err != nil {
+		return nil, fmt.Errorf("error committing csv import for %s: %w", symbol, err)
+	}
+
+	log.Printf("csv import for %s inserted %d and updated %d bars", symbol, res.Inserted, res.Updated)
+	return res, nil
+}
+
+// parseCsvImport validates every row and groups the bars by symbol. A returned error means the file
+// could not be read at all, row level problems are collected on the report instead.
+func parseCsvImport(reader io.Reader, request CsvImportRequest) (map[string][]*m.TimeSeriesData, *CsvImportReport, error) {
+	cr := csv.NewReader(reader)
+	cr.TrimLeadingSpace = true
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading csv header: %w", err)
+	}
+
+	columns, err := mapImportColumns(header, request.Columns)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if _, ok := columns[importSymbol]; !ok && request.Symbol == "" {
+		return nil, nil, fmt.Errorf("a symbol is required when the file has no symbol column")
+	}
+
+	report := &CsvImportReport{
+		DryRun: request.DryRun,
+		Errors: []CsvImportRowError{},
+	}
+	addError := func(row int, format string, args ...any) {
+		report.ErrorCount++
+		if len(report.Errors) < maxReportedImportErrors {
+			report.Errors = append(report.Errors, CsvImportRowError{Row: row, Message: fmt.Sprintf(format, args...)})
+		}
+	}
+
+	bySymbol := map[string][]*m.TimeSeriesData{}
+	seen := map[string]int{}
+	for row := 2; ; row++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			addError(row, "unreadable row: %v", err)
+			continue
+		}
+		report.RowsRead++
+
+		value := func(field string) string {
+			i, ok := columns[field]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		symbol := strings.ToUpper(value(importSymbol))
+		if symbol == "" {
+			symbol = strings.ToUpper(request.Symbol)
+		}
+
+		timestamp, err := parseImportDate(value(importTimestamp), request.DateFormat)
+		if err != nil {
+			addError(row, "%v", err)
+			continue
+		}
+
+		key := symbol + " " + ex.FmtShort(timestamp)
+		if prev, ok := seen[key]; ok {
+			addError(row, "duplicate date %s for %s, first seen on row %d", ex.FmtShort(timestamp), symbol, prev)
+			continue
+		}
+		seen[key] = row
+
+		bar, err := parseImportBar(value, timestamp)
+		if err != nil {
+			addError(row, "%v", err)
+			continue
+		}
+
+		bySymbol[symbol] = append(bySymbol[symbol], bar)
+	}
+
+	return bySymbol, report, nil
+}
+
+// mapImportColumns resolves field -> column index, from the explicit mapping when given, else by header name
+func mapImportColumns(header []string, mapping map[string]string) (map[string]int, error) {
+	res := map[string]int{}
+	for i, h := range header {
+		h = normalizeImportName(h)
+		if len(mapping) > 0 {
+			continue
+		}
+		if slices.Contains(importFields, h) {
+			res[h] = i
+		} else if alias, ok := importHeaderAliases[h]; ok {
+			if _, taken := res[alias]; !taken {
+				res[alias] = i
+			}
+		}
+	}
+
+	for column, field := range mapping {
+		field = normalizeImportName(field)
+		if !slices.Contains(importFields, field) {
+			return nil, fmt.Errorf("unrecognized import field %s for column %s, expected one of %v", field, column, importFields)
+		}
+
+		i := slices.IndexFunc(header, func(h string) bool { return ex.AreEqual(strings.TrimSpace(h), strings.TrimSpace(column)) })
+		if i < 0 {
+			return nil, fmt.Errorf("mapped column %s is not in the csv header %v", column, header)
+		}
+		res[field] = i
+	}
+
+	for _, required := range []string{importTimestamp, importClose} {
+		if _, ok := res[required]; !ok {
+			return nil, fmt.Errorf("no column mapped to %s, csv header is %v", required, header)
+		}
+	}
+
+	return res, nil
+}
+
+// parseImportBar reads the numeric fields. only close is required, missing open/high/low fall back to close,
+// adjusted close falls back to close and volume/dividends to zero
+func parseImportBar(value func(string) string, timestamp time.Time) (*m.TimeSeriesData, error) {
+	number := func(field string, fallback float64) (float64, error) {
+		raw := strings.ReplaceAll(value(field), ",", "")
+		if raw == "" {
+			return fallback, nil
+		}
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			return 0, fmt.Errorf("invalid %s value %q", field, value(field))
+		}
+		return v, nil
+	}
+
+	closePrice, err := number(importClose, math.NaN())
+	if err != nil {
+		return nil, err
+	}
+	if math.IsNaN(closePrice) {
+		return nil, fmt.Errorf("close is required")
+	}
+
+	bar := &m.TimeSeriesData{Timestamp: timestamp}
+	bar.Close = closePrice
+	if bar.Open, err = number(importOpen, closePrice); err != nil {
+		return nil, err
+	}
+	if bar.High, err = number(importHigh, math.Max(bar.Open, closePrice)); err != nil {
+		return nil, err
+	}
+	if bar.Low, err = number(importLow, math.Min(bar.Open, closePrice)); err != nil {
+		return nil, err
+	}
+	if bar.Volume, err = number(importVolume, 0); err != nil {
+		return nil, err
+	}
+	if bar.AdjustedClose, err = number(importAdjustedClose, closePrice); err != nil {
+		return nil, err
+	}
+	if bar.DividendAmount, err = number(importDividendAmount, 0); err != nil {
+		return nil, err
+	}
+
+	for name, v := range map[string]float64{importOpen: bar.Open, importHigh: bar.High, importLow: bar.Low, importClose: bar.Close, importAdjustedClose: bar.AdjustedClose} {
+		if v <= 0 {
+			return nil, fmt.Errorf("%s must be positive, got %v", name, v)
+		}
+	}
+	if bar.Volume < 0 || bar.DividendAmount < 0 {
+		return nil, fmt.Errorf("volume and dividend amount cannot be negative")
+	}
+	if bar.Low > bar.High {
+		return nil, fmt.Errorf("low %v is above high %v", bar.Low, bar.High)
+	}
+
+	return bar, nil
+}
+
+func parseImportDate(value, format string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, fmt.Errorf("date is required")
+	}
+
+	formats := importDateFormats
+	if format != "" {
+		formats = []string{format}
+	}
+
+	for _, f := range formats {
+		if t, err := time.Parse(f, value); err == nil {
+			// stored as DATE, drop any time component
+			return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unrecognized date %q", value)
+}
+
+func normalizeImportName(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	s = strings.NewReplacer("_", " ", "-", " ").Replace(s)
+	return s
+}
+
+// checkImportFrequency rejects bars at another frequency than the stored series. A single bar has no
+// frequency of its own so it is always accepted
+func checkImportFrequency(md *m.TimeSeriesMetadata, bars []*m.TimeSeriesData) error {
+	if len(bars) < 2 {
+		return nil
+	}
+	if frequency := inferFrequency(bars); frequency != md.Frequency {
+		return fmt.Errorf("%w: %s is stored as %s but the file is %s, set allowfrequencymismatch to load it anyway",
+			ErrImportFrequency, md.Symbol, md.Frequency, frequency)
+	}
+	return nil
+}
+
+// inferFrequency classifies the median gap between bars, vendor files can be daily, weekly or monthly
+func inferFrequency(bars []*m.TimeSeriesData) string {
+	sorted := sortedAscending(bars)
+	if len(sorted) < 2 {
+		return m.FrequencyWeekly
+	}
+
+	gaps := make([]float64, len(sorted)-1)
+	for i := 1; i < len(sorted); i++ {
+		gaps[i-1] = sorted[i].Timestamp.Sub(sorted[i-1].Timestamp).Hours() / 24
+	}
+	slices.Sort(gaps)
+
+	switch median := gaps[len(gaps)/2]; {
+	case median <= 4:
+		return m.FrequencyDaily
+	case median <= 10:
+		return m.FrequencyWeekly
+	default:
+		return m.FrequencyMonthly
+	}
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,154 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	e "mc.data/extensions"
+	m "mc.data/models"
+)
+
+func Test_CsvImport_ParsesVendorHeaders(t *testing.T) {
+	file := `Date,Open,High,Low,Close,Adj Close,Volume
+2024-01-05,100.5,102,99.25,101,100.1,"1,200,000"
+2024-01-12,101,103.5,100,103,102.2,1100000
+`
+	bySymbol, report, err := parseCsvImport(strings.NewReader(file), CsvImportRequest{Symbol: "spy"})
+	if err != nil {
+		t.Fatalf("error parsing csv: %s", err)
+	}
+
+	e.AssertAreEqual(t, "rows read", 2, report.RowsRead)
+	e.AssertAreEqual(t, "error count", 0, report.ErrorCount)
+
+	bars := bySymbol["SPY"]
+	e.AssertAreEqual(t, "bar count", 2, len(bars))
+	e.AssertAreEqual(t, "date", "2024-01-05", e.FmtShort(bars[0].Timestamp))
+	e.AssertAreEqual(t, "adjusted close", 100.1, bars[0].AdjustedClose)
+	e.AssertAreEqual(t, "volume", 1_200_000.0, bars[0].Volume)
+	e.AssertAreEqual(t, "dividend default", 0.0, bars[1].DividendAmount)
+}
+
+func Test_CsvImport_ExplicitMappingAndSymbolColumn(t *testing.T) {
+	file := `Ticker,Day,Px
+msft,03/01/2024,400
+aapl,03/01/2024,180
+`
+	req := CsvImportRequest{
+		Columns:    map[string]string{"Ticker": "symbol", "Day": "timestamp", "Px": "close"},
+		DateFormat: "01/02/2006",
+	}
+
+	bySymbol, report, err := parseCsvImport(strings.NewReader(file), req)
+	if err != nil {
+		t.Fatalf("error parsing csv: %s", err)
+	}
+
+	e.AssertAreEqual(t, "error count", 0, report.ErrorCount)
+	e.AssertAreEqual(t, "symbols", 2, len(bySymbol))
+
+	bar := bySymbol["MSFT"][0]
+	e.AssertAreEqual(t, "date", "2024-03-01", e.FmtShort(bar.Timestamp))
+	e.AssertAreEqual(t, "open falls back to close", 400.0, bar.Open)
+	e.AssertAreEqual(t, "adjusted falls back to close", 400.0, bar.AdjustedClose)
+}
+
+func Test_CsvImport_ReportsRowErrors(t *testing.T) {
+	file := `date,open,high,low,close
+2024-01-05,100,102,99,101
+2024-01-05,100,102,99,101
+not a date,100,102,99,101
+2024-01-19,100,98,99,101
+2024-01-26,100,102,99,abc
+2024-02-02,100,102,99,-1
+`
+	_, report, err := parseCsvImport(strings.NewReader(file), CsvImportRequest{Symbol: "SPY"})
+	if err != nil {
+		t.Fatalf("error parsing csv: %s", err)
+	}
+
+	e.AssertAreEqual(t, "rows read", 6, report.RowsRead)
+	e.AssertAreEqual(t, "error count", 5, report.ErrorCount)
+	e.AssertAreEqual(t, "duplicate row", 3, report.Errors[0].Row)
+	e.AssertAreEqual(t, "bad date row", 4, report.Errors[1].Row)
+}
+
+func Test_CsvImport_RejectsUnusableFiles(t *testing.T) {
+	if _, _, err := parseCsvImport(strings.NewReader("date,open\n2024-01-05,1\n"), CsvImportRequest{Symbol: "SPY"}); err == nil {
+		t.Fatalf("expected an error when no column maps to close")
+	}
+
+	if _, _, err := parseCsvImport(strings.NewReader("date,close\n2024-01-05,1\n"), CsvImportRequest{}); err == nil {
+		t.Fatalf("expected an error without a symbol column or symbol")
+	}
+
+	req := CsvImportRequest{Symbol: "SPY", Columns: map[string]string{"date": "when"}}
+	if _, _, err := parseCsvImport(strings.NewReader("date,close\n2024-01-05,1\n"), req); err == nil {
+		t.Fatalf("expected an error for an unknown field in the mapping")
+	}
+}
+
+func Test_CsvImport_DiffSplitsInsertsAndUpdates(t *testing.T) {
+	existing, _, _ := parseCsvImport(strings.NewReader(`date,close,adjusted close
+2024-01-05,100,98
+2024-01-12,101,99
+`), CsvImportRequest{Symbol: "SPY"})
+	incoming, _, _ := parseCsvImport(strings.NewReader(`date,close,adjusted close
+2024-01-05,100,98.00001
+2024-01-12,101,97.5
+2024-01-19,102,100
+`), CsvImportRequest{Symbol: "SPY"})
+
+	diff := diffTimeSeriesData(existing["SPY"], incoming["SPY"])
+	e.AssertAreEqual(t, "inserts", 1, len(diff.Inserts))
+	e.AssertAreEqual(t, "updates", 1, len(diff.Updates))
+	e.AssertAreEqual(t, "unchanged", 1, diff.Unchanged)
+	e.AssertAreEqual(t, "previous adjusted close", 99.0, diff.Previous[0].AdjustedClose)
+}
+
+func Test_CsvImport_RevisionsListChangedFields(t *testing.T) {
+	existing, _, _ := parseCsvImport(strings.NewReader(`date,close,adjusted close,dividends
+2024-01-05,100,98,0
+2024-01-12,101,99,0
+`), CsvImportRequest{Symbol: "SPY"})
+	incoming, _, _ := parseCsvImport(strings.NewReader(`date,close,adjusted close,dividends
+2024-01-05,100,97.5,0
+2024-01-12,101,98.4,0.5
+2024-01-19,102,100,0
+`), CsvImportRequest{Symbol: "SPY"})
+
+	diff := diffTimeSeriesData(existing["SPY"], incoming["SPY"])
+	revisions := timeSeriesRevisions(diff, 7, CsvImportProvider)
+
+	e.AssertAreEqual(t, "revision count", 3, len(revisions))
+	e.AssertAreEqual(t, "first field", "adjusted_close", revisions[0].Field)
+	e.AssertAreEqual(t, "first previous", 98.0, revisions[0].PreviousValue)
+	e.AssertAreEqual(t, "first new", 97.5, revisions[0].NewValue)
+	e.AssertAreEqual(t, "dividend field", "dividend_amount", revisions[2].Field)
+	e.AssertAreEqual(t, "source id", int32(7), revisions[2].SourceId)
+	e.AssertAreEqual(t, "provider", CsvImportProvider, revisions[2].Provider)
+}
+
+func Test_CsvImport_RejectsAnotherFrequency(t *testing.T) {
+	daily, _, _ := parseCsvImport(strings.NewReader(`date,close
+2024-01-08,100
+2024-01-09,101
+2024-01-10,102
+2024-01-11,101
+`), CsvImportRequest{Symbol: "SPY"})
+
+	md := &m.TimeSeriesMetadata{Symbol: "SPY", Frequency: m.FrequencyWeekly}
+	err := checkImportFrequency(md, daily["SPY"])
+	e.AssertAreEqual(t, "daily into weekly", true, errors.Is(err, ErrImportFrequency))
+
+	md.Frequency = m.FrequencyDaily
+	if err := checkImportFrequency(md, daily["SPY"]); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	md.Frequency = m.FrequencyMonthly
+	if err := checkImportFrequency(md, daily["SPY"][:1]); err != nil {
+		t.Fatalf("a single bar should be accepted, got %v", err)
+	}
+}
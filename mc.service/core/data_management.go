@@ -1,14 +1,34 @@
 package core
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"time"
 
 	ex "mc.data/extensions"
 	m "mc.data/models"
 )
 
+// ErrDataIsFresh is returned by SyncSymbolTimeSeriesData when the stored series is recent enough to skip
+var ErrDataIsFresh = errors.New("stored data is fresh")
+
+// ErrNotProviderSeries is returned by SyncSymbolTimeSeriesData for series the provider's history would overwrite
+var ErrNotProviderSeries = errors.New("series is not synced from the market data provider")
+
+// checkProviderSeries rejects imported series and any stored at a frequency other than the weekly history
+// providers serve, syncing those would replace their bars with weekly ones and log false revisions
+func checkProviderSeries(md *m.TimeSeriesMetadata) error {
+	if md.Provider == CsvImportProvider {
+		return fmt.Errorf("%w: %s was imported from csv, import it again to update it", ErrNotProviderSeries, md.Symbol)
+	}
+	if md.Frequency != m.FrequencyWeekly {
+		return fmt.Errorf("%w: %s is stored as %s and the provider serves weekly history", ErrNotProviderSeries, md.Symbol, md.Frequency)
+	}
+	return nil
+}
+
 func (sc *ServiceContext) SyncSymbolTimeSeriesData(symbol string) (time.Time, error) {
 	md, err := sc.PostgresConnection.GetMetaDataBySymbol(sc.Context, symbol)
 
@@ -16,11 +36,14 @@ func (sc *ServiceContext) SyncSymbolTimeSeriesData(symbol string) (time.Time, er
 		return time.Time{}, fmt.Errorf("error determining if meta data exists in sync data: %w", err)
 	}
 
+	provider := sc.MarketDataProvider.Name()
 	if md == nil {
 		log.Printf("adding new symbol to db: %s", symbol)
 		md = &m.TimeSeriesMetadata{
 			Symbol:        symbol,
 			LastRefreshed: time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC),
+			Provider:      provider,
+			Frequency:     m.FrequencyWeekly, // providers serve weekly history, see MarketDataProvider.GetHistoricalBars
 		}
 
 		if err := sc.PostgresConnection.InsertNewMetaData(sc.Context, md, nil); err != nil {
@@ -28,24 +51,27 @@ func (sc *ServiceContext) SyncSymbolTimeSeriesData(symbol string) (time.Time, er
 		}
 	}
 
-	cutoffDate := time.Now().AddDate(0, 0, -7)
-	if md.LastRefreshed.After(cutoffDate) {
-		return md.LastRefreshed, fmt.Errorf("data was refreshed less than a week ago (%s), will not sync symbol %s", ex.FmtShort(md.LastRefreshed), symbol)
+	if err := checkProviderSeries(md); err != nil {
+		return md.LastRefreshed, err
 	}
 
-	// TODO: is this able to return a null postgres value to a pointer?
-	mrd, err := sc.PostgresConnection.GetMostRecentTimestampForSymbol(sc.Context, symbol)
+	if !sc.StalenessPolicy.IsStale(md, time.Now()) {
+		return md.LastRefreshed, fmt.Errorf("%w: %s data was refreshed less than %s ago (%s), will not sync symbol %s",
+			ErrDataIsFresh, md.Frequency, sc.StalenessPolicy.MaxAge(md.Frequency), ex.FmtShort(md.LastRefreshed), symbol)
+	}
+
+	existing, err := sc.PostgresConnection.GetTimeSeriesData(sc.Context, symbol)
 	if err != nil {
-		return time.Time{}, fmt.Errorf("error getting most recent time series date for symbol %s: %w", symbol, err)
+		return time.Time{}, err
 	}
 
-	tsr, err := sc.AlphaVantageClient.GetStockWeeklyAdjustedMetrics(symbol)
+	tsr, err := sc.MarketDataProvider.GetHistoricalBars(symbol)
 	if err != nil {
 		return time.Time{}, err
 	}
 
-	f := func(t *m.TimeSeriesData) bool { return mrd == nil || mrd.After(t.Timestamp) }
-	toInsert := ex.FilterMultiplePtr(tsr.TimeSeries, f)
+	// new dates are inserted and restated history (adjusted closes move after splits and dividends) is overwritten
+	diff := diffTimeSeriesData(existing, tsr.TimeSeries)
 
 	tx, err := sc.PostgresConnection.GetTransaction(sc.Context)
 	if err != nil {
@@ -54,21 +80,125 @@ func (sc *ServiceContext) SyncSymbolTimeSeriesData(symbol string) (time.Time, er
 	defer tx.Rollback(sc.Context) // this will kick off if we return before committing
 
 	var ra int64
-	if len(toInsert) > 0 {
-		ra, err = sc.PostgresConnection.InsertTimeSeriesData(sc.Context, toInsert, &md.Id, &tx)
+	if toLoad := append(diff.Inserts, diff.Updates...); len(toLoad) > 0 {
+		ra, err = sc.PostgresConnection.UpsertTimeSeriesData(sc.Context, toLoad, &md.Id, &tx)
 		if err != nil {
-			return time.Time{}, fmt.Errorf("error inserting time series data: %w", err)
+			return time.Time{}, fmt.Errorf("error upserting time series data: %w", err)
 		}
 	}
 
+	if revisions := timeSeriesRevisions(diff, md.Id, provider); len(revisions) > 0 {
+		if _, err := sc.PostgresConnection.InsertTimeSeriesRevisions(sc.Context, revisions, &tx); err != nil {
+			return time.Time{}, fmt.Errorf("error logging time series revisions: %w", err)
+		}
+	}
+
+	if _, err := sc.PostgresConnection.UpsertCorporateActions(sc.Context, extractCorporateActions(tsr.TimeSeries, provider), &md.Id, &tx); err != nil {
+		return time.Time{}, err
+	}
+
 	if err := sc.PostgresConnection.UpdateLastRefreshedDate(sc.Context, symbol, tsr.Metadata.LastRefreshed, &tx); err != nil {
 		return time.Time{}, err
 	}
 
+	// the series now holds bars from this provider, keep the record pointing at the latest source
+	if md.Provider != provider {
+		log.Printf("symbol %s was sourced from %s, now syncing from %s", symbol, md.Provider, provider)
+		if err := sc.PostgresConnection.UpdateProvider(sc.Context, symbol, provider, &tx); err != nil {
+			return time.Time{}, err
+		}
+	}
+
 	if err := tx.Commit(sc.Context); err != nil {
 		return time.Time{}, fmt.Errorf("error committing transaction to add new symbol %s: %w", symbol, err)
 	}
 
-	log.Printf("symbol %s got %v time series elements from av, inserted %v values", symbol, len(tsr.TimeSeries), ra)
+	log.Printf("symbol %s got %v time series elements from %s, inserted %v, revised %v and left %v unchanged (%v rows written)",
+		symbol, len(tsr.TimeSeries), provider, len(diff.Inserts), len(diff.Updates), diff.Unchanged, ra)
 	return tsr.Metadata.LastRefreshed, nil
 }
+
+// timeSeriesDiff splits incoming bars against what is already stored for a symbol
+type timeSeriesDiff struct {
+	Inserts   []*m.TimeSeriesData // dates we do not have yet
+	Updates   []*m.TimeSeriesData // dates we have, with different values
+	Previous  []*m.TimeSeriesData // stored values for Updates, index aligned
+	Unchanged int
+}
+
+func diffTimeSeriesData(existing, incoming []*m.TimeSeriesData) timeSeriesDiff {
+	// keyed on the date string, stored dates come back in utc while providers parse in the exchange time zone
+	stored := make(map[string]*m.TimeSeriesData, len(existing))
+	for _, v := range existing {
+		stored[ex.FmtShort(v.Timestamp)] = v
+	}
+
+	res := timeSeriesDiff{}
+	for _, v := range incoming {
+		prev, ok := stored[ex.FmtShort(v.Timestamp)]
+		switch {
+		case !ok:
+			res.Inserts = append(res.Inserts, v)
+		case !timeSeriesDataEqual(prev, v):
+			res.Updates = append(res.Updates, v)
+			res.Previous = append(res.Previous, prev)
+		default:
+			res.Unchanged++
+		}
+	}
+
+	return res
+}
+
+type timeSeriesField struct {
+	name   string
+	places float64 // postgres precision, NUMERIC(20, 4) and NUMERIC(20, 0) for volume
+	value  func(*m.TimeSeriesData) float64
+}
+
+var timeSeriesFields = []timeSeriesField{
+	{"open", 4, func(v *m.TimeSeriesData) float64 { return v.Open }},
+	{"high", 4, func(v *m.TimeSeriesData) float64 { return v.High }},
+	{"low", 4, func(v *m.TimeSeriesData) float64 { return v.Low }},
+	{"close", 4, func(v *m.TimeSeriesData) float64 { return v.Close }},
+	{"volume", 0, func(v *m.TimeSeriesData) float64 { return v.Volume }},
+	{"adjusted_close", 4, func(v *m.TimeSeriesData) float64 { return v.AdjustedClose }},
+	{"dividend_amount", 4, func(v *m.TimeSeriesData) float64 { return v.DividendAmount }},
+}
+
+func (f timeSeriesField) rounded(v *m.TimeSeriesData) float64 {
+	p := math.Pow(10, f.places)
+	return math.Round(f.value(v)*p) / p
+}
+
+// timeSeriesDataEqual compares at the precision postgres stores
+func timeSeriesDataEqual(a, b *m.TimeSeriesData) bool {
+	for _, f := range timeSeriesFields {
+		if f.rounded(a) != f.rounded(b) {
+			return false
+		}
+	}
+	return true
+}
+
+// timeSeriesRevisions lists every stored field the updates in a diff will overwrite
+func timeSeriesRevisions(diff timeSeriesDiff, sourceId int32, provider string) []*m.TimeSeriesRevision {
+	res := []*m.TimeSeriesRevision{}
+	for i, v := range diff.Updates {
+		prev := diff.Previous[i]
+		for _, f := range timeSeriesFields {
+			if f.rounded(prev) == f.rounded(v) {
+				continue
+			}
+			res = append(res, &m.TimeSeriesRevision{
+				SourceId:      sourceId,
+				Timestamp:     prev.Timestamp,
+				Field:         f.name,
+				PreviousValue: f.rounded(prev),
+				NewValue:      f.rounded(v),
+				Provider:      provider,
+			})
+		}
+	}
+	return res
+}
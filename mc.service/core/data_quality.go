@@ -0,0 +1,331 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"gonum.org/v1/gonum/stat"
+
+	ex "mc.data/extensions"
+	m "mc.data/models"
+)
+
+const (
+	QualitySeverityError   = "error"   // the bar is wrong or the check is blocked on, fails the report
+	QualitySeverityWarning = "warning" // worth a look, does not fail the report
+
+	QualityCheckGap              = "gap"
+	QualityCheckNonPositivePrice = "non_positive_price"
+	QualityCheckOhlcInconsistent = "ohlc_inconsistent"
+	QualityCheckExtremeReturn    = "extreme_return"
+	QualityCheckStaleValues      = "stale_values"
+
+	DefaultQualitySigmaThreshold = 8.0
+)
+
+// ErrDataQuality is returned when a simulation requires clean data and an allocation fails its checks
+var ErrDataQuality = errors.New("data quality checks failed")
+
+// qualityChecks are the checks run, with the severity each reports at unless DataQualityConfig.Block
+// raises it. Gaps, extreme returns and stale values all happen in good data too.
+var qualityChecks = map[string]string{
+	QualityCheckNonPositivePrice: QualitySeverityError,
+	QualityCheckOhlcInconsistent: QualitySeverityError,
+	QualityCheckGap:              QualitySeverityWarning,
+	QualityCheckExtremeReturn:    QualitySeverityWarning,
+	QualityCheckStaleValues:      QualitySeverityWarning,
+}
+
+type DataQualityConfig struct {
+	// returns further than this many robust (MAD based) standard deviations from the median are flagged
+	SigmaThreshold float64 `json:"sigmathreshold"`
+	// this many bars in a row with the same close is flagged, 0 picks 5 for daily and 3 otherwise
+	StaleRunLength int `json:"stalerunlength"`
+	// checks reported as errors, so they fail the report and block simulations, e.g. ["extreme_return"]
+	Block []string `json:"block"`
+}
+
+type DataQualityIssue struct {
+	Check    string `json:"check"`
+	Severity string `json:"severity"`
+	Date     string `json:"date"`
+	Message  string `json:"message"`
+}
+
+type DataQualityReport struct {
+	Symbol    string              `json:"symbol"`
+	Frequency string              `json:"frequency"`
+	Bars      int                 `json:"bars"`
+	FirstDate string              `json:"firstdate"`
+	LastDate  string              `json:"lastdate"`
+	Passed    bool                `json:"passed"`
+	Errors    int                 `json:"errors"`
+	Warnings  int                 `json:"warnings"`
+	Issues    []*DataQualityIssue `json:"issues"`
+}
+
+func (dc DataQualityConfig) Validate() error {
+	for _, check := range dc.Block {
+		if _, ok := qualityChecks[check]; !ok {
+			return fmt.Errorf("unknown data quality check %q to block on, expected one of %s",
+				check, strings.Join(slices.Sorted(maps.Keys(qualityChecks)), ", "))
+		}
+	}
+	return nil
+}
+
+// CheckDataQuality scans a stored series for bad bars
+func (sc *ServiceContext) CheckDataQuality(symbol string, config DataQualityConfig) (*DataQualityReport, error) {
+	md, err := sc.PostgresConnection.GetMetaDataBySymbol(sc.Context, symbol)
+	if err != nil {
+		return nil, fmt.Errorf("error getting meta data for %s: %w", symbol, err)
+	}
+	if md == nil {
+		return nil, fmt.Errorf("symbol %s is not stored", symbol)
+	}
+
+	return sc.checkDataQuality(md, config)
+}
+
+// CheckAllDataQuality reports on every stored series
+func (sc *ServiceContext) CheckAllDataQuality(config DataQualityConfig) ([]*DataQualityReport, error) {
+	mds, err := sc.PostgresConnection.GetAllMetaData(sc.Context)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*DataQualityReport, 0, len(mds))
+	for _, md := range mds {
+		report, err := sc.checkDataQuality(md, config)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, report)
+	}
+	return res, nil
+}
+
+// verifyAllocationQuality is the simulation gate, every allocation has to pass its checks
+func (sc *ServiceContext) verifyAllocationQuality(allocations []SimulationAllocation, config DataQualityConfig) error {
+	failing := []string{}
+	for _, a := range allocations {
+		md, err := sc.PostgresConnection.GetMetaDataById(sc.Context, a.Id)
+		if err != nil {
+			return err
+		}
+		if md == nil {
+			return fmt.Errorf("no stored series for allocation %d (%s)", a.Id, a.Ticker)
+		}
+
+		report, err := sc.checkDataQuality(md, config)
+		if err != nil {
+			return err
+		}
+		if !report.Passed {
+			failing = append(failing, fmt.Sprintf("%s (%d errors)", md.Symbol, report.Errors))
+		}
+	}
+
+	if len(failing) > 0 {
+		return fmt.Errorf("%w: %s, see /api/dataQuality", ErrDataQuality, strings.Join(failing, ", "))
+	}
+	return nil
+}
+
+func (sc *ServiceContext) checkDataQuality(md *m.TimeSeriesMetadata, config DataQualityConfig) (*DataQualityReport, error) {
+	bars, err := sc.PostgresConnection.GetTimeSeriesData(sc.Context, md.Symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	res := evaluateDataQuality(bars, md.Frequency, config)
+	res.Symbol = md.Symbol
+	return res, nil
+}
+
+func evaluateDataQuality(bars []*m.TimeSeriesData, frequency string, config DataQualityConfig) *DataQualityReport {
+	if config.SigmaThreshold <= 0 {
+		config.SigmaThreshold = DefaultQualitySigmaThreshold
+	}
+	if config.StaleRunLength <= 0 {
+		config.StaleRunLength = 3
+		if frequency == m.FrequencyDaily {
+			config.StaleRunLength = 5
+		}
+	}
+
+	sorted := sortedAscending(bars)
+	res := &DataQualityReport{
+		Frequency: frequency,
+		Bars:      len(sorted),
+		Issues:    []*DataQualityIssue{},
+	}
+
+	if len(sorted) > 0 {
+		res.FirstDate = ex.FmtShort(sorted[0].Timestamp)
+		res.LastDate = ex.FmtShort(sorted[len(sorted)-1].Timestamp)
+	}
+
+	issues := slices.Concat(
+		checkBarPrices(sorted),
+		checkGaps(sorted, frequency),
+		checkExtremeReturns(sorted, config.SigmaThreshold),
+		checkStaleValues(sorted, config.StaleRunLength),
+	)
+	slices.SortStableFunc(issues, func(a, b *DataQualityIssue) int { return strings.Compare(a.Date, b.Date) })
+
+	for _, issue := range issues {
+		if slices.Contains(config.Block, issue.Check) {
+			issue.Severity = QualitySeverityError
+		}
+		if issue.Severity == QualitySeverityError {
+			res.Errors++
+		} else {
+			res.Warnings++
+		}
+	}
+
+	res.Issues = append(res.Issues, issues...)
+	res.Passed = res.Errors == 0
+	return res
+}
+
+func checkBarPrices(sorted []*m.TimeSeriesData) []*DataQualityIssue {
+	res := []*DataQualityIssue{}
+	for _, b := range sorted {
+		date := ex.FmtShort(b.Timestamp)
+		if b.Open <= 0 || b.High <= 0 || b.Low <= 0 || b.Close <= 0 || b.AdjustedClose <= 0 {
+			res = append(res, &DataQualityIssue{QualityCheckNonPositivePrice, qualityChecks[QualityCheckNonPositivePrice], date,
+				fmt.Sprintf("zero or negative price, open %v high %v low %v close %v adjusted %v", b.Open, b.High, b.Low, b.Close, b.AdjustedClose)})
+			continue
+		}
+
+		switch {
+		case b.Low > b.High:
+			res = append(res, &DataQualityIssue{QualityCheckOhlcInconsistent, qualityChecks[QualityCheckOhlcInconsistent], date,
+				fmt.Sprintf("low %v is above high %v", b.Low, b.High)})
+		case b.Close > b.High || b.Close < b.Low || b.Open > b.High || b.Open < b.Low:
+			res = append(res, &DataQualityIssue{QualityCheckOhlcInconsistent, qualityChecks[QualityCheckOhlcInconsistent], date,
+				fmt.Sprintf("open %v or close %v outside the low %v to high %v range", b.Open, b.Close, b.Low, b.High)})
+		}
+	}
+	return res
+}
+
+// checkGaps flags missing bars. daily series skip single missing weekdays, which are usually holidays,
+// weekly bars can land on any weekday so only spans over 10 days count
+func checkGaps(sorted []*m.TimeSeriesData, frequency string) []*DataQualityIssue {
+	res := []*DataQualityIssue{}
+	for i := 1; i < len(sorted); i++ {
+		prev, cur := sorted[i-1].Timestamp, sorted[i].Timestamp
+
+		var missing int
+		var unit string
+		switch frequency {
+		case m.FrequencyDaily:
+			missing, unit = weekdaysBetween(prev, cur), "trading days"
+			if missing <= 1 {
+				continue
+			}
+		case m.FrequencyMonthly:
+			months := (cur.Year()-prev.Year())*12 + int(cur.Month()-prev.Month())
+			missing, unit = months-1, "months"
+		default:
+			days := cur.Sub(prev).Hours() / 24
+			if days <= 10 {
+				continue
+			}
+			missing, unit = int(math.Round(days/7))-1, "weeks"
+		}
+
+		if missing > 0 {
+			res = append(res, &DataQualityIssue{QualityCheckGap, qualityChecks[QualityCheckGap], ex.FmtShort(cur),
+				fmt.Sprintf("%d %s missing since %s", missing, unit, ex.FmtShort(prev))})
+		}
+	}
+	return res
+}
+
+// weekdaysBetween counts weekdays strictly between two dates
+func weekdaysBetween(a, b time.Time) int {
+	n := 0
+	for d := a.AddDate(0, 0, 1); d.Before(b) && ex.FmtShort(d) != ex.FmtShort(b); d = d.AddDate(0, 0, 1) {
+		if d.Weekday() != time.Saturday && d.Weekday() != time.Sunday {
+			n++
+		}
+	}
+	return n
+}
+
+// checkExtremeReturns uses the median and MAD of adjusted log returns, so the bad bars being looked
+// for do not widen the band they are measured against. a crash week like october 2008 is as far out as
+// a missed split, so they only fail the report when blocked on
+func checkExtremeReturns(sorted []*m.TimeSeriesData, threshold float64) []*DataQualityIssue {
+	returns := []float64{}
+	dates := []time.Time{}
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i-1].AdjustedClose > 0 && sorted[i].AdjustedClose > 0 {
+			returns = append(returns, math.Log(sorted[i].AdjustedClose/sorted[i-1].AdjustedClose))
+			dates = append(dates, sorted[i].Timestamp)
+		}
+	}
+
+	if len(returns) < 10 {
+		return []*DataQualityIssue{}
+	}
+
+	median, sigma := robustLocationScale(returns)
+	if sigma == 0 {
+		return []*DataQualityIssue{}
+	}
+
+	res := []*DataQualityIssue{}
+	for i, r := range returns {
+		if z := (r - median) / sigma; math.Abs(z) > threshold {
+			res = append(res, &DataQualityIssue{QualityCheckExtremeReturn, qualityChecks[QualityCheckExtremeReturn], ex.FmtShort(dates[i]),
+				fmt.Sprintf("log return %.4f is %.1f robust standard deviations from the median", r, z)})
+		}
+	}
+	return res
+}
+
+func robustLocationScale(values []float64) (median, sigma float64) {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	median = stat.Quantile(0.5, stat.Empirical, sorted, nil)
+
+	deviations := make([]float64, len(values))
+	for i, v := range values {
+		deviations[i] = math.Abs(v - median)
+	}
+	slices.Sort(deviations)
+
+	// 1.4826 scales the MAD to a standard deviation for normal data
+	return median, 1.4826 * stat.Quantile(0.5, stat.Empirical, deviations, nil)
+}
+
+// checkStaleValues flags runs of bars with an unchanged close, usually a feed repeating its last value
+func checkStaleValues(sorted []*m.TimeSeriesData, runLength int) []*DataQualityIssue {
+	res := []*DataQualityIssue{}
+	flush := func(start, end int) {
+		if n := end - start; n >= runLength {
+			res = append(res, &DataQualityIssue{QualityCheckStaleValues, qualityChecks[QualityCheckStaleValues], ex.FmtShort(sorted[start].Timestamp),
+				fmt.Sprintf("close of %v repeated for %d bars through %s", sorted[start].Close, n, ex.FmtShort(sorted[end-1].Timestamp))})
+		}
+	}
+
+	start := 0
+	for i := 1; i <= len(sorted); i++ {
+		if i < len(sorted) && math.Round(sorted[i].Close*10_000) == math.Round(sorted[start].Close*10_000) {
+			continue
+		}
+		flush(start, i)
+		start = i
+	}
+	return res
+}
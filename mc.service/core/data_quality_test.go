@@ -0,0 +1,117 @@
+package core
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	e "mc.data/extensions"
+	m "mc.data/models"
+)
+
+// cleanWeeklyBars is a year of well behaved weekly bars ending on a friday
+func cleanWeeklyBars() []*m.TimeSeriesData {
+	rng := rand.New(rand.NewPCG(1, 2))
+	price := 100.0
+	res := []*m.TimeSeriesData{}
+	for i := range 52 {
+		price *= math.Exp(0.001 + 0.02*rng.NormFloat64())
+		res = append(res, &m.TimeSeriesData{
+			Timestamp:       time.Date(2025, time.January, 3, 0, 0, 0, 0, time.UTC).AddDate(0, 0, 7*i),
+			TimeSeriesOHLCV: m.TimeSeriesOHLCV{Open: price, High: price * 1.01, Low: price * 0.99, Close: price, Volume: 1000},
+			AdjustedClose:   price,
+		})
+	}
+	return res
+}
+
+func issuesFor(report *DataQualityReport, check string) []*DataQualityIssue {
+	return e.FilterMultiplePtr(report.Issues, func(i *DataQualityIssue) bool { return i.Check == check })
+}
+
+func Test_DataQuality_CleanSeriesPasses(t *testing.T) {
+	report := evaluateDataQuality(cleanWeeklyBars(), m.FrequencyWeekly, DataQualityConfig{})
+	e.AssertAreEqual(t, "passed", true, report.Passed)
+	e.AssertAreEqual(t, "issues", 0, len(report.Issues))
+	e.AssertAreEqual(t, "first date", "2025-01-03", report.FirstDate)
+}
+
+func Test_DataQuality_FlagsBadBars(t *testing.T) {
+	bars := cleanWeeklyBars()
+
+	bars[5].Low = bars[5].High + 1         // low above high
+	bars[10].Close = bars[10].High * 1.05  // close outside the range
+	bars[20].Open = 0                      // zero price
+	bars[30].AdjustedClose *= 4            // missed split, a jump out and back
+	bars = append(bars[:40], bars[42:]...) // two missing weeks
+	for i := 45; i < 49; i++ {             // a feed stuck on one value
+		bars[i].Close = bars[44].Close
+		bars[i].High, bars[i].Low, bars[i].Open = bars[44].Close, bars[44].Close, bars[44].Close
+	}
+
+	report := evaluateDataQuality(bars, m.FrequencyWeekly, DataQualityConfig{})
+	e.AssertAreEqual(t, "passed", false, report.Passed)
+	e.AssertAreEqual(t, "ohlc issues", 2, len(issuesFor(report, QualityCheckOhlcInconsistent)))
+	e.AssertAreEqual(t, "non positive issues", 1, len(issuesFor(report, QualityCheckNonPositivePrice)))
+	e.AssertAreEqual(t, "extreme return issues", 2, len(issuesFor(report, QualityCheckExtremeReturn)))
+
+	gaps := issuesFor(report, QualityCheckGap)
+	e.AssertAreEqual(t, "gap issues", 1, len(gaps))
+	e.AssertAreEqual(t, "gap message", "2 weeks missing since 2025-10-03", gaps[0].Message)
+
+	stale := issuesFor(report, QualityCheckStaleValues)
+	e.AssertAreEqual(t, "stale issues", 1, len(stale))
+	e.AssertAreEqual(t, "stale severity", QualitySeverityWarning, stale[0].Severity)
+}
+
+func Test_DataQuality_DailyGapsSkipHolidays(t *testing.T) {
+	day := func(d int) *m.TimeSeriesData {
+		return &m.TimeSeriesData{Timestamp: time.Date(2025, time.December, d, 0, 0, 0, 0, time.UTC)}
+	}
+
+	// single missing weekdays (the 25th and 30th) are treated as holidays
+	issues := checkGaps([]*m.TimeSeriesData{day(22), day(23), day(24), day(26), day(29), day(31)}, m.FrequencyDaily)
+	e.AssertAreEqual(t, "gap count", 0, len(issues))
+
+	// three weekdays missing between the 22nd and 26th
+	issues = checkGaps([]*m.TimeSeriesData{day(22), day(26), day(29)}, m.FrequencyDaily)
+	e.AssertAreEqual(t, "gap count", 1, len(issues))
+	e.AssertAreEqual(t, "gap date", "2025-12-26", issues[0].Date)
+}
+
+func Test_DataQuality_CrashWeekPasses(t *testing.T) {
+	bars := cleanWeeklyBars()
+
+	// the week of october 10 2008, spy fell 18%
+	for _, b := range bars[30:] {
+		b.Open, b.High, b.Low, b.Close, b.AdjustedClose = b.Open*0.82, b.High*0.82, b.Low*0.82, b.Close*0.82, b.AdjustedClose*0.82
+	}
+
+	report := evaluateDataQuality(bars, m.FrequencyWeekly, DataQualityConfig{})
+	extreme := issuesFor(report, QualityCheckExtremeReturn)
+	e.AssertAreEqual(t, "extreme return issues", 1, len(extreme))
+	e.AssertAreEqual(t, "extreme return severity", QualitySeverityWarning, extreme[0].Severity)
+	e.AssertAreEqual(t, "passed", true, report.Passed)
+}
+
+func Test_DataQuality_BlocksOnChosenChecks(t *testing.T) {
+	bars := cleanWeeklyBars()
+	bars[30].AdjustedClose *= 4 // missed split
+
+	report := evaluateDataQuality(bars, m.FrequencyWeekly, DataQualityConfig{})
+	e.AssertAreEqual(t, "passes by default", true, report.Passed)
+
+	config := DataQualityConfig{Block: []string{QualityCheckExtremeReturn}}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	report = evaluateDataQuality(bars, m.FrequencyWeekly, config)
+	e.AssertAreEqual(t, "blocked", false, report.Passed)
+	e.AssertAreEqual(t, "errors", 2, report.Errors)
+	e.AssertAreEqual(t, "severity", QualitySeverityError, issuesFor(report, QualityCheckExtremeReturn)[0].Severity)
+
+	if err := (DataQualityConfig{Block: []string{"spikes"}}).Validate(); err == nil {
+		t.Fatalf("expected an unknown check to fail validation")
+	}
+}
@@ -2,22 +2,31 @@ package core
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	ex "mc.data/extensions"
+	m "mc.data/models"
 )
 
 const (
 	DefaultAddr = ":8080"
+
+	maxImportBytes = 64 << 20 // vendor files run a few mb per symbol
+
+	// batch syncs wait on the provider's rate limit, well past the server's default write timeout
+	batchSyncWriteTimeout = 2 * time.Hour
 )
 
 func getHandler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "http://localhost:3000")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization")
 		w.Header().Set("Access-Control-Expose-Headers", "Content-Length")
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
@@ -54,6 +63,69 @@ func GetHttpServer(sc ServiceContext) *http.Server {
 	mux.HandleFunc("/api/syncStockData", func(w http.ResponseWriter, r *http.Request) {
 		syncStockData(w, r, sc)
 	})
+	mux.HandleFunc("/api/syncStockDataBatch", func(w http.ResponseWriter, r *http.Request) {
+		syncStockDataBatch(w, r, sc)
+	})
+	mux.HandleFunc("/api/refresh", func(w http.ResponseWriter, r *http.Request) {
+		refresh(w, r, sc)
+	})
+	mux.HandleFunc("/api/refreshRuns", func(w http.ResponseWriter, r *http.Request) {
+		refreshRuns(w, r, sc)
+	})
+	mux.HandleFunc("/api/watchlists", func(w http.ResponseWriter, r *http.Request) {
+		watchlists(w, r, sc)
+	})
+	mux.HandleFunc("/api/watchlists/{name}", func(w http.ResponseWriter, r *http.Request) {
+		watchlist(w, r, sc)
+	})
+	mux.HandleFunc("/api/searchSymbols", func(w http.ResponseWriter, r *http.Request) {
+		searchSymbols(w, r, sc)
+	})
+	mux.HandleFunc("/api/symbolMetadata", func(w http.ResponseWriter, r *http.Request) {
+		symbolMetadata(w, r, sc)
+	})
+	mux.HandleFunc("/api/revisions", func(w http.ResponseWriter, r *http.Request) {
+		symbolRevisions(w, r, sc)
+	})
+	mux.HandleFunc("/api/corporateActions", func(w http.ResponseWriter, r *http.Request) {
+		corporateActions(w, r, sc)
+	})
+	mux.HandleFunc("/api/adjustedCloseCheck", func(w http.ResponseWriter, r *http.Request) {
+		adjustedCloseCheck(w, r, sc)
+	})
+	mux.HandleFunc("/api/dataQuality", func(w http.ResponseWriter, r *http.Request) {
+		dataQuality(w, r, sc)
+	})
+	mux.HandleFunc("/api/garch", func(w http.ResponseWriter, r *http.Request) {
+		garch(w, r, sc)
+	})
+	mux.HandleFunc("/api/stressTest", func(w http.ResponseWriter, r *http.Request) {
+		stressTest(w, r, sc)
+	})
+	mux.HandleFunc("/api/stressScenarios", func(w http.ResponseWriter, r *http.Request) {
+		stressScenarios(w, r, sc)
+	})
+	mux.HandleFunc("/api/stressScenarios/{name}", func(w http.ResponseWriter, r *http.Request) {
+		stressScenario(w, r, sc)
+	})
+	mux.HandleFunc("/api/backtest", func(w http.ResponseWriter, r *http.Request) {
+		backtest(w, r, sc)
+	})
+	mux.HandleFunc("/api/optimize", func(w http.ResponseWriter, r *http.Request) {
+		optimizePortfolio(w, r, sc)
+	})
+	mux.HandleFunc("/api/riskAnalysis", func(w http.ResponseWriter, r *http.Request) {
+		riskAnalysis(w, r, sc)
+	})
+	mux.HandleFunc("/api/capitalMarketAssumptions", func(w http.ResponseWriter, r *http.Request) {
+		capitalMarketAssumptions(w, r, sc)
+	})
+	mux.HandleFunc("/api/capitalMarketAssumptions/{name}", func(w http.ResponseWriter, r *http.Request) {
+		capitalMarketAssumption(w, r, sc)
+	})
+	mux.HandleFunc("/api/import", func(w http.ResponseWriter, r *http.Request) {
+		importCsv(w, r, sc)
+	})
 
 	// basic testing routes, will remove eventually
 	mux.HandleFunc("/api/test/addByGet", addByGet)
@@ -136,6 +208,690 @@ func syncStockData(w http.ResponseWriter, r *http.Request, sc ServiceContext) {
 	jsonResponse(w, http.StatusOK, map[string]string{"date": ex.FmtShort(md.LastRefreshed)})
 }
 
+type SyncStockDataBatchRequest struct {
+	Symbols   []string `json:"symbols"`
+	Watchlist string   `json:"watchlist"` // synced instead of symbols when set
+	Workers   int      `json:"workers"`
+}
+
+func syncStockDataBatch(w http.ResponseWriter, r *http.Request, sc ServiceContext) {
+	if r.Method != http.MethodPost {
+		jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req SyncStockDataBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if req.Watchlist == "" && len(req.Symbols) == 0 {
+		jsonError(w, http.StatusBadRequest, "symbols or watchlist is required")
+		return
+	}
+
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(batchSyncWriteTimeout)); err != nil {
+		log.Printf("unable to extend write deadline for batch sync: %v", err)
+	}
+
+	var report *BatchSyncReport
+	var err error
+	if req.Watchlist != "" {
+		report, err = sc.SyncWatchlist(r.Context(), req.Watchlist, req.Workers)
+	} else {
+		report, err = sc.SyncSymbols(r.Context(), req.Symbols, req.Workers)
+	}
+
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, report)
+}
+
+type RefreshRequest struct {
+	Workers int `json:"workers"`
+}
+
+// refresh runs the same stale symbol refresh as the scheduler, on demand
+func refresh(w http.ResponseWriter, r *http.Request, sc ServiceContext) {
+	if r.Method != http.MethodPost {
+		jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req RefreshRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(batchSyncWriteTimeout)); err != nil {
+		log.Printf("unable to extend write deadline for refresh: %v", err)
+	}
+
+	run, err := sc.RefreshStaleSymbols(m.RefreshTriggerManual, req.Workers)
+	if err != nil {
+		jsonError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	status := http.StatusOK
+	if run.Status == m.RefreshStatusSkipped {
+		status = http.StatusConflict
+	}
+	jsonResponse(w, status, run)
+}
+
+func refreshRuns(w http.ResponseWriter, r *http.Request, sc ServiceContext) {
+	if r.Method != http.MethodGet {
+		jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	limit := 20
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 1000 {
+			jsonError(w, http.StatusBadRequest, "limit must be between 1 and 1000")
+			return
+		}
+		limit = n
+	}
+
+	runs, err := sc.PostgresConnection.GetRefreshRuns(sc.Context, limit)
+	if err != nil {
+		jsonError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, runs)
+}
+
+type WatchlistRequest struct {
+	Name    string   `json:"name"` // only read on POST /api/watchlists, the path names it otherwise
+	Symbols []string `json:"symbols"`
+}
+
+// watchlists lists every watchlist on GET and creates or replaces one on POST
+func watchlists(w http.ResponseWriter, r *http.Request, sc ServiceContext) {
+	switch r.Method {
+	case http.MethodGet:
+		res, err := sc.PostgresConnection.GetWatchlists(sc.Context)
+		if err != nil {
+			jsonError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		jsonResponse(w, http.StatusOK, res)
+	case http.MethodPost:
+		var req WatchlistRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		saveWatchlist(w, sc, req.Name, req.Symbols)
+	default:
+		jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+func watchlist(w http.ResponseWriter, r *http.Request, sc ServiceContext) {
+	name := r.PathValue("name")
+
+	switch r.Method {
+	case http.MethodGet:
+		wl, err := sc.PostgresConnection.GetWatchlist(sc.Context, name)
+		if err != nil {
+			jsonError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if wl == nil {
+			jsonError(w, http.StatusNotFound, fmt.Sprintf("watchlist %s does not exist", name))
+			return
+		}
+		jsonResponse(w, http.StatusOK, wl)
+	case http.MethodPut:
+		var req WatchlistRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		saveWatchlist(w, sc, name, req.Symbols)
+	case http.MethodDelete:
+		deleted, err := sc.PostgresConnection.DeleteWatchlist(sc.Context, name)
+		if err != nil {
+			jsonError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if !deleted {
+			jsonError(w, http.StatusNotFound, fmt.Sprintf("watchlist %s does not exist", name))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+func saveWatchlist(w http.ResponseWriter, sc ServiceContext, name string, symbols []string) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 100 {
+		jsonError(w, http.StatusBadRequest, "name is required and can be at most 100 characters")
+		return
+	}
+
+	symbols = NormalizeSymbols(symbols)
+	if len(symbols) > MaxBatchSymbols {
+		jsonError(w, http.StatusBadRequest, fmt.Sprintf("a watchlist can hold at most %d symbols", MaxBatchSymbols))
+		return
+	}
+
+	if err := sc.PostgresConnection.SaveWatchlist(sc.Context, name, symbols, nil); err != nil {
+		jsonError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	wl, err := sc.PostgresConnection.GetWatchlist(sc.Context, name)
+	if err != nil {
+		jsonError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, wl)
+}
+
+func searchSymbols(w http.ResponseWriter, r *http.Request, sc ServiceContext) {
+	if r.Method != http.MethodGet {
+		jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	keywords := r.URL.Query().Get("keywords")
+	if keywords == "" {
+		jsonError(w, http.StatusBadRequest, "keywords is required")
+		return
+	}
+
+	matches, err := sc.MarketDataProvider.SearchSymbols(keywords)
+	if err != nil {
+		jsonError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]any{
+		"provider": sc.MarketDataProvider.Name(),
+		"matches":  matches,
+	})
+}
+
+func symbolMetadata(w http.ResponseWriter, r *http.Request, sc ServiceContext) {
+	if r.Method != http.MethodGet {
+		jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	symbol := r.URL.Query().Get("symbol")
+	if symbol == "" {
+		jsonError(w, http.StatusBadRequest, "symbol is required")
+		return
+	}
+
+	md, err := sc.MarketDataProvider.GetSymbolMetadata(symbol)
+	if err != nil {
+		jsonError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, md)
+}
+
+func symbolRevisions(w http.ResponseWriter, r *http.Request, sc ServiceContext) {
+	if r.Method != http.MethodGet {
+		jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	symbol := r.URL.Query().Get("symbol")
+	if symbol == "" {
+		jsonError(w, http.StatusBadRequest, "symbol is required")
+		return
+	}
+
+	revisions, err := sc.PostgresConnection.GetTimeSeriesRevisions(sc.Context, symbol)
+	if err != nil {
+		jsonError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]any{
+		"symbol":    symbol,
+		"revisions": revisions,
+	})
+}
+
+func corporateActions(w http.ResponseWriter, r *http.Request, sc ServiceContext) {
+	if r.Method != http.MethodGet {
+		jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	symbol := r.URL.Query().Get("symbol")
+	if symbol == "" {
+		jsonError(w, http.StatusBadRequest, "symbol is required")
+		return
+	}
+
+	actions, err := sc.PostgresConnection.GetCorporateActions(sc.Context, symbol)
+	if err != nil {
+		jsonError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]any{
+		"symbol":  symbol,
+		"actions": actions,
+	})
+}
+
+// adjustedCloseCheck compares the vendor adjusted closes to ones rebuilt from corporate actions,
+// tolerance is an optional relative difference and defaults to half a percent
+func adjustedCloseCheck(w http.ResponseWriter, r *http.Request, sc ServiceContext) {
+	if r.Method != http.MethodGet {
+		jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	symbol := r.URL.Query().Get("symbol")
+	if symbol == "" {
+		jsonError(w, http.StatusBadRequest, "symbol is required")
+		return
+	}
+
+	tolerance := DefaultAdjustedCloseTolerance
+	if v := r.URL.Query().Get("tolerance"); v != "" {
+		t, err := strconv.ParseFloat(v, 64)
+		if err != nil || t <= 0 {
+			jsonError(w, http.StatusBadRequest, "tolerance must be a positive number")
+			return
+		}
+		tolerance = t
+	}
+
+	res, err := sc.CheckAdjustedCloses(symbol, tolerance)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, res)
+}
+
+// dataQuality reports on one symbol, or every stored symbol when none is given.
+// sigma and staleRun optionally override the extreme return and stale value thresholds,
+// block is a comma separated list of checks to report as errors
+func dataQuality(w http.ResponseWriter, r *http.Request, sc ServiceContext) {
+	if r.Method != http.MethodGet {
+		jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	config := DataQualityConfig{}
+	if v := r.URL.Query().Get("sigma"); v != "" {
+		sigma, err := strconv.ParseFloat(v, 64)
+		if err != nil || sigma <= 0 {
+			jsonError(w, http.StatusBadRequest, "sigma must be a positive number")
+			return
+		}
+		config.SigmaThreshold = sigma
+	}
+	if v := r.URL.Query().Get("staleRun"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 2 {
+			jsonError(w, http.StatusBadRequest, "staleRun must be an integer of at least 2")
+			return
+		}
+		config.StaleRunLength = n
+	}
+	if v := r.URL.Query().Get("block"); v != "" {
+		for check := range strings.SplitSeq(v, ",") {
+			config.Block = append(config.Block, strings.TrimSpace(check))
+		}
+		if err := config.Validate(); err != nil {
+			jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	symbol := r.URL.Query().Get("symbol")
+	if symbol == "" {
+		reports, err := sc.CheckAllDataQuality(config)
+		if err != nil {
+			jsonError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		jsonResponse(w, http.StatusOK, reports)
+		return
+	}
+
+	report, err := sc.CheckDataQuality(symbol, config)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, report)
+}
+
+// garch fits a GARCH(1,1) to a stored symbol's returns, lookback is an optional number of days
+// and defaults to ten years
+func garch(w http.ResponseWriter, r *http.Request, sc ServiceContext) {
+	if r.Method != http.MethodGet {
+		jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	symbol := r.URL.Query().Get("symbol")
+	if symbol == "" {
+		jsonError(w, http.StatusBadRequest, "symbol is required")
+		return
+	}
+
+	lookback := DefaultGarchLookback
+	if v := r.URL.Query().Get("lookback"); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil || days <= 0 {
+			jsonError(w, http.StatusBadRequest, "lookback must be a positive number of days")
+			return
+		}
+		lookback = time.Duration(days) * 24 * time.Hour
+	}
+
+	fit, err := sc.FitSymbolGarch(symbol, lookback)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, fit)
+}
+
+// stressTest replays stored or custom scenarios against an allocation
+func stressTest(w http.ResponseWriter, r *http.Request, sc ServiceContext) {
+	if r.Method != http.MethodPost {
+		jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req StressTestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res, err := sc.RunStressTests(req)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, res)
+}
+
+// backtest replays an allocation over the stored prices with the requested rebalancing and costs
+func backtest(w http.ResponseWriter, r *http.Request, sc ServiceContext) {
+	if r.Method != http.MethodPost {
+		jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req BacktestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res, err := sc.RunBacktest(req)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, res)
+}
+
+// optimizePortfolio traces the efficient frontier of an allocation's assets under the requested constraints
+func optimizePortfolio(w http.ResponseWriter, r *http.Request, sc ServiceContext) {
+	if r.Method != http.MethodPost {
+		jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req OptimizationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res, err := sc.OptimizePortfolio(req)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, res)
+}
+
+// riskAnalysis breaks an allocation's volatility down by asset and returns its risk parity weights
+func riskAnalysis(w http.ResponseWriter, r *http.Request, sc ServiceContext) {
+	if r.Method != http.MethodPost {
+		jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req RiskAnalysisRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res, err := sc.AnalyzeRisk(req)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, res)
+}
+
+// stressScenarios lists the scenario library on GET and creates or replaces a scenario on POST
+func stressScenarios(w http.ResponseWriter, r *http.Request, sc ServiceContext) {
+	switch r.Method {
+	case http.MethodGet:
+		res, err := sc.PostgresConnection.GetStressScenarios(sc.Context)
+		if err != nil {
+			jsonError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		jsonResponse(w, http.StatusOK, res)
+	case http.MethodPost:
+		var req m.StressScenario
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := ValidateStressScenario(&req); err != nil {
+			jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := sc.PostgresConnection.SaveStressScenario(sc.Context, &req); err != nil {
+			jsonError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		jsonResponse(w, http.StatusOK, req)
+	default:
+		jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+func stressScenario(w http.ResponseWriter, r *http.Request, sc ServiceContext) {
+	name := r.PathValue("name")
+
+	switch r.Method {
+	case http.MethodGet:
+		s, err := sc.PostgresConnection.GetStressScenario(sc.Context, name)
+		if err != nil {
+			jsonError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if s == nil {
+			jsonError(w, http.StatusNotFound, fmt.Sprintf("stress scenario %s does not exist", name))
+			return
+		}
+		jsonResponse(w, http.StatusOK, s)
+	case http.MethodDelete:
+		deleted, err := sc.PostgresConnection.DeleteStressScenario(sc.Context, name)
+		if err != nil {
+			jsonError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if !deleted {
+			jsonError(w, http.StatusNotFound, fmt.Sprintf("stress scenario %s does not exist", name))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// capitalMarketAssumptions lists the stored assumption sets on GET and creates or replaces a set on POST
+func capitalMarketAssumptions(w http.ResponseWriter, r *http.Request, sc ServiceContext) {
+	switch r.Method {
+	case http.MethodGet:
+		res, err := sc.PostgresConnection.GetCapitalMarketAssumptions(sc.Context)
+		if err != nil {
+			jsonError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		jsonResponse(w, http.StatusOK, res)
+	case http.MethodPost:
+		var req m.CapitalMarketAssumptions
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := ValidateCapitalMarketAssumptions(&req); err != nil {
+			jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := sc.PostgresConnection.SaveCapitalMarketAssumption(sc.Context, &req); err != nil {
+			jsonError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		jsonResponse(w, http.StatusOK, req)
+	default:
+		jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+func capitalMarketAssumption(w http.ResponseWriter, r *http.Request, sc ServiceContext) {
+	name := r.PathValue("name")
+
+	switch r.Method {
+	case http.MethodGet:
+		cma, err := sc.PostgresConnection.GetCapitalMarketAssumption(sc.Context, name)
+		if err != nil {
+			jsonError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if cma == nil {
+			jsonError(w, http.StatusNotFound, fmt.Sprintf("capital market assumptions %s do not exist", name))
+			return
+		}
+		jsonResponse(w, http.StatusOK, cma)
+	case http.MethodDelete:
+		deleted, err := sc.PostgresConnection.DeleteCapitalMarketAssumption(sc.Context, name)
+		if err != nil {
+			jsonError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if !deleted {
+			jsonError(w, http.StatusNotFound, fmt.Sprintf("capital market assumptions %s do not exist", name))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// importCsv takes a multipart upload, the csv in "file" plus optional symbol, dryrun, dateformat,
+// allowfrequencymismatch and columns (json object of csv header -> field) form values
+func importCsv(w http.ResponseWriter, r *http.Request, sc ServiceContext) {
+	if r.Method != http.MethodPost {
+		jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, fmt.Sprintf("a csv upload in the file field is required: %v", err))
+		return
+	}
+	defer file.Close()
+
+	req := CsvImportRequest{
+		Symbol:     r.FormValue("symbol"),
+		DateFormat: r.FormValue("dateformat"),
+	}
+
+	if v := r.FormValue("dryrun"); v != "" {
+		if req.DryRun, err = strconv.ParseBool(v); err != nil {
+			jsonError(w, http.StatusBadRequest, "dryrun must be true or false")
+			return
+		}
+	}
+
+	if v := r.FormValue("allowfrequencymismatch"); v != "" {
+		if req.AllowFrequencyMismatch, err = strconv.ParseBool(v); err != nil {
+			jsonError(w, http.StatusBadRequest, "allowfrequencymismatch must be true or false")
+			return
+		}
+	}
+
+	if v := r.FormValue("columns"); v != "" {
+		if err := json.Unmarshal([]byte(v), &req.Columns); err != nil {
+			jsonError(w, http.StatusBadRequest, fmt.Sprintf("columns must be a json object of csv header to field: %v", err))
+			return
+		}
+	}
+
+	report, err := sc.ImportTimeSeriesCsv(file, req)
+	if err != nil {
+		if report == nil {
+			jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrImportValidation) || errors.Is(err, ErrImportFrequency) {
+			status = http.StatusUnprocessableEntity
+		}
+		jsonResponse(w, status, map[string]any{
+			"error":  err.Error(),
+			"report": report,
+		})
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, report)
+}
+
 // Testing endpoints below to ensure functionality
 type NumbersToSum struct {
 	Number1 int `json:"number1"`
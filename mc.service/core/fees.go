@@ -0,0 +1,225 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"gonum.org/v1/gonum/stat"
+)
+
+// RebalanceEveryPeriod trades back to the target weights after every simulated period, which is how paths
+// without fees behave, see periodGrowth
+const RebalanceEveryPeriod = "period"
+
+// FeeConfig charges the costs of holding the allocation inside every path. Paths with fees track each
+// asset's holding, so the weights drift between rebalances and the trades back to target are costed.
+type FeeConfig struct {
+	ExpenseRatios      map[string]float64 `json:"expenseratios"`      // annual share of each ticker's holding, 0.0003 is 3 bps
+	AdvisoryFeeBps     float64            `json:"advisoryfeebps"`     // annual on the whole portfolio value
+	AdvisoryTiers      []*FeeTier         `json:"advisorytiers"`      // marginal schedule on the portfolio value, instead of AdvisoryFeeBps
+	TransactionCostBps float64            `json:"transactioncostbps"` // charged on the traded notional of every rebalance
+	Rebalance          string             `json:"rebalance"`          // period (default), none, monthly, quarterly, yearly or threshold
+	Threshold          float64            `json:"threshold"`          // absolute weight drift for threshold rebalancing, zero uses DefaultRebalanceDrift
+}
+
+// FeeTier charges Bps on the part of the portfolio value between the previous tier's UpTo and its own,
+// the last tier is uncapped
+type FeeTier struct {
+	UpTo float64 `json:"upto"`
+	Bps  float64 `json:"bps"`
+}
+
+// FeesPaid is what one path paid, in the units of the initial value
+type FeesPaid struct {
+	Expenses     float64 `json:"expenses"`
+	Advisory     float64 `json:"advisory"`
+	Transactions float64 `json:"transactions"`
+	Total        float64 `json:"total"`
+	Rebalances   int     `json:"rebalances"`
+	Drag         float64 `json:"drag"` // annualized return lost, against the same draws without fees or taxes
+}
+
+// FeeSummary averages the fees paid across the paths
+type FeeSummary struct {
+	Expenses        float64 `json:"expenses"`
+	Advisory        float64 `json:"advisory"`
+	Transactions    float64 `json:"transactions"`
+	Total           float64 `json:"total"`
+	Rebalances      float64 `json:"rebalances"`
+	Drag            float64 `json:"drag"`            // median
+	GrossFinalValue float64 `json:"grossfinalvalue"` // median final value had nothing been charged
+	FinalValue      float64 `json:"finalvalue"`      // median final value after fees and taxes
+}
+
+// FeeModel is the resolved config in the order of the assets
+type FeeModel struct {
+	periodExpenses    []float64  // share of each holding charged per simulated period
+	tiers             []*FeeTier // annual advisory schedule
+	periods           float64    // simulated periods per year
+	transactionCost   float64    // share of the traded notional
+	rebalance         string
+	rebalancesPerYear int // calendar rebalances a year, spread over the periods by elapsed time
+	threshold         float64
+}
+
+func normalizeSimulationRebalance(rule string) (string, error) {
+	switch v := strings.ToLower(strings.TrimSpace(rule)); v {
+	case "":
+		return RebalanceEveryPeriod, nil
+	case RebalanceEveryPeriod, RebalanceNone, RebalanceMonthly, RebalanceQuarterly, RebalanceYearly, RebalanceThreshold:
+		return v, nil
+	default:
+		return "", fmt.Errorf("unknown rebalance rule %q", rule)
+	}
+}
+
+func (fc *FeeConfig) Validate() error {
+	for ticker, r := range fc.ExpenseRatios {
+		if r < 0 || r >= 1 {
+			return fmt.Errorf("expense ratio for %s must be in [0, 1), got %v", ticker, r)
+		}
+	}
+	if fc.AdvisoryFeeBps < 0 || fc.TransactionCostBps < 0 || fc.Threshold < 0 {
+		return fmt.Errorf("advisory fee, transaction costs and threshold cannot be negative")
+	}
+	if fc.AdvisoryFeeBps > 0 && len(fc.AdvisoryTiers) > 0 {
+		return fmt.Errorf("give either a flat advisory fee or advisory tiers, not both")
+	}
+
+	floor := 0.0
+	for i, t := range fc.AdvisoryTiers {
+		if t.Bps < 0 {
+			return fmt.Errorf("advisory tier %d cannot charge a negative fee", i+1)
+		}
+		if i == len(fc.AdvisoryTiers)-1 {
+			break
+		}
+		if t.UpTo <= floor {
+			return fmt.Errorf("advisory tier %d must end above %v", i+1, floor)
+		}
+		floor = t.UpTo
+	}
+
+	_, err := normalizeSimulationRebalance(fc.Rebalance)
+	return err
+}
+
+// GetFeeModel lines the config up with the assets for the simulation's period length
+func GetFeeModel(config *FeeConfig, assets []*SeriesReturns, simulationUnitOfTime int) (*FeeModel, error) {
+	rebalance, err := normalizeSimulationRebalance(config.Rebalance)
+	if err != nil {
+		return nil, err
+	}
+
+	u := float64(simulationUnitOfTime)
+	res := &FeeModel{
+		periodExpenses:  make([]float64, len(assets)),
+		tiers:           config.AdvisoryTiers,
+		periods:         u,
+		transactionCost: config.TransactionCostBps / basisPointsPerPercentage,
+		rebalance:       rebalance,
+		threshold:       config.Threshold,
+	}
+	if config.AdvisoryFeeBps > 0 {
+		res.tiers = []*FeeTier{{Bps: config.AdvisoryFeeBps}}
+	}
+	if res.threshold == 0 {
+		res.threshold = DefaultRebalanceDrift
+	}
+
+	switch rebalance {
+	case RebalanceMonthly:
+		res.rebalancesPerYear = Monthly
+	case RebalanceQuarterly:
+		res.rebalancesPerYear = Quarterly
+	case RebalanceYearly:
+		res.rebalancesPerYear = Yearly
+	}
+
+	for ticker, r := range config.ExpenseRatios {
+		found := false
+		for i, a := range assets {
+			if strings.EqualFold(a.Ticker, strings.TrimSpace(ticker)) {
+				res.periodExpenses[i] = r / u
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("expense ratio for %s which is not allocated", ticker)
+		}
+	}
+	return res, nil
+}
+
+// advisoryFee is the annual fee on a portfolio worth value
+func (fm *FeeModel) advisoryFee(value float64) float64 {
+	res, floor := 0.0, 0.0
+	for i, t := range fm.tiers {
+		if value <= floor {
+			break
+		}
+		top := value
+		if i < len(fm.tiers)-1 {
+			top = math.Min(value, t.UpTo)
+		}
+		res += (top - floor) * t.Bps / basisPointsPerPercentage
+		floor = t.UpTo
+	}
+	return res
+}
+
+// rebalanceDue reports whether the holdings trade back to target at the end of the zero based period.
+// Calendar rules trade when the period crosses into a new month, quarter or year, so weekly steps
+// rebalance monthly 12 times a year rather than every 4 weeks
+func (fm *FeeModel) rebalanceDue(period int, holdings, weights []float64) bool {
+	switch fm.rebalance {
+	case RebalanceEveryPeriod:
+		return true
+	case RebalanceMonthly, RebalanceQuarterly, RebalanceYearly:
+		k, u := fm.rebalancesPerYear, int(fm.periods)
+		return (period+1)*k/u != period*k/u
+	case RebalanceThreshold:
+		total := sumHoldings(holdings)
+		if total <= 0 {
+			return false
+		}
+		for i, w := range weights {
+			if math.Abs(holdings[i]/total-w) > fm.threshold {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GetFeeSummary averages the fees of the paths, nil when fees were not simulated
+func GetFeeSummary(paths []*SimulationResult) *FeeSummary {
+	if len(paths) == 0 || paths[0] == nil || paths[0].Fees == nil {
+		return nil
+	}
+
+	res := &FeeSummary{}
+	n := float64(len(paths))
+	drag := make([]float64, len(paths))
+	gross := make([]float64, len(paths))
+	final := make([]float64, len(paths))
+	for i, p := range paths {
+		res.Expenses += p.Fees.Expenses / n
+		res.Advisory += p.Fees.Advisory / n
+		res.Transactions += p.Fees.Transactions / n
+		res.Total += p.Fees.Total / n
+		res.Rebalances += float64(p.Fees.Rebalances) / n
+		drag[i], gross[i], final[i] = p.Fees.Drag, p.GrossFinalValue, p.FinalValue
+	}
+
+	median := func(values []float64) float64 {
+		slices.Sort(values)
+		return stat.Quantile(0.5, stat.Empirical, values, nil)
+	}
+	res.Drag = median(drag)
+	res.GrossFinalValue = median(gross)
+	res.FinalValue = median(final)
+	return res
+}
@@ -0,0 +1,157 @@
+package core
+
+import (
+	"math"
+	"testing"
+
+	e "mc.data/extensions"
+)
+
+func Test_Fees_ExpenseRatiosCompoundIntoDrag(t *testing.T) {
+	request := SimulationRequest{
+		SimulationUnitOfTime: Yearly,
+		SimulationDuration:   10,
+		InitialValue:         1000,
+		Fees:                 &FeeConfig{ExpenseRatios: map[string]float64{"spy": 0.01}},
+	}
+	if err := request.Fees.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sr := flatResources(t)
+	var err error
+	sr.Fees, err = GetFeeModel(request.Fees, optimizationAssets("SPY"), request.SimulationUnitOfTime)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	res, err := NewWorkerResources(sr, 42, 0).simulatePath(request)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := 1000 * math.Pow(0.99, 10)
+	e.AssertAreEqual(t, "final value", true, math.Abs(res.FinalValue-want) < 1e-9)
+	e.AssertAreEqual(t, "gross final value", true, math.Abs(res.GrossFinalValue-1000) < 1e-9)
+	e.AssertAreEqual(t, "expenses", true, math.Abs(res.Fees.Expenses-(1000-want)) < 1e-9)
+	e.AssertAreEqual(t, "total", res.Fees.Expenses, res.Fees.Total)
+	e.AssertAreEqual(t, "drag", true, math.Abs(res.Fees.Drag-0.01) < 1e-12)
+	e.AssertAreEqual(t, "rebalanced every period", 10, res.Fees.Rebalances)
+
+	summary := GetFeeSummary([]*SimulationResult{res, res})
+	e.AssertAreEqual(t, "mean expenses", true, math.Abs(summary.Expenses-res.Fees.Expenses) < 1e-9)
+	e.AssertAreEqual(t, "median final value", res.FinalValue, summary.FinalValue)
+	e.AssertAreEqual(t, "no summary without fees", true, GetFeeSummary([]*SimulationResult{{}}) == nil)
+}
+
+func Test_Fees_TieredAdvisoryFee(t *testing.T) {
+	config := &FeeConfig{AdvisoryTiers: []*FeeTier{{UpTo: 1_000_000, Bps: 100}, {UpTo: 5_000_000, Bps: 50}, {Bps: 25}}}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	model, err := GetFeeModel(config, nil, Monthly)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	e.AssertAreEqual(t, "first tier", true, math.Abs(model.advisoryFee(500_000)-5_000) < 1e-9)
+	e.AssertAreEqual(t, "second tier", true, math.Abs(model.advisoryFee(2_000_000)-15_000) < 1e-9)
+	e.AssertAreEqual(t, "uncapped last tier", true, math.Abs(model.advisoryFee(10_000_000)-42_500) < 1e-9)
+
+	flat, _ := GetFeeModel(&FeeConfig{AdvisoryFeeBps: 100}, nil, Monthly)
+	e.AssertAreEqual(t, "flat", true, math.Abs(flat.advisoryFee(2_000_000)-20_000) < 1e-9)
+
+	config.AdvisoryFeeBps = 100
+	if err := config.Validate(); err == nil {
+		t.Fatalf("expected a flat fee and tiers together to fail")
+	}
+	config = &FeeConfig{AdvisoryTiers: []*FeeTier{{UpTo: 5_000_000, Bps: 50}, {UpTo: 1_000_000, Bps: 25}, {Bps: 10}}}
+	if err := config.Validate(); err == nil {
+		t.Fatalf("expected tiers out of order to fail")
+	}
+	if _, err := GetFeeModel(&FeeConfig{ExpenseRatios: map[string]float64{"QQQ": 0.002}}, optimizationAssets("SPY"), Yearly); err == nil {
+		t.Fatalf("expected an expense ratio for an unallocated ticker to fail")
+	}
+}
+
+func Test_Fees_RebalancingPaysTransactionCosts(t *testing.T) {
+	holdings := []float64{60, 40}
+	cost := rebalanceHoldings(holdings, []float64{0.5, 0.5}, 0.001)
+	e.AssertAreEqual(t, "cost on the traded notional", true, math.Abs(cost-0.02) < 1e-12)
+	e.AssertAreEqual(t, "back at target after the cost", true, math.Abs(holdings[0]-49.99) < 1e-12 && math.Abs(holdings[1]-49.99) < 1e-12)
+
+	yearly, _ := GetFeeModel(&FeeConfig{Rebalance: "Yearly"}, nil, Monthly)
+	e.AssertAreEqual(t, "not yet a year", false, yearly.rebalanceDue(10, holdings, []float64{0.5, 0.5}))
+	e.AssertAreEqual(t, "a year in", true, yearly.rebalanceDue(11, holdings, []float64{0.5, 0.5}))
+
+	threshold, _ := GetFeeModel(&FeeConfig{Rebalance: RebalanceThreshold, Threshold: 0.1}, nil, Monthly)
+	e.AssertAreEqual(t, "inside the band", false, threshold.rebalanceDue(0, []float64{55, 45}, []float64{0.5, 0.5}))
+	e.AssertAreEqual(t, "outside the band", true, threshold.rebalanceDue(0, []float64{65, 35}, []float64{0.5, 0.5}))
+
+	// withdrawals leave the drifted weights alone
+	holdings = []float64{60, 40}
+	addCashFlow(holdings, []float64{0.5, 0.5}, -50)
+	e.AssertAreEqual(t, "pro rata withdrawal", true, math.Abs(holdings[0]-30) < 1e-12 && math.Abs(holdings[1]-20) < 1e-12)
+	addCashFlow(holdings, []float64{0.5, 0.5}, -100)
+	e.AssertAreEqual(t, "depleted", 0.0, sumHoldings(holdings))
+	addCashFlow(holdings, []float64{0.5, 0.5}, 10)
+	e.AssertAreEqual(t, "contribution invested at target", 5.0, holdings[1])
+}
+
+func Test_Fees_ZeroFeesReproduceThePlainPath(t *testing.T) {
+	seriesReturns := generateMockSeriesReturns(t, Daily*2)
+	sr, err := GetStatisticalResources(SimulationRequest{DistType: StandardNormal}, seriesReturns)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	request := SimulationRequest{
+		SimulationUnitOfTime: Monthly,
+		SimulationDuration:   120,
+		InitialValue:         1000,
+		CashFlows:            []*CashFlow{{Start: 12, Amount: -5}},
+	}
+
+	plain, err := NewWorkerResources(sr, 42, 0).simulatePath(request)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	request.Fees = &FeeConfig{}
+	sr.Fees, err = GetFeeModel(request.Fees, seriesReturns, request.SimulationUnitOfTime)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	withFees, err := NewWorkerResources(sr, 42, 0).simulatePath(request)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for i, v := range plain.PathValues {
+		if math.Abs(withFees.PathValues[i]-v) > 1e-9*v {
+			t.Fatalf("period %d: %v with zero fees, %v without", i, withFees.PathValues[i], v)
+		}
+	}
+	e.AssertAreEqual(t, "gross final value", true, math.Abs(withFees.GrossFinalValue-plain.FinalValue) < 1e-9*plain.FinalValue)
+}
+
+func Test_Fees_CalendarRebalancesFollowElapsedTime(t *testing.T) {
+	count := func(rule string, simulationUnitOfTime int) int {
+		model, err := GetFeeModel(&FeeConfig{Rebalance: rule}, nil, simulationUnitOfTime)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		n := 0
+		for period := range simulationUnitOfTime {
+			if model.rebalanceDue(period, nil, nil) {
+				n++
+			}
+		}
+		return n
+	}
+
+	e.AssertAreEqual(t, "monthly on weekly steps", 12, count(RebalanceMonthly, Weekly))
+	e.AssertAreEqual(t, "quarterly on weekly steps", 4, count(RebalanceQuarterly, Weekly))
+	e.AssertAreEqual(t, "monthly on daily steps", 12, count(RebalanceMonthly, Daily))
+	e.AssertAreEqual(t, "monthly on yearly steps", 1, count(RebalanceMonthly, Yearly))
+	e.AssertAreEqual(t, "yearly on monthly steps", 1, count(RebalanceYearly, Monthly))
+}
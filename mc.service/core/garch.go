@@ -0,0 +1,201 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"gonum.org/v1/gonum/optimize"
+	"gonum.org/v1/gonum/stat"
+
+	m "mc.data/models"
+)
+
+// how per asset volatility behaves over a simulated path
+const (
+	VolatilityConstant = "constant" // the annualized sigma every period
+	VolatilityGarch    = "garch"    // GARCH(1,1) conditional variance per asset with constant correlation (CCC-GARCH)
+)
+
+const (
+	DefaultGarchLookback = 10 * 365 * 24 * time.Hour
+	minGarchObservations = 50
+)
+
+// GarchFit is a GARCH(1,1) model of one asset's returns, h[t] = omega + alpha * e[t-1]^2 + beta * h[t-1]
+// with e the demeaned return. Variances are per period of the returns it was fit on.
+type GarchFit struct {
+	Id                  int32     `json:"id"`
+	Ticker              string    `json:"ticker"`
+	Mu                  float64   `json:"mu"` // per period mean the residuals are taken around
+	Omega               float64   `json:"omega"`
+	Alpha               float64   `json:"alpha"`
+	Beta                float64   `json:"beta"`
+	Persistence         float64   `json:"persistence"`     // alpha + beta
+	LongRunVariance     float64   `json:"longrunvariance"` // omega / (1 - alpha - beta)
+	CurrentVariance     float64   `json:"currentvariance"` // forecast for the period after the last return
+	AnnualizedLongRun   float64   `json:"annualizedlongrun"`
+	AnnualizedCurrent   float64   `json:"annualizedcurrent"`
+	HalfLife            float64   `json:"halflife"` // periods for a variance shock to decay by half
+	LogLikelihood       float64   `json:"loglikelihood"`
+	Observations        int       `json:"observations"`
+	AnnualizationFactor int       `json:"annualizationfactor"`
+	Start               time.Time `json:"start"`
+	End                 time.Time `json:"end"`
+	Converged           bool      `json:"converged"`
+}
+
+func normalizeVolatilityModel(model string) (string, error) {
+	switch v := strings.ToLower(strings.TrimSpace(model)); v {
+	case "":
+		return VolatilityConstant, nil
+	case VolatilityConstant, VolatilityGarch:
+		return v, nil
+	default:
+		return "", fmt.Errorf("unknown volatility model %q, expected %s or %s", model, VolatilityConstant, VolatilityGarch)
+	}
+}
+
+// FitGarch fits a GARCH(1,1) by gaussian maximum likelihood to date ascending returns. The parameters
+// are optimized unconstrained, omega through exp and alpha/beta through a softmax so alpha + beta < 1.
+func FitGarch(returns []float64) (*GarchFit, error) {
+	if len(returns) < minGarchObservations {
+		return nil, fmt.Errorf("garch needs at least %d returns, got %d", minGarchObservations, len(returns))
+	}
+
+	mu := stat.Mean(returns, nil)
+	resid := make([]float64, len(returns))
+	for i, r := range returns {
+		resid[i] = r - mu
+	}
+	variance := stat.Variance(resid, nil)
+	if variance <= 0 {
+		return nil, fmt.Errorf("garch needs returns that vary")
+	}
+
+	unpack := func(x []float64) (omega, alpha, beta float64) {
+		ea, eb := math.Exp(x[1]), math.Exp(x[2])
+		return math.Exp(x[0]), ea / (1 + ea + eb), eb / (1 + ea + eb)
+	}
+
+	problem := optimize.Problem{
+		Func: func(x []float64) float64 {
+			omega, alpha, beta := unpack(x)
+			return -garchLogLikelihood(resid, variance, omega, alpha, beta)
+		},
+	}
+
+	// start from a typical equity fit, alpha 0.08 and beta 0.9, with the sample variance as the long run level
+	alpha0, beta0 := 0.08, 0.9
+	rest := 1 - alpha0 - beta0
+	x0 := []float64{math.Log(variance * rest), math.Log(alpha0 / rest), math.Log(beta0 / rest)}
+	result, err := optimize.Minimize(problem, x0, &optimize.Settings{MajorIterations: 2000}, &optimize.NelderMead{})
+	if err != nil && result == nil {
+		return nil, fmt.Errorf("error fitting garch: %w", err)
+	}
+
+	omega, alpha, beta := unpack(result.X)
+	fit := &GarchFit{
+		Mu:            mu,
+		Omega:         omega,
+		Alpha:         alpha,
+		Beta:          beta,
+		Persistence:   alpha + beta,
+		LogLikelihood: -result.F,
+		Observations:  len(returns),
+		Converged:     err == nil && result.Status != optimize.IterationLimit,
+	}
+	fit.LongRunVariance = omega / (1 - alpha - beta)
+	fit.CurrentVariance = garchVariances(resid, variance, omega, alpha, beta)[len(resid)]
+	fit.HalfLife = math.Log(0.5) / math.Log(fit.Persistence)
+
+	return fit, nil
+}
+
+// garchVariances runs the variance recursion from the sample variance, the extra last entry is the forecast
+func garchVariances(resid []float64, h0, omega, alpha, beta float64) []float64 {
+	h := make([]float64, len(resid)+1)
+	h[0] = h0
+	for t, e := range resid {
+		h[t+1] = omega + alpha*e*e + beta*h[t]
+	}
+	return h
+}
+
+func garchLogLikelihood(resid []float64, h0, omega, alpha, beta float64) float64 {
+	h := garchVariances(resid, h0, omega, alpha, beta)
+	ll := 0.0
+	for t, e := range resid {
+		ll -= 0.5 * (math.Log(2*math.Pi) + math.Log(h[t]) + e*e/h[t])
+	}
+	return ll
+}
+
+// annualize fills in the yearly figures once the frequency of the fitted returns is known
+func (g *GarchFit) annualize(annualizationFactor int) {
+	g.AnnualizationFactor = annualizationFactor
+	g.AnnualizedLongRun = math.Sqrt(g.LongRunVariance * float64(annualizationFactor))
+	g.AnnualizedCurrent = math.Sqrt(g.CurrentVariance * float64(annualizationFactor))
+}
+
+// GetGarchFits fits every aligned series, used by the garch simulation mode
+func GetGarchFits(seriesReturns []*SeriesReturns) ([]*GarchFit, error) {
+	res := make([]*GarchFit, len(seriesReturns))
+	for i, s := range seriesReturns {
+		fit, err := FitGarch(s.Returns)
+		if err != nil {
+			return nil, fmt.Errorf("error fitting garch for %s: %w", s.Ticker, err)
+		}
+		fit.Id, fit.Ticker = s.Id, s.Ticker
+		fit.annualize(s.AnnualizationFactor)
+		if len(s.Dates) > 0 {
+			fit.Start, fit.End = s.Dates[0], s.Dates[len(s.Dates)-1]
+		}
+		res[i] = fit
+	}
+	return res, nil
+}
+
+// FitSymbolGarch fits a GARCH(1,1) to a stored symbol's returns within the lookback
+func (sc *ServiceContext) FitSymbolGarch(symbol string, lookback time.Duration) (*GarchFit, error) {
+	md, err := sc.PostgresConnection.GetMetaDataBySymbol(sc.Context, symbol)
+	if err != nil {
+		return nil, fmt.Errorf("error getting meta data for %s: %w", symbol, err)
+	}
+	if md == nil {
+		return nil, fmt.Errorf("symbol %s is not stored", symbol)
+	}
+
+	returns, err := sc.PostgresConnection.GetTimeSeriesReturns(sc.Context, []int32{md.Id}, lookback)
+	if err != nil {
+		return nil, fmt.Errorf("error getting time series returns: %w", err)
+	}
+
+	series := &SeriesReturns{
+		SimulationAllocation: SimulationAllocation{Id: md.Id, Ticker: md.Symbol},
+		AnnualizationFactor:  FrequencyAnnualizationFactor(md.Frequency),
+	}
+	for _, r := range returns {
+		series.Returns = append(series.Returns, r.LogReturn)
+		series.Dates = append(series.Dates, r.Timestamp)
+	}
+
+	fits, err := GetGarchFits([]*SeriesReturns{sortedSeries(series)})
+	if err != nil {
+		return nil, err
+	}
+	return fits[0], nil
+}
+
+// FrequencyAnnualizationFactor maps a stored series frequency to its periods per year
+func FrequencyAnnualizationFactor(frequency string) int {
+	switch frequency {
+	case m.FrequencyDaily:
+		return Daily
+	case m.FrequencyMonthly:
+		return Monthly
+	default:
+		return Weekly
+	}
+}
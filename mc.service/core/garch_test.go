@@ -0,0 +1,92 @@
+package core
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"gonum.org/v1/gonum/stat"
+	e "mc.data/extensions"
+)
+
+// simulateGarch draws returns from a known GARCH(1,1)
+func simulateGarch(n int, mu, omega, alpha, beta float64, seed uint64) []float64 {
+	rng := rand.New(rand.NewPCG(seed, 0))
+	h := omega / (1 - alpha - beta)
+	res := make([]float64, n)
+	for i := range n {
+		shock := math.Sqrt(h) * rng.NormFloat64()
+		res[i] = mu + shock
+		h = omega + alpha*shock*shock + beta*h
+	}
+	return res
+}
+
+func Test_Garch_RecoversKnownParameters(t *testing.T) {
+	returns := simulateGarch(6000, 0.0004, 2e-6, 0.1, 0.85, 7)
+
+	fit, err := FitGarch(returns)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	t.Logf("omega %.3g alpha %.4f beta %.4f", fit.Omega, fit.Alpha, fit.Beta)
+	e.AssertAreEqual(t, "converged", true, fit.Converged)
+	e.AssertAreEqual(t, "alpha", true, math.Abs(fit.Alpha-0.1) < 0.03)
+	e.AssertAreEqual(t, "beta", true, math.Abs(fit.Beta-0.85) < 0.04)
+	e.AssertAreEqual(t, "long run variance", true, math.Abs(fit.LongRunVariance-4e-5)/4e-5 < 0.25)
+	e.AssertAreEqual(t, "persistence", true, fit.Persistence < 1)
+
+	// a garch fit beats a constant variance model on clustered data
+	constant := garchLogLikelihood(returns, stat.Variance(returns, nil), stat.Variance(returns, nil), 0, 0)
+	e.AssertAreEqual(t, "likelihood improves", true, fit.LogLikelihood > constant)
+
+	if _, err := FitGarch(returns[:10]); err == nil {
+		t.Fatalf("expected a short history to be rejected")
+	}
+}
+
+func Test_Garch_SimulationEvolvesVariance(t *testing.T) {
+	series := make([]*SeriesReturns, 2)
+	start := time.Date(2010, time.January, 4, 0, 0, 0, 0, time.UTC)
+	for i := range series {
+		returns := simulateGarch(2000, 0.0003, 2e-6, 0.08, 0.9, uint64(i+1))
+		series[i] = &SeriesReturns{
+			SimulationAllocation: SimulationAllocation{Id: int32(i), Ticker: generateTicker(t, i), Weight: 0.5},
+			Returns:              returns,
+			AnnualizationFactor:  Daily,
+		}
+		for k := range returns {
+			series[i].Dates = append(series[i].Dates, start.AddDate(0, 0, k))
+		}
+	}
+
+	request := SimulationRequest{VolatilityModel: VolatilityGarch, SimulationUnitOfTime: Daily}
+	sr, err := GetStatisticalResources(request, series)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	e.AssertAreEqual(t, "fits", 2, len(sr.Garch))
+	e.AssertAreEqual(t, "fit dates", start, sr.Garch[0].Start)
+
+	worker := NewWorkerResources(sr, 42, 0)
+	worker.StartPath()
+	e.AssertAreEqual(t, "starts at the current variance", sr.Garch[0].CurrentVariance, worker.variance[0])
+
+	returns := make([]float64, 5000)
+	for i := range returns {
+		returns[i] = worker.GetCorrelatedReturns(Daily)[0]
+	}
+	e.AssertAreEqual(t, "variance moved", true, worker.variance[0] != sr.Garch[0].CurrentVariance)
+
+	// over a long path the variance settles around the long run level
+	simulated := stat.Variance(returns, nil)
+	t.Logf("simulated %.3g long run %.3g persistence %.4f", simulated, sr.Garch[0].LongRunVariance, sr.Garch[0].Persistence)
+	e.AssertAreEqual(t, "long run variance", true, math.Abs(simulated-sr.Garch[0].LongRunVariance)/sr.Garch[0].LongRunVariance < 0.35)
+
+	request.SimulationUnitOfTime = Weekly
+	if _, err := GetStatisticalResources(request, series); err == nil {
+		t.Fatalf("expected garch at a different frequency than the returns to be rejected")
+	}
+}
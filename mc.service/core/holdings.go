@@ -0,0 +1,229 @@
+package core
+
+import "math"
+
+// pathHoldings is the value held in each asset on a path that charges fees or taxes, alongside the same
+// path without them so the drag can be reported
+type pathHoldings struct {
+	fees     *FeeModel
+	taxes    *TaxModel // nil when taxes are off
+	weights  []float64 // target
+	net      []float64
+	gross    []float64
+	basis    []float64 // cost of each net holding, taxable accounts only
+	before   []float64 // net before the trade being taxed
+	realized float64   // gains realized this period, taxed when it settles
+	carry    float64   // realized losses not yet offset, zero or negative
+	paid     FeesPaid
+	taxed    TaxesPaid
+}
+
+// startHoldings invests initial at the target weights, without a fee model nothing is charged and the
+// holdings are rebalanced every period
+func startHoldings(fees *FeeModel, taxes *TaxModel, initial float64, weights []float64) *pathHoldings {
+	if fees == nil {
+		fees = &FeeModel{periodExpenses: make([]float64, len(weights)), periods: 1, rebalance: RebalanceEveryPeriod}
+	}
+
+	res := &pathHoldings{
+		fees:    fees,
+		taxes:   taxes,
+		weights: weights,
+		net:     make([]float64, len(weights)),
+		gross:   make([]float64, len(weights)),
+	}
+	for i, w := range weights {
+		res.net[i] = initial * w
+		res.gross[i] = initial * w
+	}
+	if taxes != nil && taxes.account == TaxAccountTaxable {
+		res.basis = make([]float64, len(weights))
+		copy(res.basis, res.net)
+		res.before = make([]float64, len(weights))
+	}
+	return res
+}
+
+// grow moves every holding with its log return then takes the period's dividend tax and running fees,
+// returning the value left. Returns include dividends, so the tax is withheld from the reinvested amount.
+func (ph *pathHoldings) grow(returns []float64) float64 {
+	for i, r := range returns {
+		g := math.Exp(r)
+		ph.gross[i] *= g
+		ph.net[i] *= g
+
+		if ph.basis != nil {
+			dividend := ph.net[i] * ph.taxes.periodYields[i]
+			tax := dividend * ph.taxes.dividendRate
+			ph.net[i] -= tax
+			ph.basis[i] += dividend - tax
+			ph.taxed.Dividends += tax
+		}
+
+		expense := ph.net[i] * ph.fees.periodExpenses[i]
+		ph.net[i] -= expense
+		ph.paid.Expenses += expense
+	}
+
+	total := sumHoldings(ph.net)
+	if total <= 0 {
+		return 0
+	}
+	advisory := math.Min(ph.fees.advisoryFee(total)/ph.fees.periods, total)
+	scaleHoldings(ph.net, 1-advisory/total)
+	ph.paid.Advisory += advisory
+	return total - advisory
+}
+
+// settle lands the period's cash flow, rebalances if one is due and pays the tax on the gains realized,
+// returning the value left
+func (ph *pathHoldings) settle(period int, cashFlow float64) float64 {
+	addCashFlow(ph.gross, ph.weights, cashFlow)
+	ph.addCashFlow(cashFlow)
+
+	if ph.fees.rebalanceDue(period, ph.gross, ph.weights) {
+		rebalanceHoldings(ph.gross, ph.weights, 0)
+	}
+	if ph.fees.rebalanceDue(period, ph.net, ph.weights) && sumHoldings(ph.net) > 0 {
+		ph.snapshot()
+		ph.paid.Transactions += rebalanceHoldings(ph.net, ph.weights, ph.fees.transactionCost)
+		ph.paid.Rebalances++
+		ph.traded()
+	}
+
+	ph.payCapitalGainsTax()
+	return sumHoldings(ph.net)
+}
+
+// addCashFlow lands a cash flow on the net holdings. Deferred withdrawals are taxed as income, so enough
+// more is sold to leave the amount after tax.
+func (ph *pathHoldings) addCashFlow(amount float64) {
+	if ph.taxes != nil && ph.taxes.account == TaxAccountDeferred && amount < 0 {
+		sold := math.Min(-amount/(1-ph.taxes.incomeRate), sumHoldings(ph.net))
+		ph.taxed.Income += sold * ph.taxes.incomeRate
+		amount = -sold
+	}
+
+	ph.snapshot()
+	addCashFlow(ph.net, ph.weights, amount)
+	ph.traded()
+}
+
+// payCapitalGainsTax settles the period's net realized gains, losses carry forward. The tax is raised by
+// selling pro rata, the gains on that sale are taxed with the next period's.
+func (ph *pathHoldings) payCapitalGainsTax() {
+	if ph.basis == nil {
+		return
+	}
+
+	gains := ph.realized + ph.carry
+	ph.realized = 0
+	ph.carry = math.Min(gains, 0)
+
+	total := sumHoldings(ph.net)
+	tax := math.Min(math.Max(gains, 0)*ph.taxes.capitalGainsRate, total)
+	if tax <= 0 {
+		return
+	}
+	ph.taxed.CapitalGains += tax
+
+	ph.snapshot()
+	scaleHoldings(ph.net, 1-tax/total)
+	ph.traded()
+}
+
+func (ph *pathHoldings) snapshot() {
+	if ph.basis != nil {
+		copy(ph.before, ph.net)
+	}
+}
+
+// traded updates the cost basis since the snapshot at average cost. Sales realize their share of the
+// holding's gain and purchases add what they cost.
+func (ph *pathHoldings) traded() {
+	if ph.basis == nil {
+		return
+	}
+	for i, held := range ph.before {
+		change := ph.net[i] - held
+		switch {
+		case change > 0:
+			ph.basis[i] += change
+		case change < 0 && held > 0:
+			cost := ph.basis[i] * -change / held
+			ph.basis[i] -= cost
+			ph.realized += -change - cost
+		}
+	}
+}
+
+// finish totals the fees and taxes, the drag compares annualized growth from initial with and without
+// them. The final value is returned after the tax owed on withdrawing it.
+func (ph *pathHoldings) finish(initial, years float64) (*FeesPaid, *TaxesPaid, float64) {
+	value := sumHoldings(ph.net)
+
+	fees := ph.paid
+	fees.Total = fees.Expenses + fees.Advisory + fees.Transactions
+	if years > 0 {
+		fees.Drag = math.Pow(sumHoldings(ph.gross)/initial, 1/years) - math.Pow(value/initial, 1/years)
+	}
+	if ph.taxes == nil {
+		return &fees, nil, value
+	}
+
+	taxes := ph.taxed
+	taxes.Total = taxes.Dividends + taxes.CapitalGains + taxes.Income
+	switch ph.taxes.account {
+	case TaxAccountTaxable:
+		gains := value - sumHoldings(ph.basis) + ph.realized + ph.carry
+		taxes.Liquidation = math.Max(gains, 0) * ph.taxes.capitalGainsRate
+	case TaxAccountDeferred:
+		taxes.Liquidation = value * ph.taxes.incomeRate
+	}
+	return &fees, &taxes, value - taxes.Liquidation
+}
+
+func sumHoldings(holdings []float64) float64 {
+	res := 0.0
+	for _, h := range holdings {
+		res += h
+	}
+	return res
+}
+
+func scaleHoldings(holdings []float64, f float64) {
+	for i := range holdings {
+		holdings[i] *= f
+	}
+}
+
+// addCashFlow spreads a withdrawal pro rata so it does not move the weights, a contribution to an empty
+// portfolio is invested at the target weights. Withdrawals stop at zero.
+func addCashFlow(holdings, weights []float64, amount float64) {
+	if amount == 0 {
+		return
+	}
+	total := sumHoldings(holdings)
+	if total <= 0 {
+		for i, w := range weights {
+			holdings[i] = math.Max(amount, 0) * w
+		}
+		return
+	}
+	scaleHoldings(holdings, math.Max(total+amount, 0)/total)
+}
+
+// rebalanceHoldings trades back to the weights, paying costRate on the traded notional, and returns the cost
+func rebalanceHoldings(holdings, weights []float64, costRate float64) float64 {
+	total := sumHoldings(holdings)
+	traded := 0.0
+	for i, w := range weights {
+		traded += math.Abs(total*w - holdings[i])
+	}
+	cost := traded * costRate
+	total -= cost
+	for i, w := range weights {
+		holdings[i] = total * w
+	}
+	return cost
+}
@@ -0,0 +1,253 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat"
+	"gonum.org/v1/gonum/stat/distuv"
+
+	m "mc.data/models"
+)
+
+// how simulated inflation evolves
+const (
+	InflationFixed = "fixed" // the same rate every period
+	InflationAR1   = "ar1"   // mean reverting around Rate
+	InflationCpi   = "cpi"   // resampled from the changes of a stored CPI series
+)
+
+const DefaultInflationRate = 0.025
+
+// InflationConfig adds a price level to every path so values can also be reported in today's money
+type InflationConfig struct {
+	Model       string  `json:"model"`       // fixed (default), ar1 or cpi
+	Rate        float64 `json:"rate"`        // annual, the fixed rate or the ar1 long run mean, zero uses DefaultInflationRate
+	Current     float64 `json:"current"`     // ar1 starting rate, zero starts at Rate
+	Persistence float64 `json:"persistence"` // ar1 share of a deviation from Rate left after a year, in [0, 1)
+	Volatility  float64 `json:"volatility"`  // ar1 long run standard deviation of the annual rate
+	Symbol      string  `json:"symbol"`      // stored CPI series the cpi model resamples
+
+	// correlation of inflation surprises with each ticker's standardized return, ar1 and cpi only
+	Correlations map[string]float64 `json:"correlations"`
+
+	cpi                    []float64 // stored CPI levels, date ascending, loaded before the statistics are built
+	cpiAnnualizationFactor int
+}
+
+// InflationModel is the resolved config the workers draw from
+type InflationModel struct {
+	Model       string
+	periodMean  float64   // log inflation per simulated period
+	periodStart float64   // ar1 deviation from the mean before the first period
+	periodPhi   float64   // ar1 persistence per simulated period
+	periodShock float64   // ar1 shock standard deviation per simulated period
+	loadings    []float64 // regression of the inflation shock on the standardized asset shocks
+	residual    float64   // standard deviation of the part of the shock the assets do not explain
+	samples     []float64 // cpi log inflation per simulated period, ascending
+}
+
+func normalizeInflationModel(model string) (string, error) {
+	switch v := strings.ToLower(strings.TrimSpace(model)); v {
+	case "":
+		return InflationFixed, nil
+	case InflationFixed, InflationAR1, InflationCpi:
+		return v, nil
+	default:
+		return "", fmt.Errorf("unknown inflation model %q, expected %s, %s or %s", model, InflationFixed, InflationAR1, InflationCpi)
+	}
+}
+
+func (ic *InflationConfig) Validate() error {
+	model, err := normalizeInflationModel(ic.Model)
+	if err != nil {
+		return err
+	}
+	if ic.Rate <= -1 || ic.Current <= -1 {
+		return fmt.Errorf("inflation rates must be above -100%%")
+	}
+	if ic.Persistence < 0 || ic.Persistence >= 1 {
+		return fmt.Errorf("inflation persistence must be in [0, 1), got %v", ic.Persistence)
+	}
+	if ic.Volatility < 0 {
+		return fmt.Errorf("inflation volatility cannot be negative")
+	}
+	if model == InflationCpi && ic.Symbol == "" {
+		return fmt.Errorf("the %s inflation model needs the symbol of a stored CPI series", InflationCpi)
+	}
+	if model == InflationFixed && len(ic.Correlations) > 0 {
+		return fmt.Errorf("fixed inflation has no surprises to correlate")
+	}
+	for ticker, c := range ic.Correlations {
+		if c < -1 || c > 1 {
+			return fmt.Errorf("inflation correlation with %s must be in [-1, 1], got %v", ticker, c)
+		}
+	}
+	return nil
+}
+
+// loadInflationHistory fetches the stored CPI series the cpi model resamples
+func (sc *ServiceContext) loadInflationHistory(request SimulationRequest) error {
+	ic := request.Inflation
+	if ic == nil {
+		return nil
+	}
+	if model, _ := normalizeInflationModel(ic.Model); model != InflationCpi {
+		return nil
+	}
+
+	md, err := sc.PostgresConnection.GetMetaDataBySymbol(sc.Context, ic.Symbol)
+	if err != nil {
+		return fmt.Errorf("error getting meta data for %s: %w", ic.Symbol, err)
+	}
+	if md == nil {
+		return fmt.Errorf("cpi series %s is not stored", ic.Symbol)
+	}
+
+	bars, err := sc.PostgresConnection.GetTimeSeriesDataBetween(sc.Context, []int32{md.Id}, time.Time{}, time.Now())
+	if err != nil {
+		return err
+	}
+
+	ic.cpi = ic.cpi[:0]
+	for _, b := range bars {
+		ic.cpi = append(ic.cpi, cpiLevel(b))
+	}
+	ic.cpiAnnualizationFactor = FrequencyAnnualizationFactor(md.Frequency)
+	return nil
+}
+
+// cpiLevel is the index value of a stored CPI bar, imported series may only fill the close
+func cpiLevel(b *m.TimeSeriesData) float64 {
+	if b.AdjustedClose > 0 {
+		return b.AdjustedClose
+	}
+	return b.Close
+}
+
+// GetInflationModel resolves the config for the simulation's period length. The asset shocks are
+// standardized returns with correlation matrix corr, the inflation shock is regressed on them so the
+// requested correlations hold while the asset correlations are untouched.
+func GetInflationModel(config *InflationConfig, assets []*SeriesReturns, corr *mat.SymDense, simulationUnitOfTime int) (*InflationModel, error) {
+	model, err := normalizeInflationModel(config.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	rate := config.Rate
+	if rate == 0 {
+		rate = DefaultInflationRate
+	}
+	u := float64(simulationUnitOfTime)
+	res := &InflationModel{Model: model, periodMean: math.Log1p(rate) / u, residual: 1}
+
+	switch model {
+	case InflationAR1:
+		res.periodPhi = math.Pow(config.Persistence, 1/u)
+		res.periodShock = ar1PeriodShock(config.Volatility, res.periodPhi, simulationUnitOfTime)
+		if config.Current != 0 {
+			res.periodStart = (math.Log1p(config.Current) - math.Log1p(rate)) / u
+		}
+	case InflationCpi:
+		res.samples, err = cpiPeriodInflation(config.cpi, config.cpiAnnualizationFactor, simulationUnitOfTime)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(config.Correlations) == 0 {
+		return res, nil
+	}
+
+	n := len(assets)
+	rho := mat.NewVecDense(n, nil)
+	for ticker, c := range config.Correlations {
+		found := false
+		for i, a := range assets {
+			if strings.EqualFold(a.Ticker, strings.TrimSpace(ticker)) {
+				rho.SetVec(i, c)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("inflation correlation for %s which is not allocated", ticker)
+		}
+	}
+
+	var loadings mat.VecDense
+	if err := loadings.SolveVec(corr, rho); err != nil {
+		return nil, fmt.Errorf("error solving for the inflation loadings: %w", err)
+	}
+	explained := mat.Dot(rho, &loadings)
+	if explained >= 1 {
+		return nil, fmt.Errorf("the inflation correlations are inconsistent with the asset correlations")
+	}
+	res.loadings = loadings.RawVector().Data
+	res.residual = math.Sqrt(1 - explained)
+	return res, nil
+}
+
+// ar1PeriodShock keeps the annual rate's long run standard deviation at volatility. The annual rate is
+// the sum of u stationary steps with variance s², whose variance is s²(u + 2Σ(u-k)φᵏ) over k < u.
+func ar1PeriodShock(volatility, phi float64, simulationUnitOfTime int) float64 {
+	u := simulationUnitOfTime
+	sum := float64(u)
+	for k := 1; k < u; k++ {
+		sum += 2 * float64(u-k) * math.Pow(phi, float64(k))
+	}
+	return volatility / math.Sqrt(sum) * math.Sqrt(1-phi*phi)
+}
+
+// cpiPeriodInflation turns CPI levels into the log inflation of every window one simulated period long,
+// sorted so a uniform draw maps to a quantile. Periods shorter than the CPI's own spread its changes evenly.
+func cpiPeriodInflation(levels []float64, cpiAnnualizationFactor, simulationUnitOfTime int) ([]float64, error) {
+	if cpiAnnualizationFactor == 0 {
+		cpiAnnualizationFactor = Monthly
+	}
+	step := max(cpiAnnualizationFactor/simulationUnitOfTime, 1)
+	scale := float64(cpiAnnualizationFactor) / float64(simulationUnitOfTime) / float64(step)
+	if len(levels) <= step {
+		return nil, fmt.Errorf("the cpi series has %d observations, too few for a %s period", len(levels), convertFrequencyToString(simulationUnitOfTime))
+	}
+
+	res := make([]float64, 0, len(levels)-step)
+	for t := step; t < len(levels); t++ {
+		if levels[t] <= 0 || levels[t-step] <= 0 {
+			return nil, fmt.Errorf("the cpi series has non positive levels")
+		}
+		res = append(res, math.Log(levels[t]/levels[t-step])*scale)
+	}
+	slices.Sort(res)
+	return res, nil
+}
+
+// drawInflation returns the period's log inflation, the shock leans on this period's standardized returns
+func (wr *WorkerResource) drawInflation(returns []float64, simulationUnitOfTime int) float64 {
+	im := wr.Inflation
+	if im.Model == InflationFixed {
+		return im.periodMean
+	}
+
+	normalDist := distuv.Normal{Mu: 0, Sigma: 1, Src: wr.rng}
+	shock := im.residual * normalDist.Rand()
+	u := float64(simulationUnitOfTime)
+	for i, l := range im.loadings {
+		if l == 0 {
+			continue
+		}
+		z := (returns[i] - (wr.Mu[i]-0.5*wr.Sigma[i]*wr.Sigma[i])/u) / (wr.Sigma[i] / math.Sqrt(u))
+		shock += l * z
+	}
+
+	if im.Model == InflationCpi {
+		q := normalDist.CDF(shock)
+		return stat.Quantile(q, stat.Empirical, im.samples, nil)
+	}
+
+	wr.inflationDeviation = im.periodPhi*wr.inflationDeviation + im.periodShock*shock
+	return im.periodMean + wr.inflationDeviation
+}
@@ -0,0 +1,159 @@
+package core
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat"
+
+	e "mc.data/extensions"
+)
+
+// flatResources never moves, so a path only changes with its cash flows
+func flatResources(t *testing.T) *StatisticalResources {
+	t.Helper()
+	cov := mat.NewSymDense(1, []float64{1})
+	chol, err := GetCholeskyDecomposition(cov)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return &StatisticalResources{CovMatrix: cov, CholeskyL: chol, AssetWeight: []float64{1}, Mu: []float64{0}, Sigma: []float64{0}, DistType: StandardNormal}
+}
+
+func Test_Inflation_DeflatesPathsAndIndexesCashFlows(t *testing.T) {
+	request := SimulationRequest{
+		SimulationUnitOfTime: Yearly,
+		SimulationDuration:   10,
+		InitialValue:         1000,
+		CashFlows:            []*CashFlow{{Start: 1, Amount: -50, Indexed: true}},
+		Inflation:            &InflationConfig{Rate: 0.03},
+	}
+
+	sr := flatResources(t)
+	var err error
+	sr.Inflation, err = GetInflationModel(request.Inflation, nil, nil, request.SimulationUnitOfTime)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	res, err := NewWorkerResources(sr, 42, 0).simulatePath(request)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := 1000.0
+	for year := 1; year <= 10; year++ {
+		want -= 50 * math.Pow(1.03, float64(year))
+	}
+	e.AssertAreEqual(t, "indexed withdrawals", true, math.Abs(res.FinalValue-want) < 1e-9)
+	e.AssertAreEqual(t, "real value", true, math.Abs(res.RealFinalValue-want/math.Pow(1.03, 10)) < 1e-9)
+	e.AssertAreEqual(t, "inflation", true, math.Abs(res.Inflation-0.03) < 1e-12)
+	e.AssertAreEqual(t, "total return", true, math.Abs(res.TotalReturn-(want/1000-1)) < 1e-12)
+	e.AssertAreEqual(t, "real path starts at the initial value", 1000.0, res.RealPathValues[0])
+
+	// withdrawals stop at zero
+	request.CashFlows = []*CashFlow{{Start: 2, End: 2, Amount: -5000}}
+	res, _ = NewWorkerResources(sr, 42, 0).simulatePath(request)
+	e.AssertAreEqual(t, "depleted", 0.0, res.FinalValue)
+	e.AssertAreEqual(t, "before the withdrawal", 1000.0, res.PathValues[1])
+}
+
+func Test_Inflation_CorrelatesWithAssetReturns(t *testing.T) {
+	assets := optimizationAssets("SPY", "TIP")
+	corr := mat.NewSymDense(2, []float64{1, 0, 0, 1})
+	config := &InflationConfig{Model: InflationAR1, Volatility: 0.01, Correlations: map[string]float64{"tip": 0.6}}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	model, err := GetInflationModel(config, assets, corr, Yearly)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	e.AssertAreEqual(t, "loading", true, math.Abs(model.loadings[1]-0.6) < 1e-12)
+	e.AssertAreEqual(t, "residual", true, math.Abs(model.residual-0.8) < 1e-12)
+
+	sr := &StatisticalResources{Mu: []float64{0, 0}, Sigma: []float64{0.2, 0.05}, Inflation: model}
+	worker := NewWorkerResources(sr, 42, 0)
+	worker.StartPath()
+
+	rng := rand.New(rand.NewPCG(1, 2))
+	n := 20_000
+	z, inflation := make([]float64, n), make([]float64, n)
+	for i := range n {
+		z[i] = rng.NormFloat64()
+		returns := []float64{-0.02, -0.5*0.05*0.05 + 0.05*z[i]}
+		worker.inflationDeviation = 0 // no persistence so only the shock moves it
+		inflation[i] = worker.drawInflation(returns, Yearly)
+	}
+	e.AssertAreEqual(t, "correlation", true, math.Abs(stat.Correlation(z, inflation, nil)-0.6) < 0.03)
+	e.AssertAreEqual(t, "mean", true, math.Abs(stat.Mean(inflation, nil)-math.Log1p(DefaultInflationRate)) < 0.001)
+
+	config.Correlations = map[string]float64{"SPY": 0.8, "TIP": 0.8}
+	if _, err := GetInflationModel(config, assets, corr, Yearly); err == nil {
+		t.Fatalf("expected correlations the assets cannot support to fail")
+	}
+}
+
+func Test_Inflation_AR1KeepsTheAnnualVolatility(t *testing.T) {
+	for _, persistence := range []float64{0, 0.5, 0.9} {
+		config := &InflationConfig{Model: InflationAR1, Volatility: 0.01, Persistence: persistence}
+		model, err := GetInflationModel(config, nil, nil, Monthly)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		worker := NewWorkerResources(&StatisticalResources{Inflation: model}, 42, 0)
+		worker.StartPath()
+		for range 10 * Monthly { // burn in towards the stationary spread
+			worker.drawInflation(nil, Monthly)
+		}
+
+		annual := make([]float64, 20_000)
+		for i := range annual {
+			for range Monthly {
+				annual[i] += worker.drawInflation(nil, Monthly)
+			}
+		}
+		sd := stat.StdDev(annual, nil)
+		if math.Abs(sd-0.01) > 0.0005 {
+			t.Fatalf("persistence %v: annual rate standard deviation %v, expected 0.01", persistence, sd)
+		}
+	}
+}
+
+func Test_Inflation_ResamplesCpiWindows(t *testing.T) {
+	levels := make([]float64, 37)
+	for i := range levels {
+		levels[i] = 100 * math.Pow(1.002, float64(i))
+	}
+
+	yearly, err := cpiPeriodInflation(levels, Monthly, Yearly)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	e.AssertAreEqual(t, "overlapping windows", 25, len(yearly))
+	e.AssertAreEqual(t, "a year of monthly changes", true, math.Abs(yearly[0]-12*math.Log(1.002)) < 1e-12)
+
+	weekly, _ := cpiPeriodInflation(levels, Monthly, Weekly)
+	e.AssertAreEqual(t, "spread over the weeks", true, math.Abs(weekly[0]-12.0/52*math.Log(1.002)) < 1e-12)
+
+	if _, err := cpiPeriodInflation(levels[:5], Monthly, Yearly); err == nil {
+		t.Fatalf("expected too short a series to fail")
+	}
+}
+
+func Test_PercentileBands_SummarizePaths(t *testing.T) {
+	paths := []*SimulationResult{}
+	for _, v := range []float64{1, 2, 3, 4, 5} {
+		paths = append(paths, &SimulationResult{PathValues: []float64{100, 100 * v}})
+	}
+
+	bands := GetPercentileBands(paths, []float64{50, 0})
+	e.AssertAreEqual(t, "sorted percentiles", 0.0, bands.Percentiles[0])
+	e.AssertAreEqual(t, "minimum", 100.0, bands.Nominal[0][1])
+	e.AssertAreEqual(t, "median", 300.0, bands.Nominal[1][1])
+	e.AssertAreEqual(t, "no real bands without inflation", true, bands.Real == nil)
+}
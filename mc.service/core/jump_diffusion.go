@@ -0,0 +1,176 @@
+package core
+
+import (
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/stat"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// where an asset's jump parameters came from
+const (
+	JumpSourceAsset      = "asset"      // supplied for the asset
+	JumpSourceCommon     = "common"     // the request's common parameters
+	JumpSourceCalibrated = "calibrated" // estimated from the tails of the asset's returns
+)
+
+const DefaultJumpThreshold = 4.0 // robust sigmas from the median before a historical return counts as a jump
+
+// JumpParameters describe Merton jumps, the number per year is Poisson with mean Intensity and each
+// jump adds a normally distributed log return with Mean and StdDev
+type JumpParameters struct {
+	Intensity float64 `json:"intensity"`
+	Mean      float64 `json:"mean"`
+	StdDev    float64 `json:"stddev"`
+}
+
+// JumpConfig turns on the Merton jump-diffusion mode. Parameters are picked per asset from Assets by
+// ticker, then Common, then calibrated from history when Calibrate is set, otherwise the asset does not jump.
+type JumpConfig struct {
+	Common    *JumpParameters           `json:"common"`
+	Assets    map[string]JumpParameters `json:"assets"`
+	Calibrate bool                      `json:"calibrate"`
+	Threshold float64                   `json:"threshold"` // calibration cut off in robust sigmas, zero uses DefaultJumpThreshold
+	Systemic  bool                      `json:"systemic"`  // jumps arrive on a shared clock so crashes hit the assets together
+}
+
+// AssetJumpModel is the resolved jump model of one asset
+type AssetJumpModel struct {
+	Id     int32  `json:"id"`
+	Ticker string `json:"ticker"`
+	JumpParameters
+	Source string `json:"source"`
+}
+
+func (jc *JumpConfig) Validate() error {
+	if jc.Common == nil && len(jc.Assets) == 0 && !jc.Calibrate {
+		return fmt.Errorf("jumps need common or per asset parameters, or calibrate")
+	}
+	if jc.Threshold < 0 {
+		return fmt.Errorf("jump threshold must be positive, got %v", jc.Threshold)
+	}
+
+	check := func(name string, p JumpParameters) error {
+		if p.Intensity < 0 || p.StdDev < 0 {
+			return fmt.Errorf("%s jump intensity and standard deviation cannot be negative", name)
+		}
+		return nil
+	}
+	if jc.Common != nil {
+		if err := check("common", *jc.Common); err != nil {
+			return err
+		}
+	}
+	for ticker, p := range jc.Assets {
+		if err := check(ticker, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetJumpModels resolves every asset's jump parameters
+func GetJumpModels(config *JumpConfig, seriesReturns []*SeriesReturns) []*AssetJumpModel {
+	threshold := config.Threshold
+	if threshold == 0 {
+		threshold = DefaultJumpThreshold
+	}
+
+	res := make([]*AssetJumpModel, len(seriesReturns))
+	for i, s := range seriesReturns {
+		model := &AssetJumpModel{Id: s.Id, Ticker: s.Ticker}
+		if p, ok := config.Assets[s.Ticker]; ok {
+			model.JumpParameters, model.Source = p, JumpSourceAsset
+		} else if config.Common != nil {
+			model.JumpParameters, model.Source = *config.Common, JumpSourceCommon
+		} else if config.Calibrate {
+			model.JumpParameters, model.Source = CalibrateJumps(s.Returns, s.AnnualizationFactor, threshold), JumpSourceCalibrated
+		}
+		res[i] = model
+	}
+	return res
+}
+
+// CalibrateJumps treats returns more than threshold robust sigmas from the median as jumps, the
+// intensity is how often they happen per year and the size is their spread around the median
+func CalibrateJumps(returns []float64, annualizationFactor int, threshold float64) JumpParameters {
+	if len(returns) == 0 {
+		return JumpParameters{}
+	}
+
+	median, sigma := robustLocationScale(returns)
+	jumps := []float64{}
+	for _, r := range returns {
+		if math.Abs(r-median) > threshold*sigma {
+			jumps = append(jumps, r-median)
+		}
+	}
+	if len(jumps) == 0 {
+		return JumpParameters{}
+	}
+
+	res := JumpParameters{
+		Intensity: float64(len(jumps)) / float64(len(returns)) * float64(annualizationFactor),
+		Mean:      stat.Mean(jumps, nil),
+	}
+	if len(jumps) > 1 {
+		res.StdDev = stat.StdDev(jumps, nil)
+	}
+	return res
+}
+
+// compensator is the drift taken out each period so jumps do not change the expected arithmetic return
+func (p JumpParameters) compensator(simulationUnitOfTime int) float64 {
+	return p.Intensity / float64(simulationUnitOfTime) * (math.Exp(p.Mean+0.5*p.StdDev*p.StdDev) - 1)
+}
+
+// addJumps draws this period's jumps onto the diffusion returns and counts them for the path diagnostics
+func (wr *WorkerResource) addJumps(returns []float64, simulationUnitOfTime int) {
+	if wr.jumpCounts == nil {
+		wr.jumpCounts = make([]int, len(wr.Jumps))
+	}
+	normalDist := distuv.Normal{Mu: 0, Sigma: 1, Src: wr.rng}
+
+	// a shared event clock runs at the largest asset intensity and each asset joins an event with
+	// probability intensity / rate, so every asset still jumps at its own intensity
+	rate, events := 0.0, 0
+	if wr.SystemicJumps {
+		for _, j := range wr.Jumps {
+			rate = math.Max(rate, j.Intensity)
+		}
+		events = poissonDraw(rate/float64(simulationUnitOfTime), wr)
+	}
+
+	for i, j := range wr.Jumps {
+		returns[i] -= j.compensator(simulationUnitOfTime)
+		if j.Intensity == 0 {
+			continue
+		}
+
+		n := 0
+		if wr.SystemicJumps {
+			uniform := distuv.Uniform{Min: 0, Max: 1, Src: wr.rng}
+			for range events {
+				if uniform.Rand() < j.Intensity/rate {
+					n++
+				}
+			}
+		} else {
+			n = poissonDraw(j.Intensity/float64(simulationUnitOfTime), wr)
+		}
+		if n == 0 {
+			continue
+		}
+
+		returns[i] += float64(n)*j.Mean + math.Sqrt(float64(n))*j.StdDev*normalDist.Rand()
+		wr.jumpCounts[i] += n
+	}
+}
+
+func poissonDraw(lambda float64, wr *WorkerResource) int {
+	if lambda <= 0 {
+		return 0
+	}
+	return int(distuv.Poisson{Lambda: lambda, Src: wr.rng}.Rand())
+}
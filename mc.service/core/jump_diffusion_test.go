@@ -0,0 +1,94 @@
+package core
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+
+	e "mc.data/extensions"
+)
+
+func Test_JumpDiffusion_CalibratesFromTails(t *testing.T) {
+	rng := rand.New(rand.NewPCG(3, 4))
+	returns := make([]float64, 52*20)
+	for i := range returns {
+		returns[i] = 0.002 + 0.02*rng.NormFloat64()
+	}
+	for _, i := range []int{100, 300, 550, 700, 900} { // one crash every four years
+		returns[i] = -0.25
+	}
+
+	p := CalibrateJumps(returns, Weekly, DefaultJumpThreshold)
+	e.AssertAreEqual(t, "intensity", true, math.Abs(p.Intensity-0.25) < 0.06)
+	e.AssertAreEqual(t, "mean", true, math.Abs(p.Mean+0.25) < 0.02)
+
+	e.AssertAreEqual(t, "no tails no jumps", 0.0, CalibrateJumps(returns[:90], Weekly, DefaultJumpThreshold).Intensity)
+}
+
+func Test_JumpDiffusion_ResolvesParameters(t *testing.T) {
+	series := generateMockSeriesReturns(t, Daily)
+	config := &JumpConfig{
+		Common: &JumpParameters{Intensity: 1, Mean: -0.05, StdDev: 0.02},
+		Assets: map[string]JumpParameters{series[1].Ticker: {Intensity: 0.5, Mean: -0.1}},
+	}
+
+	models := GetJumpModels(config, series)
+	e.AssertAreEqual(t, "common", JumpSourceCommon, models[0].Source)
+	e.AssertAreEqual(t, "asset", JumpSourceAsset, models[1].Source)
+	e.AssertAreEqual(t, "asset intensity", 0.5, models[1].Intensity)
+
+	if err := (&JumpConfig{}).Validate(); err == nil {
+		t.Fatalf("expected jumps without parameters to be rejected")
+	}
+	if err := (&JumpConfig{Common: &JumpParameters{Intensity: -1}}).Validate(); err == nil {
+		t.Fatalf("expected a negative intensity to be rejected")
+	}
+}
+
+func Test_JumpDiffusion_SimulatedJumpsMatchIntensity(t *testing.T) {
+	series := generateMockSeriesReturns(t, Daily*2)
+
+	for _, systemic := range []bool{false, true} {
+		request := SimulationRequest{Jumps: &JumpConfig{
+			Common:   &JumpParameters{Intensity: 2, Mean: -0.08, StdDev: 0.03},
+			Assets:   map[string]JumpParameters{series[2].Ticker: {Intensity: 0.5, Mean: -0.08, StdDev: 0.03}},
+			Systemic: systemic,
+		}}
+		sr, err := GetStatisticalResources(request, series)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		worker := NewWorkerResources(sr, 42, 0)
+		worker.StartPath()
+		years := 2000
+		for range years * Monthly {
+			worker.GetCorrelatedReturns(Monthly)
+		}
+
+		e.AssertAreEqual(t, "common intensity", true, math.Abs(float64(worker.jumpCounts[0])/float64(years)-2) < 0.15)
+		e.AssertAreEqual(t, "asset intensity", true, math.Abs(float64(worker.jumpCounts[2])/float64(years)-0.5) < 0.08)
+		if systemic {
+			// the first two assets share a clock at the same rate so they always jump together
+			e.AssertAreEqual(t, "systemic jumps together", worker.jumpCounts[0], worker.jumpCounts[1])
+		}
+	}
+}
+
+func Test_JumpDiffusion_CompensatorKeepsExpectedReturn(t *testing.T) {
+	series := generateMockSeriesReturns(t, Daily*2)
+	plain, _ := GetStatisticalResources(SimulationRequest{}, series)
+	jumpy, _ := GetStatisticalResources(SimulationRequest{Jumps: &JumpConfig{Common: &JumpParameters{Intensity: 1, Mean: -0.1, StdDev: 0.05}}}, series)
+
+	growth := func(sr *StatisticalResources) float64 {
+		worker := NewWorkerResources(sr, 7, 0)
+		total := 0.0
+		n := 200_000
+		for range n {
+			total += math.Exp(worker.GetCorrelatedReturns(Yearly)[0])
+		}
+		return total / float64(n)
+	}
+
+	e.AssertAreEqual(t, "expected growth unchanged", true, math.Abs(growth(plain)-growth(jumpy)) < 0.01)
+}
@@ -6,14 +6,18 @@ import (
 	"maps"
 	"math"
 	"slices"
+	"sync"
 	"time"
 
 	ex "mc.data/extensions"
+	m "mc.data/models"
 )
 
 const (
 	Workers   = 8
 	BatchSize = 10_000
+
+	DefaultInitialValue = 100.0
 )
 
 type SimulationAllocation struct {
@@ -33,6 +37,30 @@ type SimulationRequest struct {
 	SimulationUnitOfTime int `json:"simulationunitoftime"` // daily, weekly, monthly, quarterly, yearly
 	SimulationDuration   int `json:"simulationduration"`   // number of units of time to simulate
 	DegreesOfFreedom     int `json:"degreesoffreedom"`     // degrees of freedom for student t distribution
+
+	RequireDataQuality bool              `json:"requiredataquality"` // refuse to simulate when an allocation fails its data quality checks
+	DataQuality        DataQualityConfig `json:"dataquality"`        // thresholds and blocked checks for the check above, zero values use the defaults
+
+	Alignment        string `json:"alignment"`        // truncate (default), drop or pairwise, see AlignSeriesReturns
+	CovarianceRepair string `json:"covariancerepair"` // none (default), clip or higham, see RepairCovariance
+
+	Estimator       string  `json:"estimator"`       // sample (default), ledoitwolf or ewma, see EstimateCovariance
+	ShrinkageTarget string  `json:"shrinkagetarget"` // constantcorrelation (default) or diagonal for ledoitwolf
+	HalfLife        float64 `json:"halflife"`        // ewma half-life in periods of the stored returns, zero uses the RiskMetrics half-life in days
+
+	VolatilityModel string        `json:"volatilitymodel"` // constant (default) or garch, garch steps in the frequency of the stored returns
+	Jumps           *JumpConfig   `json:"jumps"`           // adds merton jumps to the returns, nil leaves them off
+	Regimes         *RegimeConfig `json:"regimes"`         // markov regime switching, steps in the frequency of the stored returns
+
+	InitialValue float64          `json:"initialvalue"` // starting value of every path, zero uses DefaultInitialValue
+	CashFlows    []*CashFlow      `json:"cashflows"`    // contributions and withdrawals, in the units of InitialValue
+	Inflation    *InflationConfig `json:"inflation"`    // simulates a price level so paths are also reported in today's money
+	Percentiles  []float64        `json:"percentiles"`  // bands reported across the paths, empty uses DefaultPercentiles
+	Fees         *FeeConfig       `json:"fees"`         // expense ratios, advisory fees and trading costs charged inside every path
+	Taxes        *TaxConfig       `json:"taxes"`        // taxes every path as a taxable or tax deferred account
+
+	CapitalMarketAssumptions *CapitalMarketAssumptionConfig `json:"capitalmarketassumptions"` // forward looking mu, sigma and correlations over history
+	BlackLitterman           *BlackLittermanConfig          `json:"blacklitterman"`           // replaces the historical mu and covariance with the posterior, nil leaves them
 }
 
 type SeriesReturns struct {
@@ -43,10 +71,39 @@ type SeriesReturns struct {
 }
 
 type SimulationResult struct {
-	FinalValue       float64
-	TotalReturn      float64
-	AnnualizedReturn float64
-	PathValues       []float64
+	FinalValue         float64
+	TotalReturn        float64
+	AnnualizedReturn   float64
+	PathValues         []float64
+	RealFinalValue     float64   // in today's money, only when inflation is simulated
+	RealPathValues     []float64 // PathValues deflated by the simulated price level
+	Inflation          float64   // annualized inflation over the path
+	GrossFinalValue    float64   // FinalValue had no fees or taxes been charged, only when either is simulated
+	Fees               *FeesPaid
+	AfterTaxFinalValue float64 // FinalValue less the tax owed on withdrawing it, only when taxes are simulated
+	Taxes              *TaxesPaid
+	Diagnostics        PathDiagnostics
+}
+
+// PathDiagnostics are the model events behind a single path
+type PathDiagnostics struct {
+	Jumps   []int `json:"jumps,omitempty"`   // jumps per asset, in allocation order
+	Regimes []int `json:"regimes,omitempty"` // periods spent in each regime
+}
+
+type SimulationOutput struct {
+	Window                   *EffectiveWindow               `json:"window"` // history the statistics were estimated from
+	CovarianceEstimate       *CovarianceEstimate            `json:"covarianceestimate"`
+	CovarianceRepair         *CovarianceRepairReport        `json:"covariancerepair"`
+	Garch                    []*GarchFit                    `json:"garch,omitempty"` // fitted per asset when the volatility model is garch
+	Jumps                    []*AssetJumpModel              `json:"jumps,omitempty"`
+	Regimes                  *RegimeModel                   `json:"regimes,omitempty"` // fitted regimes with the simulated occupancy
+	CapitalMarketAssumptions *CapitalMarketAssumptionReport `json:"capitalmarketassumptions,omitempty"`
+	BlackLitterman           *BlackLittermanResult          `json:"blacklitterman,omitempty"`
+	Bands                    *PercentileBands               `json:"bands"`
+	Fees                     *FeeSummary                    `json:"fees,omitempty"`
+	Taxes                    *TaxSummary                    `json:"taxes,omitempty"`
+	Paths                    []*SimulationResult            `json:"paths"`
 }
 
 type job struct {
@@ -73,22 +130,137 @@ func (sr SimulationRequest) Validate() error {
 		return fmt.Errorf("did not recieve a unique asset list")
 	}
 
+	policy, err := normalizeAlignmentPolicy(sr.Alignment)
+	if err != nil {
+		return err
+	}
+
+	estimator, _, err := normalizeEstimator(sr.Estimator, sr.ShrinkageTarget)
+	if err != nil {
+		return err
+	}
+	if policy == AlignPairwise && estimator != EstimatorSample {
+		return fmt.Errorf("the %s estimator needs aligned returns and cannot be used with %s alignment", estimator, AlignPairwise)
+	}
+
+	if _, err := normalizeVolatilityModel(sr.VolatilityModel); err != nil {
+		return err
+	}
+
+	if err := sr.DataQuality.Validate(); err != nil {
+		return err
+	}
+
+	if sr.Jumps != nil {
+		if err := sr.Jumps.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if sr.Regimes != nil {
+		if err := sr.Regimes.Validate(); err != nil {
+			return err
+		}
+		if policy == AlignPairwise {
+			return fmt.Errorf("regime switching needs aligned returns and cannot be used with %s alignment", AlignPairwise)
+		}
+		if volatility, _ := normalizeVolatilityModel(sr.VolatilityModel); volatility != VolatilityConstant {
+			return fmt.Errorf("regime switching sets the volatility per regime and cannot be combined with %s", volatility)
+		}
+	}
+
+	if sr.CapitalMarketAssumptions != nil {
+		if err := sr.CapitalMarketAssumptions.Validate(); err != nil {
+			return err
+		}
+		if sr.Regimes != nil {
+			return fmt.Errorf("regime switching estimates returns per regime and cannot be combined with capital market assumptions")
+		}
+	}
+
+	if sr.BlackLitterman != nil {
+		if err := sr.BlackLitterman.Validate(); err != nil {
+			return err
+		}
+		if sr.Regimes != nil {
+			return fmt.Errorf("regime switching estimates returns per regime and cannot be combined with black-litterman")
+		}
+	}
+
+	if _, err := normalizeRepairMethod(sr.CovarianceRepair); err != nil {
+		return err
+	}
+
+	if sr.InitialValue < 0 {
+		return fmt.Errorf("initial value cannot be negative")
+	}
+	if err := validateCashFlows(sr.CashFlows, sr.SimulationDuration); err != nil {
+		return err
+	}
+	if err := validatePercentiles(sr.Percentiles); err != nil {
+		return err
+	}
+	if sr.Inflation != nil {
+		if err := sr.Inflation.Validate(); err != nil {
+			return err
+		}
+	}
+	if sr.Fees != nil {
+		if err := sr.Fees.Validate(); err != nil {
+			return err
+		}
+	}
+	if sr.Taxes != nil {
+		if err := sr.Taxes.Validate(); err != nil {
+			return err
+		}
+	}
+
 	// anything else we want to validate before kicking off a simulation?
 
 	return nil
 }
 
-func (sc *ServiceContext) RunEquityMonteCarloWithCovarianceMartix(request SimulationRequest) ([]*SimulationResult, error) {
+func (sc *ServiceContext) RunEquityMonteCarloWithCovarianceMartix(request SimulationRequest) (*SimulationOutput, error) {
 	res := make([]*SimulationResult, request.Iterations)
+	output := &SimulationOutput{Paths: res}
+	if request.RequireDataQuality {
+		if err := sc.verifyAllocationQuality(request.Allocations, request.DataQuality); err != nil {
+			return output, err
+		}
+	}
+
 	seriesReturns, err := sc.getSeriesReturns(request)
 	if err != nil {
-		return res, err
+		return output, err
+	}
+
+	seriesReturns, output.Window, err = AlignSeriesReturns(request.Alignment, request.Allocations, seriesReturns)
+	if err != nil {
+		return output, err
+	}
+
+	if err := sc.loadCapitalMarketAssumptions(request); err != nil {
+		return output, err
+	}
+	if err := sc.loadInflationHistory(request); err != nil {
+		return output, err
+	}
+	if err := sc.loadDividendYields(request, output.Window); err != nil {
+		return output, err
 	}
 
 	statisticalResources, err := GetStatisticalResources(request, seriesReturns)
 	if err != nil {
-		return res, err
+		return output, err
 	}
+	output.CovarianceEstimate = statisticalResources.CovarianceEstimate
+	output.CovarianceRepair = statisticalResources.CovarianceRepair
+	output.Garch = statisticalResources.Garch
+	output.Jumps = statisticalResources.Jumps
+	output.Regimes = statisticalResources.Regimes
+	output.CapitalMarketAssumptions = statisticalResources.CapitalMarketAssumptions
+	output.BlackLitterman = statisticalResources.BlackLitterman
 
 	nJobs := int(math.Ceil(float64(request.Iterations) / BatchSize / Workers))
 	if nJobs == 0 && request.Iterations > 0 {
@@ -98,6 +270,7 @@ func (sc *ServiceContext) RunEquityMonteCarloWithCovarianceMartix(request Simula
 	log.Println("Starting monte carlo simulation:")
 	log.Printf("\t Simulation duration: %v %s", request.SimulationDuration, convertFrequencyToString(request.SimulationUnitOfTime))
 	log.Printf("\t Simulation paths: %v", request.Iterations)
+	log.Printf("\t History: %s to %s, %v observations (%s)", ex.FmtShort(output.Window.Start), ex.FmtShort(output.Window.End), output.Window.Observations, output.Window.Policy)
 	log.Printf("\t Simulation batch size: %v", BatchSize)
 	log.Printf("\t Workers: %v", Workers)
 
@@ -110,37 +283,20 @@ func (sc *ServiceContext) RunEquityMonteCarloWithCovarianceMartix(request Simula
 	jobs := make(chan job, nJobs) // TODO: if njobs is less than workers, take the minimum
 	done := make(chan bool, ex.Min(nJobs, Workers))
 
+	var workerErr error
+	var errOnce sync.Once
 	worker := func(wr *WorkerResource) {
+		defer func() { done <- true }()
 		for j := range jobs { // this will loop over available jobs, and will reup if a job finishes and there are more jobs
 			for sim := j.start; sim < j.end; sim++ { // this will loop over the iterations
-				portfolioValue := 100.0
-				pathValues := make([]float64, request.Iterations+1)
-				pathValues[0] = portfolioValue
-
-				for period := range request.Iterations {
-					correlatedReturns := wr.GetCorrelatedReturns(request.SimulationUnitOfTime)
-					portfolioReturn, err := ex.DotProduct(statisticalResources.AssetWeight, correlatedReturns)
-					if err != nil {
-						return // TODO: how to handle errors in channels?
-					}
-
-					portfolioValue *= math.Exp(portfolioReturn)
-					pathValues[period+1] = portfolioValue
-				}
-
-				totalReturn := portfolioValue - 1.0
-				fullDurationAnnualizationFactor := float64(request.SimulationDuration) / float64(request.SimulationUnitOfTime)
-				annualizedReturn := math.Pow(portfolioValue, fullDurationAnnualizationFactor) - 1.0
-
-				res[sim] = &SimulationResult{
-					FinalValue:       portfolioValue,
-					TotalReturn:      totalReturn,
-					AnnualizedReturn: annualizedReturn,
-					PathValues:       pathValues,
+				path, err := wr.simulatePath(request)
+				if err != nil {
+					errOnce.Do(func() { workerErr = err })
+					return
 				}
+				res[sim] = path
 			}
 		}
-		done <- true
 	}
 
 	// starts the workers
@@ -162,29 +318,153 @@ func (sc *ServiceContext) RunEquityMonteCarloWithCovarianceMartix(request Simula
 	for range workerCount {
 		<-done
 	}
+	if workerErr != nil {
+		return output, workerErr
+	}
+
+	output.Bands = GetPercentileBands(res, request.Percentiles)
+	output.Fees = GetFeeSummary(res)
+	output.Taxes = GetTaxSummary(res)
+
+	if output.Regimes != nil {
+		output.Regimes.Occupancy = regimeOccupancy(res, len(output.Regimes.States))
+	}
+
+	return output, nil
+}
+
+// simulatePath runs one path. Each period the value moves with the portfolio return, then that period's
+// cash flows land, withdrawals can take it to zero but not below. With fees or taxes the path holds each
+// asset separately, both come out of the holdings and the rebalance rule trades them back to target.
+func (wr *WorkerResource) simulatePath(request SimulationRequest) (*SimulationResult, error) {
+	initial := request.InitialValue
+	if initial == 0 {
+		initial = DefaultInitialValue
+	}
+
+	portfolioValue := initial
+	pathValues := make([]float64, request.SimulationDuration+1)
+	pathValues[0] = portfolioValue
+
+	priceIndex := 1.0
+	var realValues []float64
+	if wr.Inflation != nil {
+		realValues = make([]float64, request.SimulationDuration+1)
+		realValues[0] = portfolioValue
+	}
+
+	var holdings *pathHoldings
+	if wr.Fees != nil || wr.Taxes != nil {
+		holdings = startHoldings(wr.Fees, wr.Taxes, initial, wr.AssetWeight)
+	}
 
+	wr.StartPath()
+	for period := range request.SimulationDuration {
+		correlatedReturns := wr.GetCorrelatedReturns(request.SimulationUnitOfTime)
+		if holdings != nil {
+			portfolioValue = holdings.grow(correlatedReturns)
+		} else {
+			portfolioValue *= periodGrowth(wr.AssetWeight, correlatedReturns)
+		}
+
+		if wr.Inflation != nil {
+			priceIndex *= math.Exp(wr.drawInflation(correlatedReturns, request.SimulationUnitOfTime))
+		}
+		cashFlow := cashFlowAt(request.CashFlows, period, priceIndex)
+		if holdings != nil {
+			portfolioValue = holdings.settle(period, cashFlow)
+		} else {
+			portfolioValue = math.Max(portfolioValue+cashFlow, 0)
+		}
+
+		pathValues[period+1] = portfolioValue
+		if realValues != nil {
+			realValues[period+1] = portfolioValue / priceIndex
+		}
+	}
+
+	res := &SimulationResult{
+		FinalValue:     portfolioValue,
+		TotalReturn:    portfolioValue/initial - 1,
+		PathValues:     pathValues,
+		RealPathValues: realValues,
+		Diagnostics:    PathDiagnostics{Jumps: wr.jumpCounts, Regimes: wr.regimePeriods},
+	}
+
+	years := float64(request.SimulationDuration) / float64(request.SimulationUnitOfTime)
+	if years > 0 {
+		res.AnnualizedReturn = math.Pow(portfolioValue/initial, 1/years) - 1
+		if realValues != nil {
+			res.Inflation = math.Pow(priceIndex, 1/years) - 1
+		}
+	}
+	if realValues != nil {
+		res.RealFinalValue = portfolioValue / priceIndex
+	}
+	if holdings != nil {
+		fees, taxes, afterTax := holdings.finish(initial, years)
+		res.GrossFinalValue = sumHoldings(holdings.gross)
+		if wr.Fees != nil {
+			res.Fees = fees
+		}
+		if wr.Taxes != nil {
+			res.Taxes = taxes
+			res.AfterTaxFinalValue = afterTax
+		}
+	}
 	return res, nil
 }
 
+// periodGrowth is Σwᵢexp(rᵢ), the growth of a portfolio at the target weights over one period of log
+// returns. It matches holdings that rebalance every period, the weighted log return exp(w·r) does not.
+func periodGrowth(weights, returns []float64) float64 {
+	res := 0.0
+	for i, w := range weights {
+		res += w * math.Exp(returns[i])
+	}
+	return res
+}
+
 func (sc *ServiceContext) getSeriesReturns(request SimulationRequest) (res []*SeriesReturns, err error) {
-	tickerLookup := make(map[int32]SimulationAllocation, len(request.Allocations))
-	for _, allocation := range request.Allocations {
-		tickerLookup[allocation.Id] = allocation
+	ids := make([]int32, len(request.Allocations))
+	annualizationFactors := make(map[int32]int, len(request.Allocations))
+	for i, allocation := range request.Allocations {
+		ids[i] = allocation.Id
+
+		// returns step in the frequency the series was stored at
+		md, err := sc.PostgresConnection.GetMetaDataById(sc.Context, allocation.Id)
+		if err != nil {
+			return res, err
+		}
+		if md == nil {
+			return res, fmt.Errorf("no stored series for allocation %d", allocation.Id)
+		}
+		annualizationFactors[allocation.Id] = FrequencyAnnualizationFactor(md.Frequency)
 	}
 
-	returns, err := sc.PostgresConnection.GetTimeSeriesReturns(sc.Context, slices.Collect(maps.Keys(tickerLookup)), request.MaxLookback)
+	returns, err := sc.PostgresConnection.GetTimeSeriesReturns(sc.Context, ids, request.MaxLookback)
 	if err != nil {
 		return res, fmt.Errorf("error getting time series returns: %v", err)
 	}
 
-	agg := make(map[int32]*SeriesReturns, len(request.Allocations))
+	return groupSeriesReturns(request.Allocations, returns, annualizationFactors), nil
+}
+
+// groupSeriesReturns collects the returns of each allocation, sorted on source id
+func groupSeriesReturns(allocations []SimulationAllocation, returns []*m.TimeSeriesReturn, annualizationFactors map[int32]int) (res []*SeriesReturns) {
+	tickerLookup := make(map[int32]SimulationAllocation, len(allocations))
+	for _, allocation := range allocations {
+		tickerLookup[allocation.Id] = allocation
+	}
+
+	agg := make(map[int32]*SeriesReturns, len(allocations))
 	for _, ret := range returns {
 		if agg[ret.Id] == nil {
 			agg[ret.Id] = &SeriesReturns{
 				SimulationAllocation: tickerLookup[ret.Id],
 				Returns:              []float64{},
 				Dates:                []time.Time{},
-				AnnualizationFactor:  Weekly,
+				AnnualizationFactor:  annualizationFactors[ret.Id],
 			}
 		}
 
@@ -201,50 +481,5 @@ func (sc *ServiceContext) getSeriesReturns(request SimulationRequest) (res []*Se
 		return int(i.Id - j.Id)
 	})
 
-	err = verifySeriesReturnIntegrity(res)
 	return
 }
-
-func verifySeriesReturnIntegrity(data []*SeriesReturns) error {
-	firstDates := make([]time.Time, len(data))
-	lastDates := make([]time.Time, len(data))
-	lengths := make([]int, len(data))
-	for _, v := range data {
-		first, last, length := getTimeRange(v)
-		firstDates = append(firstDates, first)
-		lastDates = append(lastDates, last)
-		lengths = append(lengths, length)
-	}
-
-	if ex.AreAllEqual(firstDates) {
-		return fmt.Errorf("data validation failed, first dates in range do not align")
-	}
-
-	if ex.AreAllEqual(lastDates) {
-		return fmt.Errorf("data validation failed, last dates in range do not align")
-	}
-
-	if ex.AreAllEqual(lengths) {
-		return fmt.Errorf("data validation failed, length of dates in range do not align")
-	}
-
-	return nil
-}
-
-func getTimeRange(data *SeriesReturns) (time.Time, time.Time, int) {
-	// i dont think we need to keep the dates in the same order... but i dont want to find out
-	dates := slices.Clone(data.Dates)
-	slices.SortFunc(dates, func(i, j time.Time) int {
-		return i.Compare(j)
-	})
-
-	first := dates[0]
-	length := len(dates)
-	last := dates[length-1]
-
-	if last.Before(first) {
-		log.Println("you dummy you missed the multipler in getTimeRange() sort compare")
-	}
-
-	return first, last, length
-}
@@ -0,0 +1,48 @@
+package core
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	e "mc.data/extensions"
+	m "mc.data/models"
+)
+
+func Test_Simulation_PathsRunForTheDuration(t *testing.T) {
+	sr, err := GetStatisticalResources(SimulationRequest{DistType: StandardNormal}, generateMockSeriesReturns(t, Daily*2))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// iterations count the paths, not their periods
+	request := SimulationRequest{Iterations: 3, SimulationUnitOfTime: Yearly, SimulationDuration: 10}
+	res, err := NewWorkerResources(sr, 42, 0).simulatePath(request)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	e.AssertAreEqual(t, "path values", request.SimulationDuration+1, len(res.PathValues))
+	e.AssertAreEqual(t, "final value", res.PathValues[request.SimulationDuration], res.FinalValue)
+}
+
+func Test_Simulation_ReturnsKeepTheStoredFrequency(t *testing.T) {
+	start := time.Date(2020, time.January, 31, 0, 0, 0, 0, time.UTC)
+	returns := []*m.TimeSeriesReturn{}
+	for i := range 36 {
+		returns = append(returns, &m.TimeSeriesReturn{Id: 2, Timestamp: start.AddDate(0, i, 0), LogReturn: 0.01 * float64(i%3-1)})
+		returns = append(returns, &m.TimeSeriesReturn{Id: 1, Timestamp: start.AddDate(0, 0, 7*i), LogReturn: 0.005 * float64(i%2)})
+	}
+	allocations := []SimulationAllocation{{Id: 1, Ticker: "SPY", Weight: 0.5}, {Id: 2, Ticker: "VNQ", Weight: 0.5}}
+
+	res := groupSeriesReturns(allocations, returns, map[int32]int{1: Weekly, 2: Monthly})
+	e.AssertAreEqual(t, "weekly", Weekly, res[0].AnnualizationFactor)
+	e.AssertAreEqual(t, "monthly", Monthly, res[1].AnnualizationFactor)
+	e.AssertAreEqual(t, "returns", 36, len(res[1].Returns))
+
+	// garch steps in the stored frequency, so the monthly series cannot drive a weekly simulation
+	request := SimulationRequest{VolatilityModel: VolatilityGarch, SimulationUnitOfTime: Weekly}
+	_, err := GetStatisticalResources(request, res)
+	if err == nil || !strings.Contains(err.Error(), "VNQ has months") {
+		t.Fatalf("expected a monthly series in a weekly garch simulation to fail, got %v", err)
+	}
+}
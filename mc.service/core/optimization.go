@@ -0,0 +1,558 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+const (
+	DefaultFrontierPoints = 20
+	maxFrontierPoints     = 100
+
+	maxOptimizerIterations  = 20_000
+	maxProjectionIterations = 2_000
+	optimizerTolerance      = 1e-10
+	constraintTolerance     = 1e-6
+	goldenSectionIterations = 40
+)
+
+// EstimationSettings are the SimulationRequest fields that decide how mu and the covariance matrix are
+// estimated, shared by the requests that only need the statistics
+type EstimationSettings struct {
+	MaxLookback      time.Duration `json:"maxlookback"`
+	Alignment        string        `json:"alignment"`
+	CovarianceRepair string        `json:"covariancerepair"`
+	Estimator        string        `json:"estimator"`
+	ShrinkageTarget  string        `json:"shrinkagetarget"`
+	HalfLife         float64       `json:"halflife"`
+
+	CapitalMarketAssumptions *CapitalMarketAssumptionConfig `json:"capitalmarketassumptions"`
+	BlackLitterman           *BlackLittermanConfig          `json:"blacklitterman"` // optimize on the posterior returns instead of the historical ones
+}
+
+// OptimizationRequest picks weights for the allocations, their own weights are ignored
+type OptimizationRequest struct {
+	Allocations []SimulationAllocation `json:"allocations"`
+	EstimationSettings
+
+	Constraints    WeightConstraints `json:"constraints"`
+	RiskFreeRate   float64           `json:"riskfreerate"`   // annual, used for the maximum sharpe portfolio
+	FrontierPoints int               `json:"frontierpoints"` // zero uses DefaultFrontierPoints
+}
+
+// WeightConstraints bound the optimized weights, which always sum to one. Long only unless AllowShort is set.
+type WeightConstraints struct {
+	AllowShort bool                   `json:"allowshort"`
+	MinWeight  float64                `json:"minweight"` // per asset floor, zero is 0 long only and -MaxWeight when shorting
+	MaxWeight  float64                `json:"maxweight"` // per asset cap, zero uses 1
+	Bounds     map[string]WeightBound `json:"bounds"`    // per ticker overrides of the floor and cap
+	Groups     []*WeightGroup         `json:"groups"`
+}
+
+// WeightBound limits a single asset, a zero Max falls back to the constraints' cap
+type WeightBound struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+// WeightGroup limits the total weight of several assets, e.g. an asset class. A zero Max leaves it uncapped.
+type WeightGroup struct {
+	Name    string   `json:"name"`
+	Tickers []string `json:"tickers"`
+	Min     float64  `json:"min"`
+	Max     float64  `json:"max"`
+}
+
+// OptimizedPortfolio is one solution, Allocations can be sent as a SimulationRequest's allocations as is
+type OptimizedPortfolio struct {
+	Return      float64                `json:"return"`     // annualized
+	Volatility  float64                `json:"volatility"` // annualized
+	Sharpe      float64                `json:"sharpe"`
+	Allocations []SimulationAllocation `json:"allocations"`
+}
+
+type OptimizationResult struct {
+	Window             *EffectiveWindow        `json:"window"`
+	CovarianceEstimate *CovarianceEstimate     `json:"covarianceestimate"`
+	CovarianceRepair   *CovarianceRepairReport `json:"covariancerepair"`
+	MinimumVariance    *OptimizedPortfolio     `json:"minimumvariance"`
+	MaximumSharpe      *OptimizedPortfolio     `json:"maximumsharpe"`
+	Frontier           []*OptimizedPortfolio   `json:"frontier"` // ascending in return from the minimum variance portfolio
+}
+
+// simulationRequest carries the settings over to a request for the allocations so the usual validation applies
+func (es EstimationSettings) simulationRequest(allocations []SimulationAllocation) SimulationRequest {
+	return SimulationRequest{
+		Allocations:              allocations,
+		MaxLookback:              es.MaxLookback,
+		Alignment:                es.Alignment,
+		CovarianceRepair:         es.CovarianceRepair,
+		Estimator:                es.Estimator,
+		ShrinkageTarget:          es.ShrinkageTarget,
+		HalfLife:                 es.HalfLife,
+		CapitalMarketAssumptions: es.CapitalMarketAssumptions,
+		BlackLitterman:           es.BlackLitterman,
+	}
+}
+
+// simulationRequest weights the assets equally, the optimizer ignores the weights
+func (or OptimizationRequest) simulationRequest() SimulationRequest {
+	allocations := slices.Clone(or.Allocations)
+	for i := range allocations {
+		allocations[i].Weight = 1 / float64(len(allocations))
+	}
+	return or.EstimationSettings.simulationRequest(allocations)
+}
+
+func (or OptimizationRequest) Validate() error {
+	if len(or.Allocations) == 0 {
+		return fmt.Errorf("at least one allocation is required")
+	}
+	if or.FrontierPoints < 0 || or.FrontierPoints > maxFrontierPoints {
+		return fmt.Errorf("frontier points must be between 1 and %d", maxFrontierPoints)
+	}
+	if err := or.simulationRequest().Validate(); err != nil {
+		return err
+	}
+
+	c := or.Constraints
+	if c.MaxWeight < 0 || (!c.AllowShort && c.MinWeight < 0) {
+		return fmt.Errorf("weights cannot be negative without allowing shorts")
+	}
+	for ticker, b := range c.Bounds {
+		if !c.AllowShort && b.Min < 0 {
+			return fmt.Errorf("%s cannot have a negative floor without allowing shorts", ticker)
+		}
+		if b.Max != 0 && b.Max < b.Min {
+			return fmt.Errorf("%s has a floor above its cap", ticker)
+		}
+	}
+	for _, g := range c.Groups {
+		if len(g.Tickers) == 0 {
+			return fmt.Errorf("group %s has no tickers", g.Name)
+		}
+		if g.Max != 0 && g.Max < g.Min {
+			return fmt.Errorf("group %s has a floor above its cap", g.Name)
+		}
+	}
+	return nil
+}
+
+// OptimizePortfolio estimates the allocations' statistics and traces the constrained efficient frontier
+func (sc *ServiceContext) OptimizePortfolio(req OptimizationRequest) (*OptimizationResult, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	res := &OptimizationResult{}
+	seriesReturns, sr, window, err := sc.estimateStatistics(req.simulationRequest())
+	if err != nil {
+		return nil, err
+	}
+	res.Window, res.CovarianceEstimate, res.CovarianceRepair = window, sr.CovarianceEstimate, sr.CovarianceRepair
+
+	points := req.FrontierPoints
+	if points == 0 {
+		points = DefaultFrontierPoints
+	}
+	res.MinimumVariance, res.MaximumSharpe, res.Frontier, err = OptimizeMeanVariance(seriesReturns, sr.Mu, annualizedCovariance(sr.CovMatrix, seriesReturns), req.Constraints, req.RiskFreeRate, points)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// estimateStatistics loads and aligns the allocations' returns and builds their statistical resources
+func (sc *ServiceContext) estimateStatistics(request SimulationRequest) ([]*SeriesReturns, *StatisticalResources, *EffectiveWindow, error) {
+	allocations, err := sc.resolveAllocationTickers(request.Allocations)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	request.Allocations = allocations
+
+	seriesReturns, err := sc.getSeriesReturns(request)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	seriesReturns, window, err := AlignSeriesReturns(request.Alignment, request.Allocations, seriesReturns)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if err := sc.loadCapitalMarketAssumptions(request); err != nil {
+		return nil, nil, nil, err
+	}
+
+	sr, err := GetStatisticalResources(request, seriesReturns)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return seriesReturns, sr, window, nil
+}
+
+// resolveAllocationTickers fills in missing tickers from the stored meta data, constraints are keyed by ticker
+func (sc *ServiceContext) resolveAllocationTickers(allocations []SimulationAllocation) ([]SimulationAllocation, error) {
+	res := slices.Clone(allocations)
+	for i, a := range res {
+		if a.Ticker != "" {
+			continue
+		}
+		md, err := sc.PostgresConnection.GetMetaDataById(sc.Context, a.Id)
+		if err != nil {
+			return nil, err
+		}
+		if md == nil {
+			return nil, fmt.Errorf("no stored series for allocation %d", a.Id)
+		}
+		res[i].Ticker = md.Symbol
+	}
+	return res, nil
+}
+
+// annualizedCovariance scales the per period covariance matrix to match the annualized mu
+func annualizedCovariance(cov *mat.SymDense, seriesReturns []*SeriesReturns) *mat.SymDense {
+	n := cov.SymmetricDim()
+	res := mat.NewSymDense(n, nil)
+	for i := range n {
+		for j := i; j < n; j++ {
+			af := math.Sqrt(float64(seriesReturns[i].AnnualizationFactor * seriesReturns[j].AnnualizationFactor))
+			res.SetSym(i, j, cov.At(i, j)*af)
+		}
+	}
+	return res
+}
+
+// OptimizeMeanVariance solves the minimum variance portfolio, the frontier between it and the highest return
+// the constraints allow, and the maximum sharpe portfolio on that frontier. mu and cov are annualized.
+func OptimizeMeanVariance(assets []*SeriesReturns, mu []float64, cov *mat.SymDense, constraints WeightConstraints, riskFreeRate float64, points int) (minVariance, maxSharpe *OptimizedPortfolio, frontier []*OptimizedPortfolio, err error) {
+	set, err := newFeasibleSet(assets, constraints)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	n := len(assets)
+	start := make([]float64, n)
+	for i := range start {
+		start[i] = 1 / float64(n)
+	}
+
+	zero := make([]float64, n)
+	w := minimizeQuadratic(cov, zero, set.project, start)
+	if set.violation(w) > constraintTolerance {
+		return nil, nil, nil, fmt.Errorf("the weight constraints cannot all be met")
+	}
+	minVariance = newOptimizedPortfolio(assets, w, mu, cov, riskFreeRate)
+
+	// the highest return the constraints allow, the tiny ridge picks a single corner when there are ties
+	ridge := mat.NewSymDense(n, nil)
+	negMu := make([]float64, n)
+	for i := range n {
+		ridge.SetSym(i, i, 1e-8)
+		negMu[i] = -mu[i]
+	}
+	highest := dot(mu, minimizeQuadratic(ridge, negMu, set.project, w))
+
+	solve := func(target float64, warm []float64) *OptimizedPortfolio {
+		targetSet := set.withHalfspace(mu, target)
+		return newOptimizedPortfolio(assets, minimizeQuadratic(cov, zero, targetSet.project, warm), mu, cov, riskFreeRate)
+	}
+
+	frontier = []*OptimizedPortfolio{minVariance}
+	targets := []float64{minVariance.Return}
+	if span := highest - minVariance.Return; points > 1 && span > optimizerTolerance {
+		for k := 1; k < points; k++ {
+			target := minVariance.Return + span*float64(k)/float64(points-1)
+			frontier = append(frontier, solve(target, weightsOf(frontier[k-1])))
+			targets = append(targets, target)
+		}
+	}
+
+	// sharpe is unimodal along the frontier, refine between the neighbours of the best point
+	best := 0
+	for k, p := range frontier {
+		if p.Sharpe > frontier[best].Sharpe {
+			best = k
+		}
+	}
+	maxSharpe = frontier[best]
+	if len(frontier) > 2 {
+		lo, hi := targets[max(best-1, 0)], targets[min(best+1, len(targets)-1)]
+		ratio := (math.Sqrt(5) - 1) / 2
+		for range goldenSectionIterations {
+			a, b := hi-ratio*(hi-lo), lo+ratio*(hi-lo)
+			if solve(a, weightsOf(maxSharpe)).Sharpe > solve(b, weightsOf(maxSharpe)).Sharpe {
+				hi = b
+			} else {
+				lo = a
+			}
+		}
+		if p := solve((lo+hi)/2, weightsOf(maxSharpe)); p.Sharpe > maxSharpe.Sharpe {
+			maxSharpe = p
+		}
+	}
+
+	return minVariance, maxSharpe, frontier, nil
+}
+
+func newOptimizedPortfolio(assets []*SeriesReturns, w, mu []float64, cov *mat.SymDense, riskFreeRate float64) *OptimizedPortfolio {
+	res := &OptimizedPortfolio{Return: dot(mu, w), Volatility: math.Sqrt(portfolioVariance(w, cov))}
+	if res.Volatility > 0 {
+		res.Sharpe = (res.Return - riskFreeRate) / res.Volatility
+	}
+	for i, a := range assets {
+		weight := w[i]
+		if math.Abs(weight) < 1e-9 {
+			weight = 0
+		}
+		res.Allocations = append(res.Allocations, SimulationAllocation{Id: a.Id, Ticker: a.Ticker, Weight: weight})
+	}
+	return res
+}
+
+func weightsOf(p *OptimizedPortfolio) []float64 {
+	res := make([]float64, len(p.Allocations))
+	for i, a := range p.Allocations {
+		res[i] = a.Weight
+	}
+	return res
+}
+
+func portfolioVariance(w []float64, cov *mat.SymDense) float64 {
+	x := mat.NewVecDense(len(w), w)
+	return mat.Inner(x, cov, x)
+}
+
+func dot(a, b []float64) float64 {
+	res := 0.0
+	for i := range a {
+		res += a[i] * b[i]
+	}
+	return res
+}
+
+// minimizeQuadratic minimizes 0.5 w'Qw + c'w over a convex set with accelerated projected gradient (FISTA)
+func minimizeQuadratic(q *mat.SymDense, c []float64, project func([]float64) []float64, start []float64) []float64 {
+	n := len(c)
+	var eig mat.EigenSym
+	lipschitz := 1.0
+	if eig.Factorize(q, false) {
+		values := eig.Values(nil)
+		lipschitz = math.Max(values[n-1], 1e-12)
+	}
+	step := 1 / lipschitz
+
+	x := project(start)
+	y := slices.Clone(x)
+	t := 1.0
+	grad := mat.NewVecDense(n, nil)
+	next := make([]float64, n)
+	for range maxOptimizerIterations {
+		grad.MulVec(q, mat.NewVecDense(n, y))
+		for i := range n {
+			next[i] = y[i] - step*(grad.AtVec(i)+c[i])
+		}
+		next = project(next)
+
+		tNext := (1 + math.Sqrt(1+4*t*t)) / 2
+		change := 0.0
+		for i := range n {
+			change = math.Max(change, math.Abs(next[i]-x[i]))
+			y[i] = next[i] + (t-1)/tNext*(next[i]-x[i])
+		}
+		x, next, t = next, x, tNext
+		if change < optimizerTolerance {
+			break
+		}
+	}
+	return x
+}
+
+// halfspace is the set a'w >= b
+type halfspace struct {
+	a []float64
+	b float64
+}
+
+// feasibleSet is the per asset box intersected with the budget (weights sum to one) and any halfspaces
+type feasibleSet struct {
+	lower, upper []float64
+	halfspaces   []halfspace
+}
+
+func newFeasibleSet(assets []*SeriesReturns, c WeightConstraints) (*feasibleSet, error) {
+	maxWeight := c.MaxWeight
+	if maxWeight == 0 {
+		maxWeight = 1
+	}
+	minWeight := c.MinWeight
+	if c.AllowShort && minWeight == 0 {
+		minWeight = -maxWeight
+	}
+
+	n := len(assets)
+	set := &feasibleSet{lower: make([]float64, n), upper: make([]float64, n)}
+	index := make(map[string]int, n)
+	for i, a := range assets {
+		ticker := strings.ToUpper(a.Ticker)
+		index[ticker] = i
+		set.lower[i], set.upper[i] = minWeight, maxWeight
+		for t, b := range c.Bounds {
+			if strings.ToUpper(strings.TrimSpace(t)) != ticker {
+				continue
+			}
+			set.lower[i] = b.Min
+			if b.Max != 0 {
+				set.upper[i] = b.Max
+			}
+		}
+		if set.lower[i] > set.upper[i] {
+			return nil, fmt.Errorf("%s has a floor above its cap", a.Ticker)
+		}
+	}
+
+	lowerSum, upperSum := 0.0, 0.0
+	for i := range n {
+		lowerSum += set.lower[i]
+		upperSum += set.upper[i]
+	}
+	if lowerSum > 1+constraintTolerance || upperSum < 1-constraintTolerance {
+		return nil, fmt.Errorf("the weight bounds do not allow a fully invested portfolio, floors sum to %.4f and caps to %.4f", lowerSum, upperSum)
+	}
+
+	for _, g := range c.Groups {
+		member := make([]float64, n)
+		for _, t := range g.Tickers {
+			i, ok := index[strings.ToUpper(strings.TrimSpace(t))]
+			if !ok {
+				return nil, fmt.Errorf("group %s has %s which is not allocated", g.Name, t)
+			}
+			member[i] = 1
+		}
+		if g.Min != 0 {
+			set.halfspaces = append(set.halfspaces, halfspace{a: member, b: g.Min})
+		}
+		if g.Max != 0 {
+			negated := make([]float64, n)
+			for i, v := range member {
+				negated[i] = -v
+			}
+			set.halfspaces = append(set.halfspaces, halfspace{a: negated, b: -g.Max})
+		}
+	}
+	return set, nil
+}
+
+// withHalfspace is a copy of the set that also requires a'w >= b
+func (fs *feasibleSet) withHalfspace(a []float64, b float64) *feasibleSet {
+	return &feasibleSet{
+		lower:      fs.lower,
+		upper:      fs.upper,
+		halfspaces: append(slices.Clone(fs.halfspaces), halfspace{a: a, b: b}),
+	}
+}
+
+// project is the euclidean projection onto the set, Dykstra's alternating projections when there are halfspaces
+func (fs *feasibleSet) project(v []float64) []float64 {
+	if len(fs.halfspaces) == 0 {
+		return projectBudgetBox(v, fs.lower, fs.upper)
+	}
+
+	n := len(v)
+	x := slices.Clone(v)
+	corrections := make([][]float64, len(fs.halfspaces)+1)
+	for i := range corrections {
+		corrections[i] = make([]float64, n)
+	}
+
+	shifted := make([]float64, n)
+	for range maxProjectionIterations {
+		change := 0.0
+		for k := range corrections {
+			for i := range n {
+				shifted[i] = x[i] + corrections[k][i]
+			}
+
+			var y []float64
+			if k == 0 {
+				y = projectBudgetBox(shifted, fs.lower, fs.upper)
+			} else {
+				y = fs.halfspaces[k-1].project(shifted)
+			}
+
+			for i := range n {
+				corrections[k][i] = shifted[i] - y[i]
+				change = math.Max(change, math.Abs(y[i]-x[i]))
+			}
+			x = y
+		}
+		if change < optimizerTolerance {
+			break
+		}
+	}
+	return x
+}
+
+// violation is the largest amount by which w breaks a constraint
+func (fs *feasibleSet) violation(w []float64) float64 {
+	sum, res := 0.0, 0.0
+	for i, v := range w {
+		sum += v
+		res = math.Max(res, math.Max(fs.lower[i]-v, v-fs.upper[i]))
+	}
+	res = math.Max(res, math.Abs(sum-1))
+	for _, h := range fs.halfspaces {
+		res = math.Max(res, h.b-dot(h.a, w))
+	}
+	return res
+}
+
+func (h halfspace) project(v []float64) []float64 {
+	gap := h.b - dot(h.a, v)
+	if gap <= 0 {
+		return slices.Clone(v)
+	}
+	norm := dot(h.a, h.a)
+	res := make([]float64, len(v))
+	for i := range v {
+		res[i] = v[i] + gap/norm*h.a[i]
+	}
+	return res
+}
+
+// projectBudgetBox projects onto {lower <= w <= upper, sum(w) = 1}, the solution is clip(v - tau) for the
+// tau that makes it sum to one, found by bisection
+func projectBudgetBox(v, lower, upper []float64) []float64 {
+	clipped := func(tau float64) ([]float64, float64) {
+		res, sum := make([]float64, len(v)), 0.0
+		for i := range v {
+			res[i] = math.Min(math.Max(v[i]-tau, lower[i]), upper[i])
+			sum += res[i]
+		}
+		return res, sum
+	}
+
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for i := range v {
+		lo = math.Min(lo, v[i]-upper[i])
+		hi = math.Max(hi, v[i]-lower[i])
+	}
+	for range 200 {
+		mid := (lo + hi) / 2
+		if _, sum := clipped(mid); sum > 1 {
+			lo = mid
+		} else {
+			hi = mid
+		}
+		if hi-lo < 1e-15 {
+			break
+		}
+	}
+	res, _ := clipped((lo + hi) / 2)
+	return res
+}
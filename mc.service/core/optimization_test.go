@@ -0,0 +1,90 @@
+package core
+
+import (
+	"math"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+
+	e "mc.data/extensions"
+)
+
+func optimizationAssets(tickers ...string) []*SeriesReturns {
+	res := []*SeriesReturns{}
+	for i, t := range tickers {
+		res = append(res, &SeriesReturns{SimulationAllocation: SimulationAllocation{Id: int32(i + 1), Ticker: t}, AnnualizationFactor: Weekly})
+	}
+	return res
+}
+
+func Test_Optimization_MatchesClosedFormForUncorrelatedAssets(t *testing.T) {
+	assets := optimizationAssets("BND", "SPY")
+	mu := []float64{0.05, 0.10}
+	cov := mat.NewSymDense(2, []float64{0.01, 0, 0, 0.04})
+
+	minVariance, maxSharpe, frontier, err := OptimizeMeanVariance(assets, mu, cov, WeightConstraints{}, 0, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// inverse variance weights
+	e.AssertAreEqual(t, "min variance bonds", true, math.Abs(minVariance.Allocations[0].Weight-0.8) < 1e-6)
+	// tangency weights are proportional to inverse covariance times mu, (5, 2.5)
+	e.AssertAreEqual(t, "max sharpe bonds", true, math.Abs(maxSharpe.Allocations[0].Weight-2.0/3) < 1e-4)
+	e.AssertAreEqual(t, "max sharpe beats min variance", true, maxSharpe.Sharpe > minVariance.Sharpe)
+
+	e.AssertAreEqual(t, "frontier points", 10, len(frontier))
+	e.AssertAreEqual(t, "frontier ends all in spy", true, math.Abs(frontier[9].Allocations[1].Weight-1) < 1e-6)
+	for k := 1; k < len(frontier); k++ {
+		if frontier[k].Return < frontier[k-1].Return-1e-9 || frontier[k].Volatility < frontier[k-1].Volatility-1e-9 {
+			t.Fatalf("frontier is not increasing at point %d", k)
+		}
+	}
+
+	// the weights are ready for a simulation
+	if err := (SimulationRequest{Allocations: maxSharpe.Allocations}).Validate(); err != nil {
+		t.Fatalf("optimized weights do not validate: %v", err)
+	}
+}
+
+func Test_Optimization_RespectsBoundsAndGroups(t *testing.T) {
+	assets := optimizationAssets("BND", "SPY", "BTC")
+	mu := []float64{0.04, 0.08, 0.30}
+	cov := mat.NewSymDense(3, []float64{
+		0.0025, 0.0005, 0,
+		0.0005, 0.0225, 0.02,
+		0, 0.02, 0.64,
+	})
+	constraints := WeightConstraints{
+		MaxWeight: 0.7,
+		Bounds:    map[string]WeightBound{"btc": {Max: 0.05}},
+		Groups:    []*WeightGroup{{Name: "equity", Tickers: []string{"SPY", "BTC"}, Min: 0.4}},
+	}
+
+	minVariance, maxSharpe, frontier, err := OptimizeMeanVariance(assets, mu, cov, constraints, 0.02, 8)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, p := range append(frontier, minVariance, maxSharpe) {
+		w := weightsOf(p)
+		e.AssertAreEqual(t, "fully invested", true, math.Abs(w[0]+w[1]+w[2]-1) < 1e-6)
+		e.AssertAreEqual(t, "capped", true, w[0] < 0.7+1e-6 && w[1] < 0.7+1e-6 && w[2] < 0.05+1e-6)
+		e.AssertAreEqual(t, "long only", true, w[0] > -1e-9 && w[1] > -1e-9 && w[2] > -1e-9)
+		e.AssertAreEqual(t, "equity floor", true, w[1]+w[2] > 0.4-1e-6)
+	}
+	// bonds want more than the 60% the equity floor leaves them
+	e.AssertAreEqual(t, "bond weight", true, math.Abs(minVariance.Allocations[0].Weight-0.6) < 1e-6)
+
+	constraints.Groups[0].Min = 0.8
+	if _, _, _, err := OptimizeMeanVariance(assets, mu, cov, constraints, 0, 5); err == nil {
+		t.Fatalf("expected an equity floor above the caps to be infeasible")
+	}
+}
+
+func Test_Optimization_ProjectsOntoBudgetBox(t *testing.T) {
+	w := projectBudgetBox([]float64{0.9, 0.6, -0.2}, []float64{0, 0, 0}, []float64{1, 1, 1})
+	e.AssertAreEqual(t, "first", true, math.Abs(w[0]-0.65) < 1e-12)
+	e.AssertAreEqual(t, "second", true, math.Abs(w[1]-0.35) < 1e-12)
+	e.AssertAreEqual(t, "third", 0.0, w[2])
+}
@@ -0,0 +1,66 @@
+package core
+
+import (
+	"fmt"
+	"slices"
+
+	"gonum.org/v1/gonum/stat"
+)
+
+var DefaultPercentiles = []float64{5, 25, 50, 75, 95}
+
+// PercentileBands are the cross sectional percentiles of the path values at every period,
+// Nominal[k][t] is Percentiles[k] at period t
+type PercentileBands struct {
+	Percentiles []float64   `json:"percentiles"`
+	Nominal     [][]float64 `json:"nominal"`
+	Real        [][]float64 `json:"real,omitempty"` // in today's money, only when inflation is simulated
+}
+
+func validatePercentiles(percentiles []float64) error {
+	for _, p := range percentiles {
+		if p < 0 || p > 100 {
+			return fmt.Errorf("percentiles must be between 0 and 100, got %v", p)
+		}
+	}
+	return nil
+}
+
+// GetPercentileBands summarizes the simulated paths, real bands are included when the paths have them
+func GetPercentileBands(paths []*SimulationResult, percentiles []float64) *PercentileBands {
+	if len(percentiles) == 0 {
+		percentiles = DefaultPercentiles
+	}
+	percentiles = slices.Sorted(slices.Values(percentiles))
+
+	res := &PercentileBands{Percentiles: percentiles}
+	if len(paths) == 0 || paths[0] == nil {
+		return res
+	}
+
+	res.Nominal = pathPercentiles(paths, percentiles, func(r *SimulationResult) []float64 { return r.PathValues })
+	if paths[0].RealPathValues != nil {
+		res.Real = pathPercentiles(paths, percentiles, func(r *SimulationResult) []float64 { return r.RealPathValues })
+	}
+	return res
+}
+
+func pathPercentiles(paths []*SimulationResult, percentiles []float64, values func(*SimulationResult) []float64) [][]float64 {
+	periods := len(values(paths[0]))
+	res := make([][]float64, len(percentiles))
+	for k := range res {
+		res[k] = make([]float64, periods)
+	}
+
+	column := make([]float64, len(paths))
+	for t := range periods {
+		for i, p := range paths {
+			column[i] = values(p)[t]
+		}
+		slices.Sort(column)
+		for k, p := range percentiles {
+			res[k][t] = stat.Quantile(p/100, stat.Empirical, column, nil)
+		}
+	}
+	return res
+}
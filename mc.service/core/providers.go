@@ -0,0 +1,59 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	a "mc.service/api"
+	av "mc.service/api/alpha_vantage"
+)
+
+// ProviderConfig selects and configures the market data provider, see env.example
+type ProviderConfig struct {
+	Name                 string // MARKET_DATA_PROVIDER, defaults to alphavantage
+	AlphaVantageApiKey   string
+	AlphaVantageHost     string // optional, e.g. http://localhost:8081 to use cmd/fakeav
+	AlphaVantageDataType string // optional, json or csv
+	CallsPerMinute       int    // quota shared by every caller, 0 for unlimited
+}
+
+// GetProviderConfigFromEnv reads the provider settings documented in env.example
+func GetProviderConfigFromEnv() (ProviderConfig, error) {
+	config := ProviderConfig{
+		Name:                 os.Getenv("MARKET_DATA_PROVIDER"),
+		AlphaVantageApiKey:   os.Getenv("ALPHAVANTAGE_API_KEY"),
+		AlphaVantageHost:     os.Getenv("ALPHAVANTAGE_HOST"),
+		AlphaVantageDataType: os.Getenv("ALPHAVANTAGE_DATATYPE"),
+	}
+
+	// unset keeps a free alpha vantage key inside its quota, set 0 for paid keys or cmd/fakeav
+	switch strings.ToLower(config.Name) {
+	case "", av.ProviderName:
+		config.CallsPerMinute = av.FreeTierCallsPerMinute
+	}
+	if v := os.Getenv("MARKET_DATA_CALLS_PER_MINUTE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return config, fmt.Errorf("MARKET_DATA_CALLS_PER_MINUTE must be a non negative integer, got %s", v)
+		}
+		config.CallsPerMinute = n
+	}
+
+	return config, nil
+}
+
+func GetMarketDataProvider(config ProviderConfig) (a.MarketDataProvider, error) {
+	switch strings.ToLower(config.Name) {
+	case "", av.ProviderName:
+		client := av.GetClientForHost(config.AlphaVantageHost, config.AlphaVantageApiKey)
+		client.DataType = av.DataType(strings.ToLower(config.AlphaVantageDataType))
+		if client.DataType != "" && client.DataType != av.DataTypeJSON && client.DataType != av.DataTypeCSV {
+			return nil, fmt.Errorf("unrecognized alpha vantage data type %s", config.AlphaVantageDataType)
+		}
+		return a.NewRateLimitedProvider(&client, config.CallsPerMinute), nil
+	default:
+		return nil, fmt.Errorf("unrecognized market data provider %s", config.Name)
+	}
+}
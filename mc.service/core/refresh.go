@@ -0,0 +1,229 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	m "mc.data/models"
+)
+
+const (
+	// advisory lock key shared by every instance, "gmcg" in ascii
+	refreshLockKey int64 = 0x676d6367
+
+	maxRefreshRunErrorLength = 2000
+)
+
+// StalenessPolicy is the maximum age of a series' last bar, by frequency, before a refresh syncs it again
+type StalenessPolicy map[string]time.Duration
+
+var DefaultStalenessPolicy = StalenessPolicy{
+	m.FrequencyDaily:   36 * time.Hour,
+	m.FrequencyWeekly:  7 * 24 * time.Hour,
+	m.FrequencyMonthly: 31 * 24 * time.Hour,
+}
+
+// MaxAge falls back to the default policy, and to weekly for unknown frequencies
+func (sp StalenessPolicy) MaxAge(frequency string) time.Duration {
+	if d, ok := sp[frequency]; ok {
+		return d
+	}
+	if d, ok := DefaultStalenessPolicy[frequency]; ok {
+		return d
+	}
+	return sp.MaxAge(m.FrequencyWeekly)
+}
+
+func (sp StalenessPolicy) IsStale(md *m.TimeSeriesMetadata, now time.Time) bool {
+	return !md.LastRefreshed.After(now.Add(-sp.MaxAge(md.Frequency)))
+}
+
+// ParseStalenessPolicy reads "daily=36h,weekly=7d". durations are go durations plus a d suffix for days,
+// frequencies that are not listed keep their default
+func ParseStalenessPolicy(s string) (StalenessPolicy, error) {
+	res := StalenessPolicy{}
+	if strings.TrimSpace(s) == "" {
+		return res, nil
+	}
+
+	for pair := range strings.SplitSeq(s, ",") {
+		frequency, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid staleness entry %q, expected frequency=duration", pair)
+		}
+
+		var d time.Duration
+		var err error
+		if days, isDays := strings.CutSuffix(value, "d"); isDays {
+			var n float64
+			n, err = strconv.ParseFloat(days, 64)
+			d = time.Duration(n * float64(24*time.Hour))
+		} else {
+			d, err = time.ParseDuration(value)
+		}
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid staleness duration %q for %s", value, frequency)
+		}
+
+		res[strings.ToLower(strings.TrimSpace(frequency))] = d
+	}
+
+	return res, nil
+}
+
+// RefreshConfig controls the background refresh, see env.example
+type RefreshConfig struct {
+	Schedule  *CronSchedule // nil disables the scheduler
+	Staleness StalenessPolicy
+	Workers   int
+}
+
+func GetRefreshConfigFromEnv() (RefreshConfig, error) {
+	res := RefreshConfig{Workers: DefaultSyncWorkers}
+
+	if v := os.Getenv("REFRESH_SCHEDULE"); v != "" {
+		schedule, err := ParseCron(v)
+		if err != nil {
+			return res, err
+		}
+		res.Schedule = schedule
+	}
+
+	staleness, err := ParseStalenessPolicy(os.Getenv("REFRESH_STALENESS"))
+	if err != nil {
+		return res, err
+	}
+	res.Staleness = staleness
+
+	if v := os.Getenv("REFRESH_WORKERS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return res, fmt.Errorf("REFRESH_WORKERS must be a positive integer, got %s", v)
+		}
+		res.Workers = n
+	}
+
+	return res, nil
+}
+
+// RunRefreshScheduler refreshes stale symbols on the schedule until ctx is done. runs never overlap
+// in one process, and the advisory lock in RefreshStaleSymbols keeps other instances out
+func (sc *ServiceContext) RunRefreshScheduler(ctx context.Context, config RefreshConfig) {
+	if config.Schedule == nil {
+		return
+	}
+
+	for {
+		next := config.Schedule.Next(time.Now())
+		if next.IsZero() {
+			log.Printf("refresh schedule %s never fires, scheduler stopped", config.Schedule)
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		run, err := sc.RefreshStaleSymbols(m.RefreshTriggerSchedule, config.Workers)
+		if err != nil {
+			log.Printf("scheduled refresh failed: %v", err)
+			continue
+		}
+		log.Printf("scheduled refresh %d %s: %d of %d symbols stale, %d synced, %d failed",
+			run.Id, run.Status, run.Stale, run.SymbolsChecked, run.Synced, run.Failed)
+	}
+}
+
+// RefreshStaleSymbols syncs every stored symbol that is stale under the service's staleness policy.
+// If another instance is already refreshing the run is recorded as skipped
+func (sc *ServiceContext) RefreshStaleSymbols(trigger string, workers int) (*m.RefreshRun, error) {
+	unlock, locked, err := sc.PostgresConnection.TryAdvisoryLock(sc.Context, refreshLockKey)
+	if err != nil {
+		return nil, err
+	}
+	if locked {
+		defer unlock()
+	}
+
+	run := &m.RefreshRun{Trigger: trigger, Status: m.RefreshStatusRunning}
+	if err := sc.PostgresConnection.InsertRefreshRun(sc.Context, run); err != nil {
+		return nil, err
+	}
+
+	// the run row should be closed out even if we are shutting down
+	finishCtx := context.WithoutCancel(sc.Context)
+	finish := func(status string, runErr error) (*m.RefreshRun, error) {
+		run.Status = status
+		if runErr != nil {
+			run.Error = truncate(runErr.Error(), maxRefreshRunErrorLength)
+		}
+		if err := sc.PostgresConnection.FinishRefreshRun(finishCtx, run); err != nil {
+			return run, err
+		}
+		return run, nil
+	}
+
+	if !locked {
+		return finish(m.RefreshStatusSkipped, nil)
+	}
+
+	mds, err := sc.PostgresConnection.GetAllMetaData(sc.Context)
+	if err != nil {
+		return finish(m.RefreshStatusFailed, err)
+	}
+
+	now := time.Now()
+	stale := []string{}
+	for _, md := range mds {
+		if checkProviderSeries(md) != nil {
+			continue // csv imports and daily or monthly series are not the provider's to refresh
+		}
+		if sc.StalenessPolicy.IsStale(md, now) {
+			stale = append(stale, md.Symbol)
+		}
+	}
+
+	run.SymbolsChecked = int32(len(mds))
+	run.Stale = int32(len(stale))
+	if len(stale) == 0 {
+		return finish(m.RefreshStatusCompleted, nil)
+	}
+
+	report, err := sc.SyncSymbols(sc.Context, stale, workers)
+	if err != nil {
+		return finish(m.RefreshStatusFailed, err)
+	}
+
+	run.Synced = int32(report.Synced)
+	run.Fresh = int32(report.Fresh)
+	run.Failed = int32(report.Failed)
+
+	var failures error
+	if report.Failed > 0 {
+		reasons := []string{}
+		for _, r := range report.Results {
+			if r.Status == SyncStatusFailed {
+				reasons = append(reasons, fmt.Sprintf("%s: %s", r.Symbol, r.Error))
+			}
+		}
+		failures = fmt.Errorf("%s", strings.Join(reasons, "; "))
+	}
+
+	return finish(m.RefreshStatusCompleted, failures)
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
@@ -0,0 +1,68 @@
+package core
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	e "mc.data/extensions"
+	m "mc.data/models"
+)
+
+func Test_Refresh_StalenessPolicy(t *testing.T) {
+	policy, err := ParseStalenessPolicy("daily=12h, Weekly=3d")
+	if err != nil {
+		t.Fatalf("error parsing staleness policy: %s", err)
+	}
+
+	e.AssertAreEqual(t, "daily", 12*time.Hour, policy.MaxAge(m.FrequencyDaily))
+	e.AssertAreEqual(t, "weekly", 72*time.Hour, policy.MaxAge(m.FrequencyWeekly))
+	e.AssertAreEqual(t, "monthly keeps the default", DefaultStalenessPolicy[m.FrequencyMonthly], policy.MaxAge(m.FrequencyMonthly))
+	e.AssertAreEqual(t, "unknown falls back to weekly", 72*time.Hour, policy.MaxAge("quarterly"))
+
+	now := time.Date(2025, time.October, 31, 12, 0, 0, 0, time.UTC)
+	md := &m.TimeSeriesMetadata{Frequency: m.FrequencyWeekly, LastRefreshed: now.AddDate(0, 0, -2)}
+	e.AssertAreEqual(t, "two day old weekly", false, policy.IsStale(md, now))
+	md.LastRefreshed = now.AddDate(0, 0, -3)
+	e.AssertAreEqual(t, "three day old weekly", true, policy.IsStale(md, now))
+
+	// a nil policy is the defaults, which keeps the old one week rule
+	var defaults StalenessPolicy
+	md.LastRefreshed = now.AddDate(0, 0, -6)
+	e.AssertAreEqual(t, "six day old weekly by default", false, defaults.IsStale(md, now))
+}
+
+func Test_Refresh_RejectsInvalidStaleness(t *testing.T) {
+	for _, s := range []string{"daily", "daily=soon", "weekly=-1d", "weekly=0h"} {
+		if _, err := ParseStalenessPolicy(s); err == nil {
+			t.Fatalf("expected %q to be rejected", s)
+		}
+	}
+}
+
+func Test_Refresh_InferFrequency(t *testing.T) {
+	bars := func(step int) []*m.TimeSeriesData {
+		res := []*m.TimeSeriesData{}
+		for i := range 10 {
+			res = append(res, &m.TimeSeriesData{Timestamp: time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, i*step)})
+		}
+		return res
+	}
+
+	e.AssertAreEqual(t, "daily", m.FrequencyDaily, inferFrequency(bars(1)))
+	e.AssertAreEqual(t, "weekly", m.FrequencyWeekly, inferFrequency(bars(7)))
+	e.AssertAreEqual(t, "monthly", m.FrequencyMonthly, inferFrequency(bars(30)))
+}
+
+func Test_Refresh_SkipsSeriesTheProviderDoesNotServe(t *testing.T) {
+	md := &m.TimeSeriesMetadata{Symbol: "SPY", Provider: "alphavantage", Frequency: m.FrequencyWeekly}
+	if err := checkProviderSeries(md); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cpi := &m.TimeSeriesMetadata{Symbol: "CPI", Provider: CsvImportProvider, Frequency: m.FrequencyMonthly}
+	e.AssertAreEqual(t, "csv import", true, errors.Is(checkProviderSeries(cpi), ErrNotProviderSeries))
+
+	daily := &m.TimeSeriesMetadata{Symbol: "QQQ", Provider: "alphavantage", Frequency: m.FrequencyDaily}
+	e.AssertAreEqual(t, "daily series", true, errors.Is(checkProviderSeries(daily), ErrNotProviderSeries))
+}
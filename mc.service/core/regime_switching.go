@@ -0,0 +1,406 @@
+package core
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat"
+	"gonum.org/v1/gonum/stat/distuv"
+
+	ex "mc.data/extensions"
+)
+
+const (
+	DefaultRegimeStates        = 2
+	DefaultRegimeMaxIterations = 500
+	regimeTolerance            = 1e-8
+	minRegimeObservations      = 30
+)
+
+// RegimeConfig turns on the markov regime-switching mode, the regimes are fit on the historical
+// portfolio returns and paths step in the frequency of the stored returns
+type RegimeConfig struct {
+	States        int `json:"states"`        // 2 or 3, zero uses DefaultRegimeStates
+	MaxIterations int `json:"maxiterations"` // EM iterations, zero uses DefaultRegimeMaxIterations
+}
+
+// RegimeModel is a gaussian hidden markov model of the portfolio with the asset statistics in each regime
+type RegimeModel struct {
+	States               []*RegimeState `json:"states"`     // ordered from the lowest to the highest mean
+	Transition           [][]float64    `json:"transition"` // transition[i][j] is the chance of moving from regime i to j in a period
+	CurrentProbabilities []float64      `json:"currentprobabilities"`
+	LogLikelihood        float64        `json:"loglikelihood"`
+	Iterations           int            `json:"iterations"`
+	Converged            bool           `json:"converged"`
+	Observations         int            `json:"observations"`
+	Occupancy            []float64      `json:"occupancy"` // share of simulated periods spent in each regime
+
+	assetMu     [][]float64 // per regime, per period asset means
+	assetChol   []*mat.TriDense
+	cumulativeP [][]float64 // cumulative transition rows for drawing the next regime
+	cumulativeC []float64   // cumulative current probabilities for drawing the first regime
+}
+
+// RegimeState summarizes one regime, portfolio figures are per period and asset figures annualized
+type RegimeState struct {
+	Name              string    `json:"name"`
+	PortfolioMean     float64   `json:"portfoliomean"`
+	PortfolioStdDev   float64   `json:"portfoliostddev"`
+	StationaryShare   float64   `json:"stationaryshare"`  // long run share of time in the regime
+	ExpectedDuration  float64   `json:"expectedduration"` // periods, 1 / (1 - p_ii)
+	HistoricalPeriods float64   `json:"historicalperiods"`
+	AssetMu           []float64 `json:"assetmu"`
+	AssetSigma        []float64 `json:"assetsigma"`
+}
+
+func (rc *RegimeConfig) Validate() error {
+	if rc.States != 0 && (rc.States < 2 || rc.States > 3) {
+		return fmt.Errorf("regime switching supports 2 or 3 states, got %d", rc.States)
+	}
+	if rc.MaxIterations < 0 {
+		return fmt.Errorf("regime max iterations cannot be negative")
+	}
+	return nil
+}
+
+// FitRegimeModel fits the hidden markov model by EM (Baum-Welch) on the weighted portfolio returns,
+// then estimates each regime's asset means and covariance weighted by the smoothed regime probabilities.
+// The covariance of each regime goes through the requested repair before its cholesky decomposition.
+func FitRegimeModel(config *RegimeConfig, seriesReturns []*SeriesReturns, weights []float64, repair string) (*RegimeModel, error) {
+	k := config.States
+	if k == 0 {
+		k = DefaultRegimeStates
+	}
+	maxIterations := config.MaxIterations
+	if maxIterations == 0 {
+		maxIterations = DefaultRegimeMaxIterations
+	}
+
+	n := len(seriesReturns)
+	nObs := len(seriesReturns[0].Returns)
+	if nObs < minRegimeObservations*k {
+		return nil, fmt.Errorf("regime switching with %d states needs at least %d aligned returns, got %d", k, minRegimeObservations*k, nObs)
+	}
+
+	portfolio := make([]float64, nObs)
+	for t := range nObs {
+		for i, s := range seriesReturns {
+			portfolio[t] += weights[i] * s.Returns[t]
+		}
+	}
+
+	hmm := newGaussianHmm(portfolio, k)
+	model := &RegimeModel{Observations: nObs}
+	previous := math.Inf(-1)
+	var gamma [][]float64
+	for model.Iterations < maxIterations {
+		model.Iterations++
+		var ll float64
+		gamma, ll = hmm.step(portfolio)
+		model.LogLikelihood = ll
+		if math.Abs(ll-previous) < regimeTolerance {
+			model.Converged = true
+			break
+		}
+		previous = ll
+	}
+
+	// order the regimes from bear to bull so the names mean something
+	order := make([]int, k)
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortFunc(order, func(a, b int) int { return cmp.Compare(hmm.mean[a], hmm.mean[b]) })
+
+	names := map[int][]string{2: {"bear", "bull"}, 3: {"bear", "neutral", "bull"}}[k]
+	model.Transition = make([][]float64, k)
+	model.CurrentProbabilities = make([]float64, k)
+	stationary := stationaryDistribution(hmm.transition)
+	_, filtered := hmm.forward(portfolio)
+	for a, i := range order {
+		model.Transition[a] = make([]float64, k)
+		for b, j := range order {
+			model.Transition[a][b] = hmm.transition[i][j]
+		}
+		model.CurrentProbabilities[a] = filtered[nObs-1][i]
+
+		weight := make([]float64, nObs)
+		for t := range nObs {
+			weight[t] = gamma[t][i]
+		}
+
+		state := &RegimeState{
+			Name:              names[a],
+			PortfolioMean:     hmm.mean[i],
+			PortfolioStdDev:   math.Sqrt(hmm.variance[i]),
+			StationaryShare:   stationary[i],
+			ExpectedDuration:  1 / (1 - hmm.transition[i][i]),
+			HistoricalPeriods: ex.Sum(weight),
+			AssetMu:           make([]float64, n),
+			AssetSigma:        make([]float64, n),
+		}
+
+		mu := make([]float64, n)
+		for c, s := range seriesReturns {
+			mu[c] = stat.Mean(s.Returns, weight)
+		}
+		cov := mat.NewSymDense(n, nil)
+		for a1 := range n {
+			for a2 := range a1 + 1 {
+				v := 0.0
+				for t := range nObs {
+					v += weight[t] * (seriesReturns[a1].Returns[t] - mu[a1]) * (seriesReturns[a2].Returns[t] - mu[a2])
+				}
+				cov.SetSym(a1, a2, v/state.HistoricalPeriods)
+			}
+		}
+
+		cov, _, err := RepairCovariance(cov, repair)
+		if err != nil {
+			return nil, err
+		}
+		chol, err := GetCholeskyDecomposition(cov)
+		if err != nil {
+			return nil, fmt.Errorf("%s regime: %w, try a covariance repair", state.Name, err)
+		}
+
+		for c, s := range seriesReturns {
+			state.AssetMu[c] = mu[c] * float64(s.AnnualizationFactor)
+			state.AssetSigma[c] = math.Sqrt(cov.At(c, c) * float64(s.AnnualizationFactor))
+		}
+
+		model.States = append(model.States, state)
+		model.assetMu = append(model.assetMu, mu)
+		model.assetChol = append(model.assetChol, chol)
+	}
+
+	model.cumulativeP = make([][]float64, k)
+	for i, row := range model.Transition {
+		model.cumulativeP[i] = cumulative(row)
+	}
+	model.cumulativeC = cumulative(model.CurrentProbabilities)
+
+	return model, nil
+}
+
+// gaussianHmm is a one dimensional hidden markov model with normal emissions
+type gaussianHmm struct {
+	initial, mean, variance []float64
+	transition              [][]float64
+	varianceFloor           float64
+}
+
+// newGaussianHmm starts the regimes at evenly spaced quantiles of the returns with sticky transitions
+func newGaussianHmm(x []float64, k int) *gaussianHmm {
+	sorted := slices.Clone(x)
+	slices.Sort(sorted)
+	variance := stat.Variance(x, nil)
+
+	hmm := &gaussianHmm{
+		initial:       make([]float64, k),
+		mean:          make([]float64, k),
+		variance:      make([]float64, k),
+		transition:    make([][]float64, k),
+		varianceFloor: 1e-4 * variance,
+	}
+	for i := range k {
+		hmm.initial[i] = 1 / float64(k)
+		hmm.mean[i] = stat.Quantile((float64(i)+0.5)/float64(k), stat.Empirical, sorted, nil)
+		hmm.variance[i] = variance
+		hmm.transition[i] = make([]float64, k)
+		for j := range k {
+			hmm.transition[i][j] = 0.1 / float64(k-1)
+		}
+		hmm.transition[i][i] = 0.9
+	}
+	return hmm
+}
+
+func (h *gaussianHmm) emissions(x []float64) [][]float64 {
+	res := make([][]float64, len(x))
+	for t, v := range x {
+		res[t] = make([]float64, len(h.mean))
+		for i := range h.mean {
+			res[t][i] = distuv.Normal{Mu: h.mean[i], Sigma: math.Sqrt(h.variance[i])}.Prob(v)
+		}
+	}
+	return res
+}
+
+// forward is the scaled forward pass, it returns the scaling constants and the filtered probabilities
+func (h *gaussianHmm) forward(x []float64) ([]float64, [][]float64) {
+	k := len(h.mean)
+	b := h.emissions(x)
+	scale := make([]float64, len(x))
+	alpha := make([][]float64, len(x))
+	for t := range x {
+		alpha[t] = make([]float64, k)
+		for j := range k {
+			if t == 0 {
+				alpha[t][j] = h.initial[j] * b[t][j]
+				continue
+			}
+			for i := range k {
+				alpha[t][j] += alpha[t-1][i] * h.transition[i][j]
+			}
+			alpha[t][j] *= b[t][j]
+		}
+		scale[t] = math.Max(ex.Sum(alpha[t]), math.SmallestNonzeroFloat64)
+		for j := range k {
+			alpha[t][j] /= scale[t]
+		}
+	}
+	return scale, alpha
+}
+
+// step is one EM iteration, it returns the smoothed regime probabilities and the log likelihood
+// of the parameters before the update
+func (h *gaussianHmm) step(x []float64) ([][]float64, float64) {
+	k, nObs := len(h.mean), len(x)
+	b := h.emissions(x)
+	scale, alpha := h.forward(x)
+
+	beta := make([][]float64, nObs)
+	beta[nObs-1] = make([]float64, k)
+	for i := range k {
+		beta[nObs-1][i] = 1
+	}
+	for t := nObs - 2; t >= 0; t-- {
+		beta[t] = make([]float64, k)
+		for i := range k {
+			for j := range k {
+				beta[t][i] += h.transition[i][j] * b[t+1][j] * beta[t+1][j]
+			}
+			beta[t][i] /= scale[t+1]
+		}
+	}
+
+	ll := 0.0
+	gamma := make([][]float64, nObs)
+	for t := range nObs {
+		ll += math.Log(scale[t])
+		gamma[t] = make([]float64, k)
+		total := 0.0
+		for i := range k {
+			gamma[t][i] = alpha[t][i] * beta[t][i]
+			total += gamma[t][i]
+		}
+		for i := range k {
+			gamma[t][i] /= total
+		}
+	}
+
+	for i := range k {
+		from := 0.0
+		to := make([]float64, k)
+		for t := range nObs - 1 {
+			from += gamma[t][i]
+			for j := range k {
+				to[j] += alpha[t][i] * h.transition[i][j] * b[t+1][j] * beta[t+1][j] / scale[t+1]
+			}
+		}
+		for j := range k {
+			h.transition[i][j] = to[j] / from
+		}
+
+		weight := make([]float64, nObs)
+		for t := range nObs {
+			weight[t] = gamma[t][i]
+		}
+		h.initial[i] = gamma[0][i]
+		h.mean[i] = stat.Mean(x, weight)
+		h.variance[i] = 0
+		for t, v := range x {
+			h.variance[i] += weight[t] * (v - h.mean[i]) * (v - h.mean[i])
+		}
+		h.variance[i] = math.Max(h.variance[i]/ex.Sum(weight), h.varianceFloor)
+	}
+
+	return gamma, ll
+}
+
+// stationaryDistribution is the long run regime mix, found by iterating the transition matrix
+func stationaryDistribution(p [][]float64) []float64 {
+	k := len(p)
+	dist := make([]float64, k)
+	for i := range dist {
+		dist[i] = 1 / float64(k)
+	}
+	for range 10_000 {
+		next := make([]float64, k)
+		for i := range k {
+			for j := range k {
+				next[j] += dist[i] * p[i][j]
+			}
+		}
+		dist = next
+	}
+	return dist
+}
+
+func cumulative(p []float64) []float64 {
+	res := make([]float64, len(p))
+	total := 0.0
+	for i, v := range p {
+		total += v
+		res[i] = total
+	}
+	return res
+}
+
+// drawRegime picks an index from cumulative probabilities
+func (wr *WorkerResource) drawRegime(cumulative []float64) int {
+	u := distuv.Uniform{Min: 0, Max: cumulative[len(cumulative)-1], Src: wr.rng}.Rand()
+	for i, c := range cumulative {
+		if u < c {
+			return i
+		}
+	}
+	return len(cumulative) - 1
+}
+
+// generateRegimeReturns draws one period of log returns from the current regime and then moves the
+// path to the next regime
+func (wr *WorkerResource) generateRegimeReturns() []float64 {
+	if wr.regimePeriods == nil {
+		wr.StartPath()
+	}
+
+	n := len(wr.Mu)
+	normalDist := distuv.Normal{Mu: 0, Sigma: 1, Src: wr.rng}
+	correlatedZ := generateCorrelatedRandomVector(n, normalDist, wr.Regimes.assetChol[wr.regime])
+
+	res := make([]float64, n)
+	for i := range n {
+		res[i] = wr.Regimes.assetMu[wr.regime][i] + correlatedZ.AtVec(i)
+	}
+
+	wr.regimePeriods[wr.regime]++
+	wr.regime = wr.drawRegime(wr.Regimes.cumulativeP[wr.regime])
+	return res
+}
+
+// regimeOccupancy is the share of all simulated periods spent in each regime
+func regimeOccupancy(paths []*SimulationResult, states int) []float64 {
+	res := make([]float64, states)
+	total := 0
+	for _, p := range paths {
+		if p == nil {
+			continue
+		}
+		for i, n := range p.Diagnostics.Regimes {
+			res[i] += float64(n)
+			total += n
+		}
+	}
+	if total == 0 {
+		return res
+	}
+	for i := range res {
+		res[i] /= float64(total)
+	}
+	return res
+}
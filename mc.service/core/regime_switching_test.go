@@ -0,0 +1,103 @@
+package core
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+
+	e "mc.data/extensions"
+)
+
+// twoRegimeSeries draws two assets from a calm bull regime and a volatile, more correlated bear regime
+func twoRegimeSeries(t *testing.T, n int) []*SeriesReturns {
+	t.Helper()
+	rng := rand.New(rand.NewPCG(11, 12))
+	stay := []float64{0.95, 0.98} // bear, bull
+	mean := []float64{-0.006, 0.003}
+	sd := []float64{0.035, 0.012}
+	corr := []float64{0.9, 0.3}
+
+	a, b := make([]float64, n), make([]float64, n)
+	state := 1
+	for i := range n {
+		z1, z2 := rng.NormFloat64(), rng.NormFloat64()
+		a[i] = mean[state] + sd[state]*z1
+		b[i] = mean[state] + sd[state]*(corr[state]*z1+math.Sqrt(1-corr[state]*corr[state])*z2)
+		if rng.Float64() > stay[state] {
+			state = 1 - state
+		}
+	}
+
+	res := []*SeriesReturns{}
+	for i, r := range [][]float64{a, b} {
+		res = append(res, &SeriesReturns{
+			SimulationAllocation: SimulationAllocation{Id: int32(i), Ticker: generateTicker(t, i), Weight: 0.5},
+			Returns:              r,
+			AnnualizationFactor:  Weekly,
+		})
+	}
+	return res
+}
+
+func Test_RegimeSwitching_FitRecoversRegimes(t *testing.T) {
+	series := twoRegimeSeries(t, 3000)
+
+	model, err := FitRegimeModel(&RegimeConfig{}, series, []float64{0.5, 0.5}, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	bear, bull := model.States[0], model.States[1]
+	t.Logf("bear %.4f/%.4f bull %.4f/%.4f transition %v", bear.PortfolioMean, bear.PortfolioStdDev, bull.PortfolioMean, bull.PortfolioStdDev, model.Transition)
+	e.AssertAreEqual(t, "converged", true, model.Converged)
+	e.AssertAreEqual(t, "names", "bear", bear.Name)
+	e.AssertAreEqual(t, "bear mean", true, math.Abs(bear.PortfolioMean+0.006) < 0.003)
+	e.AssertAreEqual(t, "bull mean", true, math.Abs(bull.PortfolioMean-0.003) < 0.001)
+	e.AssertAreEqual(t, "bear is volatile", true, bear.AssetSigma[0] > 2*bull.AssetSigma[0])
+	e.AssertAreEqual(t, "bear persistence", true, math.Abs(model.Transition[0][0]-0.95) < 0.03)
+	e.AssertAreEqual(t, "bull persistence", true, math.Abs(model.Transition[1][1]-0.98) < 0.01)
+	e.AssertAreEqual(t, "stationary share", true, math.Abs(bull.StationaryShare-0.714) < 0.08)
+	e.AssertAreEqual(t, "current probabilities", true, math.Abs(model.CurrentProbabilities[0]+model.CurrentProbabilities[1]-1) < 1e-9)
+
+	if _, err := FitRegimeModel(&RegimeConfig{States: 3}, []*SeriesReturns{{Returns: series[0].Returns[:50]}}, []float64{1}, ""); err == nil {
+		t.Fatalf("expected a short history to be rejected")
+	}
+}
+
+func Test_RegimeSwitching_PathsMoveBetweenRegimes(t *testing.T) {
+	series := twoRegimeSeries(t, 3000)
+	request := SimulationRequest{Regimes: &RegimeConfig{}, SimulationUnitOfTime: Weekly}
+
+	sr, err := GetStatisticalResources(request, series)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	worker := NewWorkerResources(sr, 42, 0)
+	paths := make([]*SimulationResult, 200)
+	for p := range paths {
+		worker.StartPath()
+		for range 520 {
+			worker.GetCorrelatedReturns(Weekly)
+		}
+		paths[p] = &SimulationResult{Diagnostics: PathDiagnostics{Regimes: worker.regimePeriods}}
+	}
+
+	occupancy := regimeOccupancy(paths, 2)
+	e.AssertAreEqual(t, "occupancy near the stationary mix", true, math.Abs(occupancy[1]-sr.Regimes.States[1].StationaryShare) < 0.05)
+	e.AssertAreEqual(t, "periods counted", 520, paths[0].Diagnostics.Regimes[0]+paths[0].Diagnostics.Regimes[1])
+
+	request.SimulationUnitOfTime = Monthly
+	if _, err := GetStatisticalResources(request, series); err == nil {
+		t.Fatalf("expected regimes at a different frequency than the returns to be rejected")
+	}
+
+	invalid := SimulationRequest{
+		Allocations:     []SimulationAllocation{{Id: 1, Weight: 1}},
+		Regimes:         &RegimeConfig{},
+		VolatilityModel: VolatilityGarch,
+	}
+	if err := invalid.Validate(); err == nil {
+		t.Fatalf("expected regimes with garch to be rejected")
+	}
+}
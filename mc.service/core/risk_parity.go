@@ -0,0 +1,232 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+const (
+	maxRiskParityIterations = 200
+	riskParityTolerance     = 1e-12
+)
+
+// RiskAnalysisRequest breaks the allocation's volatility down by asset and finds the weights that spread it
+// according to Budgets
+type RiskAnalysisRequest struct {
+	Allocations []SimulationAllocation `json:"allocations"`
+	EstimationSettings
+
+	Budgets map[string]float64 `json:"budgets"` // share of total risk per ticker, normalized to sum to one, empty is equal risk
+}
+
+type RiskAnalysisResult struct {
+	Window             *EffectiveWindow        `json:"window"`
+	CovarianceEstimate *CovarianceEstimate     `json:"covarianceestimate"`
+	CovarianceRepair   *CovarianceRepairReport `json:"covariancerepair"`
+	Current            *RiskDecomposition      `json:"current"`    // the requested weights
+	RiskParity         *RiskDecomposition      `json:"riskparity"` // the weights whose risk contributions match the budgets
+}
+
+// RiskDecomposition splits a portfolio's annualized volatility into per asset contributions that sum to it
+type RiskDecomposition struct {
+	Volatility    float64                `json:"volatility"`
+	Contributions []*RiskContribution    `json:"contributions"`
+	Allocations   []SimulationAllocation `json:"allocations"`
+}
+
+type RiskContribution struct {
+	Id         int32   `json:"id"`
+	Ticker     string  `json:"ticker"`
+	Weight     float64 `json:"weight"`
+	Volatility float64 `json:"volatility"`   // the asset's own annualized volatility
+	Marginal   float64 `json:"marginal"`     // change in portfolio volatility per unit of weight
+	Absolute   float64 `json:"contribution"` // weight times marginal
+	Percentage float64 `json:"percentage"`   // share of portfolio volatility
+	Budget     float64 `json:"budget,omitempty"`
+}
+
+func (rr RiskAnalysisRequest) Validate() error {
+	if err := rr.simulationRequest().Validate(); err != nil {
+		return err
+	}
+	for ticker, b := range rr.Budgets {
+		if b <= 0 {
+			return fmt.Errorf("%s needs a positive risk budget, got %v", ticker, b)
+		}
+	}
+	return nil
+}
+
+func (rr RiskAnalysisRequest) simulationRequest() SimulationRequest {
+	return rr.EstimationSettings.simulationRequest(rr.Allocations)
+}
+
+// AnalyzeRisk decomposes the allocation's risk and solves for its risk parity weights
+func (sc *ServiceContext) AnalyzeRisk(req RiskAnalysisRequest) (*RiskAnalysisResult, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	seriesReturns, sr, window, err := sc.estimateStatistics(req.simulationRequest())
+	if err != nil {
+		return nil, err
+	}
+
+	res := &RiskAnalysisResult{Window: window, CovarianceEstimate: sr.CovarianceEstimate, CovarianceRepair: sr.CovarianceRepair}
+	cov := annualizedCovariance(sr.CovMatrix, seriesReturns)
+
+	// dropped assets change the weights, the statistical resources hold the ones actually used
+	res.Current = GetRiskContributions(seriesReturns, sr.AssetWeight, cov)
+
+	budgets, err := riskBudgets(seriesReturns, req.Budgets)
+	if err != nil {
+		return nil, err
+	}
+	weights, err := RiskBudgetWeights(cov, budgets)
+	if err != nil {
+		return nil, err
+	}
+	res.RiskParity = GetRiskContributions(seriesReturns, weights, cov)
+	for i, c := range res.RiskParity.Contributions {
+		c.Budget = budgets[i]
+	}
+	return res, nil
+}
+
+// GetRiskContributions computes each asset's marginal and total contribution to portfolio volatility,
+// cov is annualized. The contributions sum to the volatility because it is homogeneous in the weights.
+func GetRiskContributions(assets []*SeriesReturns, weights []float64, cov *mat.SymDense) *RiskDecomposition {
+	n := len(weights)
+	w := mat.NewVecDense(n, weights)
+	var covW mat.VecDense
+	covW.MulVec(cov, w)
+
+	res := &RiskDecomposition{Volatility: math.Sqrt(mat.Dot(w, &covW))}
+	for i, a := range assets {
+		c := &RiskContribution{Id: a.Id, Ticker: a.Ticker, Weight: weights[i], Volatility: math.Sqrt(cov.At(i, i))}
+		if res.Volatility > 0 {
+			c.Marginal = covW.AtVec(i) / res.Volatility
+			c.Absolute = weights[i] * c.Marginal
+			c.Percentage = c.Absolute / res.Volatility
+		}
+		res.Contributions = append(res.Contributions, c)
+		res.Allocations = append(res.Allocations, SimulationAllocation{Id: a.Id, Ticker: a.Ticker, Weight: weights[i]})
+	}
+	return res
+}
+
+// riskBudgets lines the requested budgets up with the assets, every asset needs one unless none are given
+func riskBudgets(assets []*SeriesReturns, requested map[string]float64) ([]float64, error) {
+	res := make([]float64, len(assets))
+	if len(requested) == 0 {
+		for i := range res {
+			res[i] = 1 / float64(len(res))
+		}
+		return res, nil
+	}
+
+	byTicker := make(map[string]float64, len(requested))
+	for t, b := range requested {
+		byTicker[strings.ToUpper(strings.TrimSpace(t))] = b
+	}
+
+	total := 0.0
+	for i, a := range assets {
+		b, ok := byTicker[strings.ToUpper(a.Ticker)]
+		if !ok {
+			return nil, fmt.Errorf("%s has no risk budget", a.Ticker)
+		}
+		res[i] = b
+		total += b
+	}
+	for i := range res {
+		res[i] /= total
+	}
+	return res, nil
+}
+
+// RiskBudgetWeights finds the long only weights whose percentage risk contributions equal the budgets. It
+// minimizes the convex 0.5 y'Cy - sum(b ln y) with Newton's method, at the optimum Cy = b / y so every
+// y * (Cy) is its budget and the weights are y scaled to sum to one (Spinu 2013).
+func RiskBudgetWeights(cov *mat.SymDense, budgets []float64) ([]float64, error) {
+	n := len(budgets)
+	b := mat.NewVecDense(n, budgets)
+
+	// start at the budgets scaled so the quadratic and log terms balance
+	scale := math.Sqrt(mat.Inner(b, cov, b))
+	if scale <= 0 {
+		return nil, fmt.Errorf("the covariance matrix has no variance to budget")
+	}
+	y := make([]float64, n)
+	for i := range y {
+		y[i] = budgets[i] / scale
+	}
+
+	objective := func(y []float64) float64 {
+		v := mat.NewVecDense(n, y)
+		res := 0.5 * mat.Inner(v, cov, v)
+		for i := range y {
+			res -= budgets[i] * math.Log(y[i])
+		}
+		return res
+	}
+
+	converged := false
+	for range maxRiskParityIterations {
+		var covY mat.VecDense
+		covY.MulVec(cov, mat.NewVecDense(n, y))
+
+		grad := mat.NewVecDense(n, nil)
+		hessian := mat.NewSymDense(n, nil)
+		hessian.CopySym(cov)
+		for i := range n {
+			grad.SetVec(i, covY.AtVec(i)-budgets[i]/y[i])
+			hessian.SetSym(i, i, cov.At(i, i)+budgets[i]/(y[i]*y[i]))
+		}
+
+		var step mat.VecDense
+		if err := step.SolveVec(hessian, grad); err != nil {
+			return nil, fmt.Errorf("error solving for risk parity weights: %w", err)
+		}
+
+		decrement := mat.Dot(grad, &step)
+		if decrement/2 < riskParityTolerance {
+			converged = true
+			break
+		}
+
+		// backtrack to stay positive and keep descending
+		current, t := objective(y), 1.0
+		next := make([]float64, n)
+		for {
+			positive := true
+			for i := range n {
+				next[i] = y[i] - t*step.AtVec(i)
+				positive = positive && next[i] > 0
+			}
+			if positive && objective(next) <= current-0.25*t*decrement {
+				break
+			}
+			t /= 2
+			if t < 1e-12 {
+				return nil, fmt.Errorf("risk parity line search did not make progress")
+			}
+		}
+		y, next = next, y
+	}
+	if !converged {
+		return nil, fmt.Errorf("risk parity weights did not converge in %d iterations", maxRiskParityIterations)
+	}
+
+	total := 0.0
+	for _, v := range y {
+		total += v
+	}
+	for i := range y {
+		y[i] /= total
+	}
+	return y, nil
+}
@@ -0,0 +1,74 @@
+package core
+
+import (
+	"math"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+
+	e "mc.data/extensions"
+)
+
+func Test_RiskParity_CryptoSleeveDominatesRisk(t *testing.T) {
+	assets := optimizationAssets("SPY", "BND", "BTC")
+	cov := mat.NewSymDense(3, []float64{
+		0.0225, 0.0015, 0.03,
+		0.0015, 0.0025, 0,
+		0.03, 0, 0.5625,
+	})
+
+	res := GetRiskContributions(assets, []float64{0.6, 0.3, 0.1}, cov)
+
+	sum, percentages := 0.0, 0.0
+	for _, c := range res.Contributions {
+		sum += c.Absolute
+		percentages += c.Percentage
+	}
+	e.AssertAreEqual(t, "contributions add up to volatility", true, math.Abs(sum-res.Volatility) < 1e-12)
+	e.AssertAreEqual(t, "percentages add up to one", true, math.Abs(percentages-1) < 1e-12)
+	e.AssertAreEqual(t, "btc volatility", true, math.Abs(res.Contributions[2].Volatility-0.75) < 1e-12)
+	// 0.1 * 0.07425 / 0.01809, four times its weight
+	e.AssertAreEqual(t, "a 10% crypto sleeve carries 41% of the risk", true, math.Abs(res.Contributions[2].Percentage-0.4104) < 1e-4)
+}
+
+func Test_RiskParity_EqualRiskForUncorrelatedAssets(t *testing.T) {
+	cov := mat.NewSymDense(2, []float64{0.01, 0, 0, 0.04})
+
+	// uncorrelated assets get inverse volatility weights, 1/0.1 and 1/0.2
+	w, err := RiskBudgetWeights(cov, []float64{0.5, 0.5})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	e.AssertAreEqual(t, "first weight", true, math.Abs(w[0]-2.0/3) < 1e-9)
+	e.AssertAreEqual(t, "second weight", true, math.Abs(w[1]-1.0/3) < 1e-9)
+}
+
+func Test_RiskParity_MatchesBudgets(t *testing.T) {
+	assets := optimizationAssets("SPY", "BND", "BTC")
+	cov := mat.NewSymDense(3, []float64{
+		0.0225, 0.0015, 0.03,
+		0.0015, 0.0025, 0,
+		0.03, 0, 0.5625,
+	})
+
+	budgets, err := riskBudgets(assets, map[string]float64{"spy": 5, "BND": 3, "btc": 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	w, err := RiskBudgetWeights(cov, budgets)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	res := GetRiskContributions(assets, w, cov)
+	for i, want := range []float64{0.5, 0.3, 0.2} {
+		e.AssertAreEqual(t, assets[i].Ticker+" risk share", true, math.Abs(res.Contributions[i].Percentage-want) < 1e-8)
+	}
+	if err := (SimulationRequest{Allocations: res.Allocations}).Validate(); err != nil {
+		t.Fatalf("risk parity weights do not validate: %v", err)
+	}
+
+	if _, err := riskBudgets(assets, map[string]float64{"SPY": 1}); err == nil {
+		t.Fatalf("expected assets without a budget to fail")
+	}
+}
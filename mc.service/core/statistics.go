@@ -35,12 +35,30 @@ type StatisticalResources struct {
 	Sigma         []float64 // annualized
 	DistType      int
 	Df            int
+
+	VolatilityModel          string            // constant or garch
+	Garch                    []*GarchFit       // per asset fits when VolatilityModel is garch
+	Jumps                    []*AssetJumpModel // per asset merton jumps, nil when jumps are off
+	SystemicJumps            bool
+	Regimes                  *RegimeModel                   // markov regimes, nil when regime switching is off
+	CovarianceEstimate       *CovarianceEstimate            // which estimator built CovMatrix
+	CovarianceRepair         *CovarianceRepairReport        // how far the covariance matrix was moved to make it positive definite
+	CapitalMarketAssumptions *CapitalMarketAssumptionReport // how Mu, Sigma and CovMatrix moved to the assumptions
+	BlackLitterman           *BlackLittermanResult          // how Mu moved from history, nil when black-litterman is off
+	Inflation                *InflationModel                // simulated price level, nil when inflation is off
+	Fees                     *FeeModel                      // costs charged on the holdings, nil when fees are off
+	Taxes                    *TaxModel                      // taxes on the holdings, nil when taxes are off
 }
 
 // Used for parallelization, will have shared materials to minimize memory usage
 type WorkerResource struct {
 	*StatisticalResources           // embed read only shared data
 	rng                   *rand.PCG // worker-specific RNG
+	variance              []float64 // per asset conditional variance of the current path (garch)
+	jumpCounts            []int     // per asset jumps drawn on the current path
+	regime                int       // regime the current path is in
+	regimePeriods         []int     // periods the current path has spent in each regime
+	inflationDeviation    float64   // ar1 inflation's current deviation from its mean
 }
 
 // Called in the go routine and have seeds respectively set for each
@@ -64,12 +82,43 @@ func GetStatisticalResources(request SimulationRequest, seriesReturns []*SeriesR
 		Df:       request.DegreesOfFreedom,
 	}
 
+	sr.VolatilityModel, err = normalizeVolatilityModel(request.VolatilityModel)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(seriesReturns) == 0 {
+		return nil, fmt.Errorf("no series returns to build statistical resources from")
+	}
+
 	returns := make([][]float64, len(seriesReturns))
 	for i, r := range seriesReturns {
 		returns[i] = r.Returns
 	}
 
-	sr.CovMatrix = GetCovarianceMatrix(returns)
+	if policy, _ := normalizeAlignmentPolicy(request.Alignment); policy == AlignPairwise {
+		sr.CovMatrix, err = GetPairwiseCovarianceMatrix(seriesReturns)
+		if err != nil {
+			return nil, err
+		}
+		sr.CovarianceEstimate = &CovarianceEstimate{Estimator: EstimatorSample}
+	} else {
+		for _, r := range seriesReturns {
+			if len(r.Returns) != len(returns[0]) {
+				return nil, fmt.Errorf("series returns are not aligned, %s has %d returns and %s has %d", seriesReturns[0].Ticker, len(returns[0]), r.Ticker, len(r.Returns))
+			}
+		}
+		sr.CovMatrix, sr.CovarianceEstimate, err = EstimateCovariance(request, returns, seriesReturns[0].AnnualizationFactor)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sr.CovMatrix, sr.CovarianceRepair, err = RepairCovariance(sr.CovMatrix, request.CovarianceRepair)
+	if err != nil {
+		return nil, err
+	}
+
 	sr.CholeskyL, err = GetCholeskyDecomposition(sr.CovMatrix)
 	if err != nil {
 		return nil, err
@@ -82,30 +131,140 @@ func GetStatisticalResources(request SimulationRequest, seriesReturns []*SeriesR
 		sr.AssetWeight[i] = r.Weight
 		sr.Mu[i] = stat.Mean(r.Returns, nil) * float64(r.AnnualizationFactor)
 		sr.Sigma[i] = stat.StdDev(r.Returns, nil) * math.Sqrt(float64(r.AnnualizationFactor))
+		if sr.CovarianceEstimate.Estimator == EstimatorEwma {
+			// the point of ewma is a current volatility, so it replaces the full history one
+			sr.Sigma[i] = math.Sqrt(sr.CovMatrix.At(i, i) * float64(r.AnnualizationFactor))
+		}
 	}
 
-	if request.DistType == StudentT {
-		sr.CorrMatrix = GetCorrelationMatrix(sr.CovMatrix, sr.Sigma)
+	if request.CapitalMarketAssumptions != nil {
+		mu, cov, report, err := ApplyCapitalMarketAssumptions(request.CapitalMarketAssumptions, seriesReturns, sr.Mu, annualizedCovariance(sr.CovMatrix, seriesReturns))
+		if err != nil {
+			return nil, err
+		}
+		sr.Mu, sr.CovMatrix, sr.CapitalMarketAssumptions = mu, periodCovariance(cov, seriesReturns), report
+		if !isPositiveDefinite(sr.CovMatrix) {
+			// assumed and historical correlations can disagree, the request's repair reconciles them
+			sr.CovMatrix, sr.CovarianceRepair, err = RepairCovariance(sr.CovMatrix, request.CovarianceRepair)
+			if err != nil {
+				return nil, err
+			}
+		}
+		for i, r := range seriesReturns {
+			sr.Sigma[i] = math.Sqrt(sr.CovMatrix.At(i, i) * float64(r.AnnualizationFactor))
+		}
+		sr.CholeskyL, err = GetCholeskyDecomposition(sr.CovMatrix)
+		if err != nil {
+			return nil, fmt.Errorf("the covariance matrix with the capital market assumptions is not positive definite, set a covariance repair: %w", err)
+		}
+	}
+
+	if request.BlackLitterman != nil {
+		mu, cov, report, err := BlackLittermanPosterior(request.BlackLitterman, seriesReturns, sr.Mu, annualizedCovariance(sr.CovMatrix, seriesReturns))
+		if err != nil {
+			return nil, err
+		}
+		sr.Mu, sr.CovMatrix, sr.BlackLitterman = mu, periodCovariance(cov, seriesReturns), report
+		for i, r := range seriesReturns {
+			sr.Sigma[i] = math.Sqrt(sr.CovMatrix.At(i, i) * float64(r.AnnualizationFactor))
+		}
+		sr.CholeskyL, err = GetCholeskyDecomposition(sr.CovMatrix)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if sr.VolatilityModel == VolatilityGarch {
+		for _, r := range seriesReturns {
+			if r.AnnualizationFactor != request.SimulationUnitOfTime {
+				return nil, fmt.Errorf("garch simulation steps in the frequency of the stored returns, %s has %s", r.Ticker, convertFrequencyToString(r.AnnualizationFactor))
+			}
+		}
+		sr.Garch, err = GetGarchFits(seriesReturns)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if request.Regimes != nil {
+		for _, r := range seriesReturns {
+			if r.AnnualizationFactor != request.SimulationUnitOfTime {
+				return nil, fmt.Errorf("regime switching steps in the frequency of the stored returns, %s has %s", r.Ticker, convertFrequencyToString(r.AnnualizationFactor))
+			}
+		}
+		sr.Regimes, err = FitRegimeModel(request.Regimes, seriesReturns, sr.AssetWeight, request.CovarianceRepair)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if request.Jumps != nil {
+		sr.Jumps = GetJumpModels(request.Jumps, seriesReturns)
+		sr.SystemicJumps = request.Jumps.Systemic
+	}
+
+	if request.DistType == StudentT || sr.VolatilityModel == VolatilityGarch {
+		// CovMatrix is per period while Sigma is annualized, so scale by the matrix's own diagonal
+		periodSigma := make([]float64, len(sr.Sigma))
+		for i := range periodSigma {
+			periodSigma[i] = math.Sqrt(sr.CovMatrix.At(i, i))
+		}
+		sr.CorrMatrix = GetCorrelationMatrix(sr.CovMatrix, periodSigma)
 		sr.CholeskyCorrL, err = GetCholeskyDecomposition(sr.CorrMatrix)
 		if err != nil {
 			return nil, fmt.Errorf("failed to compute correlation Cholesky: %w", err)
 		}
 	}
 
+	if request.Inflation != nil {
+		periodSigma := make([]float64, len(sr.Sigma))
+		for i := range periodSigma {
+			periodSigma[i] = math.Sqrt(sr.CovMatrix.At(i, i))
+		}
+		sr.Inflation, err = GetInflationModel(request.Inflation, seriesReturns, GetCorrelationMatrix(sr.CovMatrix, periodSigma), request.SimulationUnitOfTime)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if request.Fees != nil {
+		sr.Fees, err = GetFeeModel(request.Fees, seriesReturns, request.SimulationUnitOfTime)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if request.Taxes != nil {
+		sr.Taxes, err = GetTaxModel(request.Taxes, seriesReturns, request.SimulationUnitOfTime)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return sr, nil
 }
 
 // GetCorrelatedReturns generates one set of correlated returns
 // This is goroutine-safe as long as each goroutine has its own WorkerResources
 func (wr *WorkerResource) GetCorrelatedReturns(simulationUnitOfTime int) []float64 {
-	switch wr.DistType {
-	case StandardNormal:
-		return wr.generateNormalReturns(simulationUnitOfTime)
-	case StudentT:
-		return wr.generateTReturns(simulationUnitOfTime)
+	var res []float64
+	switch {
+	case wr.Regimes != nil:
+		res = wr.generateRegimeReturns()
+	case wr.VolatilityModel == VolatilityGarch:
+		res = wr.generateGarchReturns(simulationUnitOfTime)
+	case wr.DistType == StandardNormal:
+		res = wr.generateNormalReturns(simulationUnitOfTime)
+	case wr.DistType == StudentT:
+		res = wr.generateTReturns(simulationUnitOfTime)
 	default:
 		return nil
 	}
+
+	if wr.Jumps != nil {
+		wr.addJumps(res, simulationUnitOfTime)
+	}
+	return res
 }
 
 // generateNormalReturns generates a single period of correlated normal returns
@@ -116,7 +275,9 @@ func (wr *WorkerResource) generateNormalReturns(simulationUnitOfTime int) []floa
 
 	correlatedReturns := make([]float64, n)
 	for i := range n {
-		correlatedReturns[i] = CalculateLogNormalReturn(wr.Mu[i], wr.Sigma[i], correlatedZ.AtVec(i), simulationUnitOfTime)
+		// CholeskyL carries each asset's per period volatility, standardize so Sigma alone sets the scale
+		z := correlatedZ.AtVec(i) / math.Sqrt(wr.CovMatrix.At(i, i))
+		correlatedReturns[i] = CalculateLogNormalReturn(wr.Mu[i], wr.Sigma[i], z, simulationUnitOfTime)
 	}
 
 	return correlatedReturns
@@ -141,6 +302,62 @@ func (wr *WorkerResource) generateTReturns(simulationUnitOfTime int) []float64 {
 	return correlatedReturns
 }
 
+// StartPath resets the per path state before a new path is simulated
+func (wr *WorkerResource) StartPath() {
+	if wr.Inflation != nil {
+		wr.inflationDeviation = wr.Inflation.periodStart
+	}
+
+	if wr.Jumps != nil {
+		wr.jumpCounts = make([]int, len(wr.Jumps))
+	}
+
+	if wr.Regimes != nil {
+		wr.regimePeriods = make([]int, len(wr.Regimes.States))
+		wr.regime = wr.drawRegime(wr.Regimes.cumulativeC)
+	}
+
+	if wr.VolatilityModel != VolatilityGarch {
+		return
+	}
+
+	wr.variance = make([]float64, len(wr.Garch))
+	for i, g := range wr.Garch {
+		wr.variance[i] = g.CurrentVariance
+	}
+}
+
+// generateGarchReturns draws one period with each asset's current conditional variance and then steps
+// the variances forward, the shocks are correlated through the constant correlation matrix
+func (wr *WorkerResource) generateGarchReturns(simulationUnitOfTime int) []float64 {
+	if wr.variance == nil {
+		wr.StartPath()
+	}
+
+	n := len(wr.Mu)
+	normalDist := distuv.Normal{Mu: 0, Sigma: 1, Src: wr.rng}
+	correlatedZ := generateCorrelatedRandomVector(n, normalDist, wr.CholeskyCorrL)
+
+	correlatedReturns := make([]float64, n)
+	for i := range n {
+		z := correlatedZ.AtVec(i)
+		if wr.DistType == StudentT && wr.Df > 2 {
+			// t shocks through the copula, scaled to unit variance so h stays the variance
+			tDist := distuv.StudentsT{Mu: 0, Sigma: 1, Nu: float64(wr.Df), Src: wr.rng}
+			z = tDist.Quantile(normalDist.CDF(z)) * math.Sqrt(float64(wr.Df-2)/float64(wr.Df))
+		}
+
+		h := wr.variance[i]
+		correlatedReturns[i] = CalculateLogNormalReturn(wr.Mu[i], math.Sqrt(h*float64(simulationUnitOfTime)), z, simulationUnitOfTime)
+
+		g := wr.Garch[i]
+		shock := math.Sqrt(h) * z
+		wr.variance[i] = g.Omega + g.Alpha*shock*shock + g.Beta*h
+	}
+
+	return correlatedReturns
+}
+
 func generateCorrelatedRandomVector(n int, dist distuv.Normal, L *mat.TriDense) *mat.VecDense {
 	z := make([]float64, n)
 	for i := range n {
@@ -192,6 +409,7 @@ func GetCholeskyDecomposition(covMatrix *mat.SymDense) (*mat.TriDense, error) {
 	return L, nil
 }
 
+// ArrToMatrix lays each series out as a column, every series must be the same length
 func ArrToMatrix[T ex.Number](data [][]T) *mat.Dense {
 	nSymbols := len(data)
 	nObservations := len(data[0])
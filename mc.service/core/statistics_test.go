@@ -171,15 +171,17 @@ func TestStatisticalResourcesWorkerCorrelatedReturnsForStandardNormal(t *testing
 		asset_a_returns[i] = allReturns[i][0]
 	}
 
-	asset_a_mu := stat.Mean(asset_a_returns, nil)
-	asset_a_sigma := stat.StdDev(asset_a_returns, nil)
+	// the draws are daily, annualize them to compare with mu and sigma
+	asset_a_mu := stat.Mean(asset_a_returns, nil) * Daily
+	asset_a_sigma := stat.StdDev(asset_a_returns, nil) * math.Sqrt(Daily)
+	expected_mu := calculateDriftAdjustedMu(t, sr.Mu[0], sr.Sigma[0])
 
-	t.Logf("Asset 0 - Expected mean: %.4f, Simulated: %.4f", sr.Mu[0], asset_a_mu)
+	t.Logf("Asset 0 - Expected mean: %.4f, Simulated: %.4f", expected_mu, asset_a_mu)
 	t.Logf("Asset 0 - Expected std: %.4f, Simulated: %.4f", sr.Sigma[0], asset_a_sigma)
 
 	// Allow 5% tolerance for mean and std (Monte Carlo variation)
-	if math.Abs(asset_a_mu-sr.Mu[0]) > 0.01 {
-		t.Errorf("Mean differs too much: expected %.4f, got %.4f", sr.Mu[0], asset_a_mu)
+	if math.Abs(asset_a_mu-expected_mu) > 0.01 {
+		t.Errorf("Mean differs too much: expected %.4f, got %.4f", expected_mu, asset_a_mu)
 	}
 	if math.Abs(asset_a_sigma-sr.Sigma[0]) > 0.02 {
 		t.Errorf("StdDev differs too much: expected %.4f, got %.4f", sr.Sigma[0], asset_a_sigma)
@@ -208,6 +210,24 @@ func TestStatisticalResourcesWorkerCorrelatedReturnsForStudentT(t *testing.T) {
 	// TODO: need to finish this at some point, but am going to work on the controller and front end to get some tangible results
 }
 
+// TestStudentTCorrelationMatrix checks the copula correlations come from the per period covariance
+func TestStudentTCorrelationMatrix(t *testing.T) {
+	returns := generateMockSeriesReturns(t, Daily*20)
+	sr, err := GetStatisticalResources(SimulationRequest{DistType: StudentT, DegreesOfFreedom: 5}, returns)
+	if err != nil {
+		t.Fatalf("Failed to create StatisticalResources: %v", err)
+	}
+
+	for i := range len(returns) {
+		if math.Abs(sr.CorrMatrix.At(i, i)-1) > 1e-9 {
+			t.Errorf("Asset %d: expected a unit diagonal, got %.4f", i, sr.CorrMatrix.At(i, i))
+		}
+	}
+	if math.Abs(sr.CorrMatrix.At(0, 1)-corr_ab) > 0.05 {
+		t.Errorf("Expected correlation ~%.2f, got %.4f", corr_ab, sr.CorrMatrix.At(0, 1))
+	}
+}
+
 // Helper: Generate mock series returns
 func generateMockSeriesReturns(t *testing.T, n int) []*SeriesReturns {
 	t.Helper()
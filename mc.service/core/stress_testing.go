@@ -0,0 +1,281 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	m "mc.data/models"
+)
+
+const (
+	DefaultStressInitialValue = 100.0
+
+	// weekly bars rarely land on a scenario's exact start or end, prices this close still count
+	stressWindowSlack = 14 * 24 * time.Hour
+)
+
+// ErrStressData marks a scenario that could not be replayed because an asset has no prices for it
+var ErrStressData = errors.New("not enough stored prices for the scenario")
+
+type StressTestRequest struct {
+	Allocations  []SimulationAllocation `json:"allocations"`
+	Scenarios    []string               `json:"scenarios"` // stored scenario names, every stored scenario when empty and there are no custom ones
+	Custom       []*m.StressScenario    `json:"custom"`    // ad hoc historical windows or shocks that are not stored
+	InitialValue float64                `json:"initialvalue"`
+}
+
+type StressTestResult struct {
+	Scenario      string                `json:"scenario"`
+	Description   string                `json:"description"`
+	Kind          string                `json:"kind"`
+	Start         time.Time             `json:"start,omitzero"`
+	End           time.Time             `json:"end,omitzero"`
+	Path          []*StressPoint        `json:"path"`
+	TotalReturn   float64               `json:"totalreturn"`
+	MaxDrawdown   float64               `json:"maxdrawdown"` // largest peak to trough loss along the path, positive
+	Contributions []*StressContribution `json:"contributions"`
+	Error         string                `json:"error,omitempty"` // the scenario could not be run, the rest of the result is empty
+}
+
+type StressPoint struct {
+	Date  time.Time `json:"date,omitzero"` // empty for shocks, they have no dates
+	Value float64   `json:"value"`
+}
+
+// StressContribution is one asset's share of the scenario's total return, the contributions sum to it
+type StressContribution struct {
+	Id           int32   `json:"id"`
+	Ticker       string  `json:"ticker"`
+	Weight       float64 `json:"weight"`
+	Return       float64 `json:"return"`
+	Contribution float64 `json:"contribution"`
+}
+
+// ValidateStressScenario checks a scenario before it is stored or run
+func ValidateStressScenario(s *m.StressScenario) error {
+	s.Name = strings.TrimSpace(s.Name)
+	if s.Name == "" || len(s.Name) > 100 {
+		return fmt.Errorf("scenario name is required and can be at most 100 characters")
+	}
+
+	switch s.Kind {
+	case m.StressKindHistorical:
+		if s.StartDate == nil || s.EndDate == nil || !s.StartDate.Before(*s.EndDate) {
+			return fmt.Errorf("historical scenario %s needs a start date before its end date", s.Name)
+		}
+	case m.StressKindShock:
+		if len(s.Shocks) == 0 {
+			return fmt.Errorf("shock scenario %s needs at least one shock", s.Name)
+		}
+		shocks := make(map[string]float64, len(s.Shocks))
+		for symbol, shock := range s.Shocks {
+			if shock <= -1 {
+				return fmt.Errorf("shock scenario %s: %s cannot lose more than 100%%", s.Name, symbol)
+			}
+			shocks[strings.ToUpper(strings.TrimSpace(symbol))] = shock
+		}
+		s.Shocks = shocks
+	default:
+		return fmt.Errorf("scenario %s has unknown kind %q, expected %s or %s", s.Name, s.Kind, m.StressKindHistorical, m.StressKindShock)
+	}
+	return nil
+}
+
+// RunStressTests runs every requested scenario against the allocation. Scenarios that lack price data
+// come back with Error set so the others are still reported.
+func (sc *ServiceContext) RunStressTests(req StressTestRequest) ([]*StressTestResult, error) {
+	if err := (SimulationRequest{Allocations: req.Allocations}).Validate(); err != nil {
+		return nil, err
+	}
+	if req.InitialValue == 0 {
+		req.InitialValue = DefaultStressInitialValue
+	}
+	if req.InitialValue < 0 {
+		return nil, fmt.Errorf("initial value must be positive")
+	}
+
+	scenarios, err := sc.getStressScenarios(req)
+	if err != nil {
+		return nil, err
+	}
+
+	allocations := slices.Clone(req.Allocations)
+	ids := make([]int32, len(allocations))
+	for i, a := range allocations {
+		ids[i] = a.Id
+		if a.Ticker != "" {
+			continue
+		}
+		md, err := sc.PostgresConnection.GetMetaDataById(sc.Context, a.Id)
+		if err != nil {
+			return nil, err
+		}
+		if md == nil {
+			return nil, fmt.Errorf("no stored series for allocation %d", a.Id)
+		}
+		allocations[i].Ticker = md.Symbol
+	}
+
+	res := make([]*StressTestResult, 0, len(scenarios))
+	for _, s := range scenarios {
+		var result *StressTestResult
+		switch s.Kind {
+		case m.StressKindShock:
+			result = applyStressShocks(allocations, s.Shocks, req.InitialValue)
+		default:
+			bars, err := sc.PostgresConnection.GetTimeSeriesDataBetween(sc.Context, ids, s.StartDate.Add(-stressWindowSlack), *s.EndDate)
+			if err != nil {
+				return nil, err
+			}
+
+			bySource := make(map[int32][]*m.TimeSeriesData, len(ids))
+			for _, b := range bars {
+				bySource[b.SourceId] = append(bySource[b.SourceId], b)
+			}
+
+			result, err = replayStressWindow(allocations, bySource, *s.StartDate, *s.EndDate, req.InitialValue)
+			if errors.Is(err, ErrStressData) {
+				result = &StressTestResult{Error: err.Error()}
+			} else if err != nil {
+				return nil, err
+			}
+		}
+
+		result.Scenario, result.Description, result.Kind = s.Name, s.Description, s.Kind
+		res = append(res, result)
+	}
+
+	return res, nil
+}
+
+func (sc *ServiceContext) getStressScenarios(req StressTestRequest) ([]*m.StressScenario, error) {
+	if len(req.Scenarios) == 0 && len(req.Custom) == 0 {
+		return sc.PostgresConnection.GetStressScenarios(sc.Context)
+	}
+
+	res := []*m.StressScenario{}
+	for _, name := range req.Scenarios {
+		s, err := sc.PostgresConnection.GetStressScenario(sc.Context, name)
+		if err != nil {
+			return nil, err
+		}
+		if s == nil {
+			return nil, fmt.Errorf("stress scenario %s does not exist", name)
+		}
+		res = append(res, s)
+	}
+
+	for _, s := range req.Custom {
+		if err := ValidateStressScenario(s); err != nil {
+			return nil, err
+		}
+		res = append(res, s)
+	}
+	return res, nil
+}
+
+// replayStressWindow holds the allocation from start to end without rebalancing. bars are ascending
+// per source id and may start up to stressWindowSlack before start.
+func replayStressWindow(allocations []SimulationAllocation, bars map[int32][]*m.TimeSeriesData, start, end time.Time, initial float64) (*StressTestResult, error) {
+	base := make([]float64, len(allocations))
+	dates := map[time.Time]bool{}
+	for i, a := range allocations {
+		series := bars[a.Id]
+		if len(series) == 0 {
+			return nil, fmt.Errorf("%w: %s has no prices between %s and %s", ErrStressData, a.Ticker, start.Format(time.DateOnly), end.Format(time.DateOnly))
+		}
+
+		// the last price on or before the start, or the first one shortly after it
+		b := series[0]
+		for _, bar := range series {
+			if bar.Timestamp.After(start) {
+				break
+			}
+			b = bar
+		}
+		if b.Timestamp.After(start.Add(stressWindowSlack)) {
+			return nil, fmt.Errorf("%w: %s has no prices until %s", ErrStressData, a.Ticker, b.Timestamp.Format(time.DateOnly))
+		}
+		if last := series[len(series)-1].Timestamp; last.Before(end.Add(-stressWindowSlack)) {
+			return nil, fmt.Errorf("%w: %s has no prices after %s", ErrStressData, a.Ticker, last.Format(time.DateOnly))
+		}
+		base[i] = b.AdjustedClose
+
+		for _, bar := range series {
+			if bar.Timestamp.After(start) {
+				dates[bar.Timestamp] = true
+			}
+		}
+	}
+
+	res := &StressTestResult{
+		Start: start,
+		End:   end,
+		Path:  []*StressPoint{{Date: start, Value: initial}},
+	}
+
+	ordered := slices.SortedFunc(maps.Keys(dates), func(a, b time.Time) int { return a.Compare(b) })
+
+	cursor := make([]int, len(allocations))
+	growth := make([]float64, len(allocations))
+	for i := range growth {
+		growth[i] = 1
+	}
+
+	peak := initial
+	for _, d := range ordered {
+		value := 0.0
+		for i, a := range allocations {
+			series := bars[a.Id]
+			for cursor[i] < len(series) && !series[cursor[i]].Timestamp.After(d) {
+				if series[cursor[i]].Timestamp.After(start) {
+					growth[i] = series[cursor[i]].AdjustedClose / base[i]
+				}
+				cursor[i]++
+			}
+			value += initial * a.Weight * growth[i]
+		}
+
+		res.Path = append(res.Path, &StressPoint{Date: d, Value: value})
+		peak = math.Max(peak, value)
+		res.MaxDrawdown = math.Max(res.MaxDrawdown, 1-value/peak)
+	}
+
+	res.TotalReturn = res.Path[len(res.Path)-1].Value/initial - 1
+	for i, a := range allocations {
+		res.Contributions = append(res.Contributions, &StressContribution{
+			Id:           a.Id,
+			Ticker:       a.Ticker,
+			Weight:       a.Weight,
+			Return:       growth[i] - 1,
+			Contribution: a.Weight * (growth[i] - 1),
+		})
+	}
+
+	return res, nil
+}
+
+// applyStressShocks moves every asset by its shock at once, assets without one are left flat
+func applyStressShocks(allocations []SimulationAllocation, shocks map[string]float64, initial float64) *StressTestResult {
+	res := &StressTestResult{}
+	for _, a := range allocations {
+		shock := shocks[strings.ToUpper(a.Ticker)]
+		res.TotalReturn += a.Weight * shock
+		res.Contributions = append(res.Contributions, &StressContribution{
+			Id:           a.Id,
+			Ticker:       a.Ticker,
+			Weight:       a.Weight,
+			Return:       shock,
+			Contribution: a.Weight * shock,
+		})
+	}
+
+	res.Path = []*StressPoint{{Value: initial}, {Value: initial * (1 + res.TotalReturn)}}
+	res.MaxDrawdown = math.Max(0, -res.TotalReturn)
+	return res
+}
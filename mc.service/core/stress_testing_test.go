@@ -0,0 +1,85 @@
+package core
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	e "mc.data/extensions"
+	m "mc.data/models"
+)
+
+func stressBars(id int32, start time.Time, closes ...float64) []*m.TimeSeriesData {
+	res := []*m.TimeSeriesData{}
+	for i, c := range closes {
+		res = append(res, &m.TimeSeriesData{SourceId: id, Timestamp: start.AddDate(0, 0, 7*i), AdjustedClose: c})
+	}
+	return res
+}
+
+func Test_StressTesting_ReplaysWindow(t *testing.T) {
+	start := time.Date(2020, time.February, 19, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 28)
+	allocations := []SimulationAllocation{{Id: 1, Ticker: "SPY", Weight: 0.6}, {Id: 2, Ticker: "TLT", Weight: 0.4}}
+	bars := map[int32][]*m.TimeSeriesData{
+		1: stressBars(1, start.AddDate(0, 0, -2), 100, 90, 70, 80, 75), // the first bar is just before the start
+		2: stressBars(2, start.AddDate(0, 0, -2), 100, 105, 110, 100, 105),
+	}
+
+	res, err := replayStressWindow(allocations, bars, start, end, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	e.AssertAreEqual(t, "path points", 5, len(res.Path))
+	e.AssertAreEqual(t, "starts at the initial value", 100.0, res.Path[0].Value)
+	// trough is 0.6 * 70 + 0.4 * 110 = 86
+	e.AssertAreEqual(t, "max drawdown", true, math.Abs(res.MaxDrawdown-0.14) < 1e-12)
+	e.AssertAreEqual(t, "total return", true, math.Abs(res.TotalReturn-(0.6*-0.25+0.4*0.05)) < 1e-12)
+
+	sum := 0.0
+	for _, c := range res.Contributions {
+		sum += c.Contribution
+	}
+	e.AssertAreEqual(t, "contributions add up", true, math.Abs(sum-res.TotalReturn) < 1e-12)
+	e.AssertAreEqual(t, "spy return", true, math.Abs(res.Contributions[0].Return+0.25) < 1e-12)
+
+	// an asset that listed after the window cannot be replayed
+	bars[2] = stressBars(2, end.AddDate(0, 0, 1), 100)
+	if _, err := replayStressWindow(allocations, bars, start, end, 100); !errors.Is(err, ErrStressData) {
+		t.Fatalf("expected a missing data error, got %v", err)
+	}
+}
+
+func Test_StressTesting_AppliesShocks(t *testing.T) {
+	allocations := []SimulationAllocation{{Id: 1, Ticker: "spy", Weight: 0.5}, {Id: 2, Ticker: "BTC", Weight: 0.1}, {Id: 3, Ticker: "BND", Weight: 0.4}}
+	scenario := &m.StressScenario{Name: "crypto winter", Kind: m.StressKindShock, Shocks: map[string]float64{"SPY": -0.2, " btc ": -0.7}}
+	if err := ValidateStressScenario(scenario); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	res := applyStressShocks(allocations, scenario.Shocks, 100)
+	e.AssertAreEqual(t, "total return", true, math.Abs(res.TotalReturn+0.17) < 1e-12)
+	e.AssertAreEqual(t, "shocked value", true, math.Abs(res.Path[1].Value-83) < 1e-9)
+	e.AssertAreEqual(t, "drawdown", true, math.Abs(res.MaxDrawdown-0.17) < 1e-12)
+	e.AssertAreEqual(t, "unshocked asset", 0.0, res.Contributions[2].Return)
+}
+
+func Test_StressTesting_ValidatesScenarios(t *testing.T) {
+	start := time.Date(2022, time.January, 3, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, -1, 0)
+
+	invalid := []*m.StressScenario{
+		{Name: "", Kind: m.StressKindShock, Shocks: map[string]float64{"SPY": -0.1}},
+		{Name: "backwards", Kind: m.StressKindHistorical, StartDate: &start, EndDate: &end},
+		{Name: "empty", Kind: m.StressKindShock},
+		{Name: "wipeout", Kind: m.StressKindShock, Shocks: map[string]float64{"SPY": -1.2}},
+		{Name: "unknown", Kind: "monte carlo"},
+	}
+	for _, s := range invalid {
+		if err := ValidateStressScenario(s); err == nil {
+			t.Errorf("expected scenario %q to be rejected", s.Name)
+		}
+	}
+}
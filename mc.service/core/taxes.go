@@ -0,0 +1,205 @@
+package core
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"gonum.org/v1/gonum/stat"
+
+	m "mc.data/models"
+)
+
+// how an account is taxed
+const (
+	TaxAccountTaxable  = "taxable"  // dividends as they are paid and gains as they are realized
+	TaxAccountDeferred = "deferred" // nothing until withdrawn, then everything as income
+)
+
+// TaxConfig taxes every path as an account of its kind. Trades follow the fees' rebalance rule, every
+// period without fees, and withdrawals sell pro rata.
+type TaxConfig struct {
+	Account          string  `json:"account"`          // taxable (default) or deferred
+	DividendRate     float64 `json:"dividendrate"`     // taxable, on dividends as they are paid
+	CapitalGainsRate float64 `json:"capitalgainsrate"` // taxable, on net gains realized by rebalancing and withdrawals
+	IncomeRate       float64 `json:"incomerate"`       // deferred, on everything withdrawn including the final value
+
+	// annual per ticker, replaces the yield measured from the stored dividends over the estimation window
+	DividendYields map[string]float64 `json:"dividendyields"`
+
+	yields map[int32]float64 // measured from the stored dividends, by source id
+}
+
+// TaxesPaid is what one path paid along the way and would owe if the final value were withdrawn
+type TaxesPaid struct {
+	Dividends    float64 `json:"dividends"`
+	CapitalGains float64 `json:"capitalgains"`
+	Income       float64 `json:"income"`      // on deferred withdrawals
+	Total        float64 `json:"total"`       // everything paid along the path
+	Liquidation  float64 `json:"liquidation"` // owed on the final value, unrealized gains or deferred income
+}
+
+// TaxSummary averages the taxes paid across the paths
+type TaxSummary struct {
+	Dividends          float64 `json:"dividends"`
+	CapitalGains       float64 `json:"capitalgains"`
+	Income             float64 `json:"income"`
+	Total              float64 `json:"total"`
+	Liquidation        float64 `json:"liquidation"`
+	AfterTaxFinalValue float64 `json:"aftertaxfinalvalue"` // median
+}
+
+// TaxModel is the resolved config in the order of the assets
+type TaxModel struct {
+	account          string
+	periodYields     []float64 // dividend paid per simulated period as a share of each holding
+	dividendRate     float64
+	capitalGainsRate float64
+	incomeRate       float64
+}
+
+func normalizeTaxAccount(account string) (string, error) {
+	switch v := strings.ToLower(strings.TrimSpace(account)); v {
+	case "":
+		return TaxAccountTaxable, nil
+	case TaxAccountTaxable, TaxAccountDeferred:
+		return v, nil
+	default:
+		return "", fmt.Errorf("unknown account %q, expected %s or %s", account, TaxAccountTaxable, TaxAccountDeferred)
+	}
+}
+
+func (tc *TaxConfig) Validate() error {
+	account, err := normalizeTaxAccount(tc.Account)
+	if err != nil {
+		return err
+	}
+	for _, r := range []float64{tc.DividendRate, tc.CapitalGainsRate, tc.IncomeRate} {
+		if r < 0 || r >= 1 {
+			return fmt.Errorf("tax rates must be in [0, 1), got %v", r)
+		}
+	}
+	if account == TaxAccountTaxable && tc.IncomeRate > 0 {
+		return fmt.Errorf("the income rate only applies to %s accounts", TaxAccountDeferred)
+	}
+	if account == TaxAccountDeferred && (tc.DividendRate > 0 || tc.CapitalGainsRate > 0) {
+		return fmt.Errorf("dividend and capital gains rates only apply to %s accounts", TaxAccountTaxable)
+	}
+	for ticker, y := range tc.DividendYields {
+		if y < 0 || y >= 1 {
+			return fmt.Errorf("dividend yield for %s must be in [0, 1), got %v", ticker, y)
+		}
+	}
+	return nil
+}
+
+// tracksDividends is whether the paths need dividend yields. Taxable accounts add reinvested dividends to
+// the cost basis even untaxed, the returns include them and they would otherwise be taxed as gains
+func (tc *TaxConfig) tracksDividends() bool {
+	account, _ := normalizeTaxAccount(tc.Account)
+	return account == TaxAccountTaxable
+}
+
+// loadDividendYields measures each asset's yield from the dividends stored over the estimation window
+func (sc *ServiceContext) loadDividendYields(request SimulationRequest, window *EffectiveWindow) error {
+	tc := request.Taxes
+	if tc == nil || !tc.tracksDividends() {
+		return nil
+	}
+
+	ids := make([]int32, len(request.Allocations))
+	for i, a := range request.Allocations {
+		ids[i] = a.Id
+	}
+	bars, err := sc.PostgresConnection.GetTimeSeriesDataBetween(sc.Context, ids, window.Start, window.End)
+	if err != nil {
+		return err
+	}
+
+	bySource := make(map[int32][]*m.TimeSeriesData, len(ids))
+	for _, b := range bars {
+		bySource[b.SourceId] = append(bySource[b.SourceId], b)
+	}
+	tc.yields = make(map[int32]float64, len(ids))
+	for id, b := range bySource {
+		tc.yields[id] = trailingDividendYield(b)
+	}
+	return nil
+}
+
+// trailingDividendYield annualizes the dividends paid over the bars, each as a share of the close before it
+func trailingDividendYield(bars []*m.TimeSeriesData) float64 {
+	if len(bars) < 2 {
+		return 0
+	}
+
+	paid := 0.0
+	for t := 1; t < len(bars); t++ {
+		if bars[t-1].Close > 0 {
+			paid += bars[t].DividendAmount / bars[t-1].Close
+		}
+	}
+
+	years := bars[len(bars)-1].Timestamp.Sub(bars[0].Timestamp).Hours() / 24 / daysPerYear
+	if years <= 0 {
+		return 0
+	}
+	return paid / years
+}
+
+// GetTaxModel lines the config up with the assets for the simulation's period length
+func GetTaxModel(config *TaxConfig, assets []*SeriesReturns, simulationUnitOfTime int) (*TaxModel, error) {
+	account, err := normalizeTaxAccount(config.Account)
+	if err != nil {
+		return nil, err
+	}
+
+	u := float64(simulationUnitOfTime)
+	res := &TaxModel{
+		account:          account,
+		periodYields:     make([]float64, len(assets)),
+		dividendRate:     config.DividendRate,
+		capitalGainsRate: config.CapitalGainsRate,
+		incomeRate:       config.IncomeRate,
+	}
+	for i, a := range assets {
+		res.periodYields[i] = config.yields[a.Id] / u
+	}
+
+	for ticker, y := range config.DividendYields {
+		found := false
+		for i, a := range assets {
+			if strings.EqualFold(a.Ticker, strings.TrimSpace(ticker)) {
+				res.periodYields[i] = y / u
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("dividend yield for %s which is not allocated", ticker)
+		}
+	}
+	return res, nil
+}
+
+// GetTaxSummary averages the taxes of the paths, nil when taxes were not simulated
+func GetTaxSummary(paths []*SimulationResult) *TaxSummary {
+	if len(paths) == 0 || paths[0] == nil || paths[0].Taxes == nil {
+		return nil
+	}
+
+	res := &TaxSummary{}
+	n := float64(len(paths))
+	afterTax := make([]float64, len(paths))
+	for i, p := range paths {
+		res.Dividends += p.Taxes.Dividends / n
+		res.CapitalGains += p.Taxes.CapitalGains / n
+		res.Income += p.Taxes.Income / n
+		res.Total += p.Taxes.Total / n
+		res.Liquidation += p.Taxes.Liquidation / n
+		afterTax[i] = p.AfterTaxFinalValue
+	}
+
+	slices.Sort(afterTax)
+	res.AfterTaxFinalValue = stat.Quantile(0.5, stat.Empirical, afterTax, nil)
+	return res
+}
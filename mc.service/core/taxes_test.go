@@ -0,0 +1,174 @@
+package core
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	e "mc.data/extensions"
+)
+
+// taxedResources grows 10% a year without noise
+func taxedResources(t *testing.T, config *TaxConfig) *StatisticalResources {
+	t.Helper()
+	sr := flatResources(t)
+	sr.Mu[0] = math.Log(1.1)
+
+	var err error
+	sr.Taxes, err = GetTaxModel(config, optimizationAssets("SPY"), Yearly)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return sr
+}
+
+func Test_Taxes_TaxableAccountTracksCostBasis(t *testing.T) {
+	config := &TaxConfig{DividendRate: 0.15, CapitalGainsRate: 0.2, DividendYields: map[string]float64{"SPY": 0.02}}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	request := SimulationRequest{
+		SimulationUnitOfTime: Yearly,
+		SimulationDuration:   2,
+		InitialValue:         1000,
+		CashFlows:            []*CashFlow{{Start: 2, Amount: -200}},
+		Taxes:                config,
+	}
+
+	res, err := NewWorkerResources(taxedResources(t, config), 42, 0).simulatePath(request)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// dividends are taxed as they are paid and the rest reinvested at cost
+	value, basis, dividendTax := 1000.0, 1000.0, 0.0
+	for range 2 {
+		value *= 1.1
+		dividend := value * 0.02
+		value -= dividend * 0.15
+		basis += dividend * 0.85
+		dividendTax += dividend * 0.15
+	}
+
+	// the withdrawal realizes its share of the gain, the tax is sold pro rata
+	withdrawn := 200 / value
+	gainsTax := 0.2 * (200 - basis*withdrawn)
+	basis *= 1 - withdrawn
+	value -= 200
+	pending := gainsTax - basis*gainsTax/value
+	basis *= 1 - gainsTax/value
+	value -= gainsTax
+
+	e.AssertAreEqual(t, "dividend tax", true, math.Abs(res.Taxes.Dividends-dividendTax) < 1e-9)
+	e.AssertAreEqual(t, "capital gains tax", true, math.Abs(res.Taxes.CapitalGains-gainsTax) < 1e-9)
+	e.AssertAreEqual(t, "final value", true, math.Abs(res.FinalValue-value) < 1e-9)
+	e.AssertAreEqual(t, "total", true, math.Abs(res.Taxes.Total-dividendTax-gainsTax) < 1e-9)
+
+	liquidation := 0.2 * (value - basis + pending)
+	e.AssertAreEqual(t, "liquidation", true, math.Abs(res.Taxes.Liquidation-liquidation) < 1e-9)
+	e.AssertAreEqual(t, "after tax", true, math.Abs(res.AfterTaxFinalValue-(value-liquidation)) < 1e-9)
+	e.AssertAreEqual(t, "gross is untaxed", true, math.Abs(res.GrossFinalValue-(1210-200)) < 1e-9)
+	e.AssertAreEqual(t, "no fees requested", true, res.Fees == nil)
+
+	summary := GetTaxSummary([]*SimulationResult{res})
+	e.AssertAreEqual(t, "median after tax", res.AfterTaxFinalValue, summary.AfterTaxFinalValue)
+}
+
+func Test_Taxes_DeferredAccountTaxesWithdrawals(t *testing.T) {
+	config := &TaxConfig{Account: "Deferred", IncomeRate: 0.25}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	request := SimulationRequest{
+		SimulationUnitOfTime: Yearly,
+		SimulationDuration:   2,
+		InitialValue:         1000,
+		CashFlows:            []*CashFlow{{Start: 2, Amount: -150}},
+		Taxes:                config,
+	}
+
+	res, err := NewWorkerResources(taxedResources(t, config), 42, 0).simulatePath(request)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// 200 is sold to leave 150 after the income tax
+	e.AssertAreEqual(t, "income tax", true, math.Abs(res.Taxes.Income-50) < 1e-9)
+	e.AssertAreEqual(t, "final value", true, math.Abs(res.FinalValue-1010) < 1e-9)
+	e.AssertAreEqual(t, "after tax", true, math.Abs(res.AfterTaxFinalValue-1010*0.75) < 1e-9)
+
+	config.CapitalGainsRate = 0.2
+	if err := config.Validate(); err == nil {
+		t.Fatalf("expected a capital gains rate on a deferred account to fail")
+	}
+}
+
+func Test_Taxes_LossesCarryForward(t *testing.T) {
+	model := &TaxModel{account: TaxAccountTaxable, periodYields: []float64{0, 0}, capitalGainsRate: 0.2}
+	holdings := startHoldings(nil, model, 100, []float64{0.5, 0.5})
+
+	// the winner is sold down to target, the loser bought at its new price
+	holdings.net = []float64{70, 30}
+	holdings.settle(0, 0)
+	gain := 20 - 50*20.0/70
+	e.AssertAreEqual(t, "gains tax", true, math.Abs(holdings.taxed.CapitalGains-0.2*gain) < 1e-9)
+	// then the tax is sold pro rata from both
+	e.AssertAreEqual(t, "bought at cost", true, math.Abs(holdings.basis[1]-70*(1-0.2*gain/100)) < 1e-9)
+
+	holdings.taxed.CapitalGains, holdings.realized = 0, -10
+	holdings.payCapitalGainsTax()
+	holdings.realized = 4
+	holdings.payCapitalGainsTax()
+	e.AssertAreEqual(t, "offset by the loss", 0.0, holdings.taxed.CapitalGains)
+	e.AssertAreEqual(t, "loss left", -6.0, holdings.carry)
+
+	holdings.realized = 10
+	holdings.payCapitalGainsTax()
+	e.AssertAreEqual(t, "taxed net of the loss", true, math.Abs(holdings.taxed.CapitalGains-0.8) < 1e-9)
+}
+
+func Test_Taxes_TrailingDividendYield(t *testing.T) {
+	bars := stressBars(1, time.Date(2024, time.January, 5, 0, 0, 0, 0, time.UTC), make([]float64, 53)...)
+	for i, b := range bars {
+		b.Close = 50
+		if i%13 == 1 {
+			b.DividendAmount = 0.25
+		}
+	}
+
+	// four quarterly dividends of half a percent over 52 weeks
+	want := 0.02 / (364 / daysPerYear)
+	e.AssertAreEqual(t, "yield", true, math.Abs(trailingDividendYield(bars)-want) < 1e-12)
+	e.AssertAreEqual(t, "too short", 0.0, trailingDividendYield(bars[:1]))
+}
+
+func Test_Taxes_UntaxedDividendsRaiseTheBasis(t *testing.T) {
+	config := &TaxConfig{CapitalGainsRate: 0.2, DividendYields: map[string]float64{"SPY": 0.02}}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	e.AssertAreEqual(t, "yields are loaded", true, config.tracksDividends())
+	e.AssertAreEqual(t, "not for deferred accounts", false, (&TaxConfig{Account: TaxAccountDeferred}).tracksDividends())
+
+	request := SimulationRequest{
+		SimulationUnitOfTime: Yearly,
+		SimulationDuration:   2,
+		InitialValue:         1000,
+		Taxes:                config,
+	}
+
+	res, err := NewWorkerResources(taxedResources(t, config), 42, 0).simulatePath(request)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the reinvested dividends were never taxed, so only the price gain is taxed on liquidation
+	value, basis := 1000.0, 1000.0
+	for range 2 {
+		value *= 1.1
+		basis += value * 0.02
+	}
+	e.AssertAreEqual(t, "dividend tax", 0.0, res.Taxes.Dividends)
+	e.AssertAreEqual(t, "final value", true, math.Abs(res.FinalValue-value) < 1e-9)
+	e.AssertAreEqual(t, "liquidation", true, math.Abs(res.Taxes.Liquidation-0.2*(value-basis)) < 1e-9)
+}
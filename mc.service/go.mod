@@ -13,6 +13,7 @@ require (
 	github.com/jackc/pgx/v5 v5.7.6 // indirect
 	github.com/jackc/puddle/v2 v2.2.2 // indirect
 	github.com/stretchr/testify v1.10.0 // indirect
+	golang.org/x/tools v0.39.0 // indirect
 )
 
 require (
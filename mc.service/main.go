@@ -12,7 +12,6 @@ import (
 	"github.com/joho/godotenv"
 
 	r "mc.data/repos"
-	av "mc.service/api/alpha_vantage"
 	c "mc.service/core"
 )
 
@@ -24,18 +23,38 @@ func main() {
 		log.Printf(".env not loaded: %v", err)
 	}
 
-    avClient := av.GetClient(os.Getenv("ALPHAVANTAGE_API_KEY"))    
+    providerConfig, err := c.GetProviderConfigFromEnv()
+    if err != nil {
+        log.Fatalf("Failed to read market data provider config: %v", err)
+    }
+
+    provider, err := c.GetMarketDataProvider(providerConfig)
+    if err != nil {
+        log.Fatalf("Failed to configure market data provider: %v", err)
+    }
+
     postgresConnection, err := r.GetPostgresConnection(ctx, os.Getenv("DATABASE_URL"))
     if err != nil {
         log.Fatalf("Failed to connect to database: %v", err)
     }
     defer postgresConnection.Close()
 
+    refreshConfig, err := c.GetRefreshConfigFromEnv()
+    if err != nil {
+        log.Fatalf("Failed to read refresh config: %v", err)
+    }
+
 	sc := c.ServiceContext{
 		Context:            ctx,
 		PostgresConnection: postgresConnection,
-		AlphaVantageClient: avClient,
+		MarketDataProvider: provider,
+		StalenessPolicy:    refreshConfig.Staleness,
 	}
+
+    if refreshConfig.Schedule != nil {
+        log.Printf("Refreshing stale symbols on schedule %s", refreshConfig.Schedule)
+        go sc.RunRefreshScheduler(ctx, refreshConfig)
+    }
     
     s := c.GetHttpServer(sc)
 